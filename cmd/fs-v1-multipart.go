@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/skyrings/skyring-common/tools/uuid"
+	"golang.org/x/net/context"
 )
 
 // listMultipartUploads - lists all multipart uploads.
@@ -78,7 +79,7 @@ func (fs fsObjects) listMultipartUploads(bucket, prefix, keyMarker, uploadIDMark
 			endWalkCh = make(chan struct{})
 			isLeaf := fs.isMultipartUpload
 			listDir := listDirFactory(isLeaf, fs.storage)
-			walkResultCh = startTreeWalk(minioMetaBucket, multipartPrefixPath, multipartMarkerPath, recursive, listDir, isLeaf, endWalkCh)
+			walkResultCh = startTreeWalk(context.Background(), minioMetaBucket, multipartPrefixPath, multipartMarkerPath, recursive, listDir, isLeaf, endWalkCh)
 		}
 		for maxUploads > 0 {
 			walkResult, ok := <-walkResultCh
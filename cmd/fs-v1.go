@@ -25,8 +25,10 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/minio/minio/pkg/mimedb"
+	"golang.org/x/net/context"
 )
 
 // fsObjects - Implements fs object layer.
@@ -518,30 +520,6 @@ func isBucketExist(storage StorageAPI, bucketName string) bool {
 // ListObjects - list all objects at prefix upto maxKeys., optionally delimited by '/'. Maintains the list pool
 // state for future re-entrant list requests.
 func (fs fsObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKeys int) (ListObjectsInfo, error) {
-	// Convert entry to FileInfo
-	entryToFileInfo := func(entry string) (fileInfo FileInfo, err error) {
-		if strings.HasSuffix(entry, slashSeparator) {
-			// Object name needs to be full path.
-			fileInfo.Name = entry
-			fileInfo.Mode = os.ModeDir
-			return
-		}
-		if fileInfo, err = fs.storage.StatFile(bucket, entry); err != nil {
-			return FileInfo{}, traceError(err)
-		}
-		fsMeta, mErr := readFSMetadata(fs.storage, minioMetaBucket, path.Join(bucketMetaPrefix, bucket, entry, fsMetaJSONFile))
-		if mErr != nil && errorCause(mErr) != errFileNotFound {
-			return FileInfo{}, traceError(mErr)
-		}
-		if len(fsMeta.Meta) == 0 {
-			fsMeta.Meta = make(map[string]string)
-		}
-		// Object name needs to be full path.
-		fileInfo.Name = entry
-		fileInfo.MD5Sum = fsMeta.Meta["md5Sum"]
-		return
-	}
-
 	// Verify if bucket is valid.
 	if !IsValidBucketName(bucket) {
 		return ListObjectsInfo{}, traceError(BucketNameInvalid{Bucket: bucket})
@@ -588,6 +566,38 @@ func (fs fsObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKey
 		maxKeys = maxObjectList
 	}
 
+	return fs.listObjects(bucket, prefix, marker, delimiter, maxKeys, globalListSoftDeadline)
+}
+
+// listObjects - wrapper function implemented over file tree walk, lists
+// upto maxKeys. A non-zero softDeadline bounds the time spent listing,
+// once it expires the entries listed so far are returned as a truncated
+// result whose NextMarker resumes the listing.
+func (fs fsObjects) listObjects(bucket, prefix, marker, delimiter string, maxKeys int, softDeadline time.Duration) (ListObjectsInfo, error) {
+	// Convert entry to FileInfo
+	entryToFileInfo := func(entry string, isDir bool) (fileInfo FileInfo, err error) {
+		if isDir {
+			// Object name needs to be full path.
+			fileInfo.Name = entry
+			fileInfo.Mode = os.ModeDir
+			return
+		}
+		if fileInfo, err = fs.storage.StatFile(bucket, entry); err != nil {
+			return FileInfo{}, traceError(err)
+		}
+		fsMeta, mErr := readFSMetadata(fs.storage, minioMetaBucket, path.Join(bucketMetaPrefix, bucket, entry, fsMetaJSONFile))
+		if mErr != nil && errorCause(mErr) != errFileNotFound {
+			return FileInfo{}, traceError(mErr)
+		}
+		if len(fsMeta.Meta) == 0 {
+			fsMeta.Meta = make(map[string]string)
+		}
+		// Object name needs to be full path.
+		fileInfo.Name = entry
+		fileInfo.MD5Sum = fsMeta.Meta["md5Sum"]
+		return
+	}
+
 	// Default is recursive, if delimiter is set then list non recursive.
 	recursive := true
 	if delimiter == slashSeparator {
@@ -605,13 +615,34 @@ func (fs fsObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKey
 			return !strings.HasSuffix(object, slashSeparator)
 		}
 		listDir := listDirFactory(isLeaf, fs.storage)
-		walkResultCh = startTreeWalk(bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh)
+		// Symlinks to ancestor directories would be walked forever.
+		opts := treeWalkOpts{realDir: realDirFunc(fs.storage)}
+		walkResultCh = startTreeWalkWithOpts(context.Background(), bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh, opts)
+	}
+	// A nil deadlineCh never fires, the listing is then bounded by maxKeys alone.
+	var deadlineCh <-chan time.Time
+	if softDeadline > 0 {
+		deadlineTimer := time.NewTimer(softDeadline)
+		defer deadlineTimer.Stop()
+		deadlineCh = deadlineTimer.C
 	}
+
 	var fileInfos []FileInfo
 	var eof bool
-	var nextMarker string
+	// Resume from marker itself if the soft deadline expires before anything is listed.
+	nextMarker := marker
 	for i := 0; i < maxKeys; {
-		walkResult, ok := <-walkResultCh
+		var walkResult treeWalkResult
+		var ok, expired bool
+		select {
+		case walkResult, ok = <-walkResultCh:
+		case <-deadlineCh:
+			expired = true
+		}
+		if expired {
+			// Soft deadline has expired, return what is listed so far.
+			break
+		}
 		if !ok {
 			// Closed channel.
 			eof = true
@@ -625,7 +656,7 @@ func (fs fsObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKey
 			}
 			return ListObjectsInfo{}, toObjectErr(walkResult.err, bucket, prefix)
 		}
-		fileInfo, err := entryToFileInfo(walkResult.entry)
+		fileInfo, err := entryToFileInfo(walkResult.entry, walkResult.isDir)
 		if err != nil {
 			return ListObjectsInfo{}, nil
 		}
@@ -643,6 +674,9 @@ func (fs fsObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKey
 	}
 
 	result := ListObjectsInfo{IsTruncated: !eof}
+	if !eof {
+		result.NextMarker = nextMarker
+	}
 	for _, fileInfo := range fileInfos {
 		result.NextMarker = fileInfo.Name
 		if fileInfo.Mode.IsDir() {
@@ -674,3 +708,92 @@ func (fs fsObjects) ListObjectsHeal(bucket, prefix, marker, delimiter string, ma
 func (fs fsObjects) HealDiskMetadata() error {
 	return NotImplemented{}
 }
+
+// ListObjectsPaged - lists upto limit objects under prefix a page at a
+// time, token is empty for the first page and the nextToken returned
+// with the previous page afterwards. nextToken is empty on the last page.
+// With delimiter set to '/' the listing is not recursive and common
+// prefixes are returned instead of the objects under them.
+func (fs fsObjects) ListObjectsPaged(bucket, prefix, token, delimiter string, limit int) (ListObjectsInfo, string, error) {
+	if !IsValidBucketName(bucket) {
+		return ListObjectsInfo{}, "", traceError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !isBucketExist(fs.storage, bucket) {
+		return ListObjectsInfo{}, "", traceError(BucketNotFound{Bucket: bucket})
+	}
+	if !IsValidObjectPrefix(prefix) {
+		return ListObjectsInfo{}, "", traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	}
+	// Verify if delimiter is anything other than '/', which we do not support.
+	if delimiter != "" && delimiter != slashSeparator {
+		return ListObjectsInfo{}, "", traceError(UnsupportedDelimiter{
+			Delimiter: delimiter,
+		})
+	}
+	// Default is recursive, if delimiter is set then list non recursive.
+	recursive := delimiter != slashSeparator
+	isLeaf := func(bucket, object string) bool {
+		return !strings.HasSuffix(object, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, fs.storage)
+	result, nextToken, err := listObjectsPaged(bucket, prefix, token, limit, recursive, fs.listPool, listDir, isLeaf, fs.getObjectInfo)
+	if err != nil {
+		return ListObjectsInfo{}, "", toObjectErr(err, bucket, prefix)
+	}
+	return result, nextToken, nil
+}
+
+// EstimateListCost - projects the number of directories and entries
+// listing prefix touches by sampling the top of the tree, so that clients
+// can decide whether to paginate or run the listing in the background.
+func (fs fsObjects) EstimateListCost(bucket, prefix string, recursive bool) (ListCostEstimate, error) {
+	if !IsValidBucketName(bucket) {
+		return ListCostEstimate{}, traceError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !isBucketExist(fs.storage, bucket) {
+		return ListCostEstimate{}, traceError(BucketNotFound{Bucket: bucket})
+	}
+	if !IsValidObjectPrefix(prefix) {
+		return ListCostEstimate{}, traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	}
+	isLeaf := func(bucket, object string) bool {
+		return !strings.HasSuffix(object, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, fs.storage)
+	estimate, err := estimateListCost(bucket, prefix, recursive, listDir, isLeaf, listCostProbes, time.Now().UnixNano())
+	if err != nil {
+		return ListCostEstimate{}, toObjectErr(err, bucket, prefix)
+	}
+	return estimate, nil
+}
+
+// ListAndDelete - deletes every object under prefix for which filter
+// returns true, a nil filter matches all objects. Objects are deleted by
+// deleteFn, by DeleteObject() if nil. deleted counts the objects deleted
+// even when an error is returned, failed deletes are returned together
+// as ListAndDeleteError once all objects were walked.
+func (fs fsObjects) ListAndDelete(ctx context.Context, bucket, prefix string, filter func(ObjectInfo) bool, deleteFn func(key string) error) (deleted int, err error) {
+	if !IsValidBucketName(bucket) {
+		return 0, traceError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !isBucketExist(fs.storage, bucket) {
+		return 0, traceError(BucketNotFound{Bucket: bucket})
+	}
+	if !IsValidObjectPrefix(prefix) {
+		return 0, traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	}
+	if deleteFn == nil {
+		deleteFn = func(key string) error {
+			return fs.DeleteObject(bucket, key)
+		}
+	}
+	isLeaf := func(bucket, object string) bool {
+		return !strings.HasSuffix(object, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, fs.storage)
+	deleted, err = listAndDelete(ctx, bucket, prefix, listDir, isLeaf, fs.getObjectInfo, filter, deleteFn)
+	if err != nil {
+		return deleted, toObjectErr(err, bucket, prefix)
+	}
+	return deleted, nil
+}
@@ -47,6 +47,8 @@ var (
 	globalDebug       = false // Debug flag set to print debug info.
 	globalDebugLock   = false // Lock debug info set via environment variable MINIO_DEBUG=lock .
 	globalDebugMemory = false // Memory debug info set via environment variable MINIO_DEBUG=mem
+
+	globalRepairDuringList = false // Repair divergent objects while heal listing, set via environment variable MINIO_REPAIR_DURING_LIST=1
 	// Add new global flags here.
 
 	// Maximum connections handled per
@@ -56,6 +58,10 @@ var (
 	globalMaxCacheSize = uint64(maxCacheSize)
 	// Cache expiry.
 	globalCacheExpiry = objcache.DefaultExpiry
+	// Time a single object listing may take, after which the objects
+	// listed so far are returned as a truncated result, zero means no
+	// deadline.
+	globalListSoftDeadline = time.Duration(0)
 	// Add new variable global values here.
 )
 
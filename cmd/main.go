@@ -73,6 +73,9 @@ func init() {
 	// Set global trace flag.
 	globalTrace = os.Getenv("MINIO_TRACE") == "1"
 
+	// Set global repair during list flag.
+	globalRepairDuringList = os.Getenv("MINIO_REPAIR_DURING_LIST") == "1"
+
 	// Set all the debug flags from ENV if any.
 	setGlobalsDebugFromEnv()
 }
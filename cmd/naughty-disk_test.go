@@ -89,6 +89,17 @@ func (d *naughtyDisk) ListDir(volume, path string) (entries []string, err error)
 	return d.disk.ListDir(volume, path)
 }
 
+func (d *naughtyDisk) ListDirBatch(volume string, paths []string) (entries [][]string, errs []error) {
+	if err := d.calcError(); err != nil {
+		errs = make([]error, len(paths))
+		for i := range errs {
+			errs[i] = err
+		}
+		return make([][]string, len(paths)), errs
+	}
+	return d.disk.ListDirBatch(volume, paths)
+}
+
 func (d *naughtyDisk) ReadFile(volume string, path string, offset int64, buf []byte) (n int64, err error) {
 	if err := d.calcError(); err != nil {
 		return 0, err
@@ -20,9 +20,11 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Wrapper for calling ListObjects tests for both XL multiple disks and single node setup.
@@ -560,6 +562,81 @@ func testListObjects(obj ObjectLayer, instanceType string, t TestErrHandler) {
 	}
 }
 
+// Wrapper for calling soft deadline ListObjects tests for both XL multiple disks and single node setup.
+func TestListObjectsSoftDeadline(t *testing.T) {
+	ExecObjectLayerTest(t, testListObjectsSoftDeadline)
+}
+
+// Tests listing with a soft deadline on a slow backend returns partial
+// results which can be resumed with NextMarker.
+func testListObjectsSoftDeadline(obj ObjectLayer, instanceType string, t TestErrHandler) {
+	bucket := "bucket"
+	if err := obj.MakeBucket(bucket); err != nil {
+		t.Fatalf("%s : %s", instanceType, err)
+	}
+	objects := []string{"a/1", "b/1", "c/1", "d/1", "e/1"}
+	for _, object := range objects {
+		_, err := obj.PutObject(bucket, object, int64(len(object)), bytes.NewBufferString(object), nil)
+		if err != nil {
+			t.Fatalf("%s : %s", instanceType, err)
+		}
+	}
+
+	// Every ListDir() on the backend takes listDirDelay.
+	listDirDelay := 50 * time.Millisecond
+	switch objLayer := obj.(type) {
+	case fsObjects:
+		objLayer.storage = slowListDirDisk{objLayer.storage, listDirDelay}
+		obj = objLayer
+	case xlObjects:
+		disks := make([]StorageAPI, len(objLayer.storageDisks))
+		for i, disk := range objLayer.storageDisks {
+			disks[i] = slowListDirDisk{disk, listDirDelay}
+		}
+		objLayer.storageDisks = disks
+		obj = objLayer
+	default:
+		t.Fatalf("%s : Unexpected object layer %T", instanceType, obj)
+	}
+	// Lists with the soft deadline set like MINIO_LIST_SOFT_DEADLINE does.
+	listObjects := func(marker string, softDeadline time.Duration) (ListObjectsInfo, error) {
+		globalListSoftDeadline = softDeadline
+		defer func() { globalListSoftDeadline = 0 }()
+		return obj.ListObjects(bucket, "", marker, "", maxObjectList)
+	}
+
+	// Walking all objects takes len(objects)+1 ListDir() calls, so the deadline expires midway.
+	result, err := listObjects("", 3*listDirDelay)
+	if err != nil {
+		t.Fatalf("%s : %s", instanceType, err)
+	}
+	if !result.IsTruncated {
+		t.Fatalf("%s : Expected a truncated result when the soft deadline expires", instanceType)
+	}
+	if len(result.Objects) >= len(objects) {
+		t.Fatalf("%s : Expected partial results, got %d objects", instanceType, len(result.Objects))
+	}
+	var listed []string
+	for _, objInfo := range result.Objects {
+		listed = append(listed, objInfo.Name)
+	}
+
+	// Resume without deadline.
+	result, err = listObjects(result.NextMarker, 0)
+	if err != nil {
+		t.Fatalf("%s : %s", instanceType, err)
+	}
+	if result.IsTruncated {
+		t.Fatalf("%s : Expected the resumed listing to complete", instanceType)
+	}
+	for _, objInfo := range result.Objects {
+		listed = append(listed, objInfo.Name)
+	}
+	if !reflect.DeepEqual(objects, listed) {
+		t.Errorf("%s : Expected %v, got %v", instanceType, objects, listed)
+	}
+}
+
 func BenchmarkListObjects(b *testing.B) {
 	// Make a temporary directory to use as the obj.
 	directory, err := ioutil.TempDir("", "minio-list-benchmark")
@@ -16,7 +16,11 @@
 
 package cmd
 
-import "io"
+import (
+	"io"
+
+	"golang.org/x/net/context"
+)
 
 // ObjectLayer implements primitives for object API layer.
 type ObjectLayer interface {
@@ -32,12 +36,15 @@ type ObjectLayer interface {
 	DeleteBucket(bucket string) error
 	ListObjects(bucket, prefix, marker, delimiter string, maxKeys int) (result ListObjectsInfo, err error)
 	ListObjectsHeal(bucket, prefix, marker, delimiter string, maxKeys int) (ListObjectsInfo, error)
+	ListObjectsPaged(bucket, prefix, token, delimiter string, limit int) (result ListObjectsInfo, nextToken string, err error)
+	EstimateListCost(bucket, prefix string, recursive bool) (ListCostEstimate, error)
 
 	// Object operations.
 	GetObject(bucket, object string, startOffset int64, length int64, writer io.Writer) (err error)
 	GetObjectInfo(bucket, object string) (objInfo ObjectInfo, err error)
 	PutObject(bucket, object string, size int64, data io.Reader, metadata map[string]string) (objInto ObjectInfo, err error)
 	DeleteObject(bucket, object string) error
+	ListAndDelete(ctx context.Context, bucket, prefix string, filter func(ObjectInfo) bool, deleteFn func(key string) error) (deleted int, err error)
 	HealObject(bucket, object string) error
 
 	// Multipart operations.
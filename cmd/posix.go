@@ -347,6 +347,40 @@ func (s *posix) DeleteVol(volume string) (err error) {
 	return nil
 }
 
+// RealDir - returns the path of directory dirPath in volume with all the
+// symlinks in it resolved, implements dirResolver. With the resolved path
+// of its parent known only a dirPath which is itself a symlink is resolved,
+// otherwise it is right beneath its parent.
+func (s *posix) RealDir(volume, dirPath, parentDir string) (string, error) {
+	volumeDir, err := s.getVolDir(volume)
+	if err != nil {
+		return "", err
+	}
+	dirFullPath := preparePath(pathJoin(volumeDir, dirPath))
+	if parentDir != "" {
+		var fi os.FileInfo
+		// Without the trailing "/" so that a symlink is not followed.
+		fi, err = os.Lstat(strings.TrimSuffix(dirFullPath, slashSeparator))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", errFileNotFound
+			}
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return filepath.Join(parentDir, slashpath.Base(dirPath)), nil
+		}
+	}
+	realPath, err := filepath.EvalSymlinks(dirFullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errFileNotFound
+		}
+		return "", err
+	}
+	return realPath, nil
+}
+
 // ListDir - return all the entries at the given directory path.
 // If an entry is a directory it will be returned with a trailing "/".
 func (s *posix) ListDir(volume, dirPath string) (entries []string, err error) {
@@ -381,6 +415,12 @@ func (s *posix) ListDir(volume, dirPath string) (entries []string, err error) {
 	return readDir(pathJoin(volumeDir, dirPath))
 }
 
+// ListDirBatch - return the entries of every directory path, a local
+// disk gains nothing from batching hence they are listed one by one.
+func (s *posix) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(s, volume, dirPaths)
+}
+
 // ReadAll reads from r until an error or EOF and returns the data it read.
 // A successful call returns err == nil, not err == EOF. Because ReadAll is
 // defined to read from src until EOF, it does not treat an EOF from Read
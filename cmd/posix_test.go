@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	slashpath "path"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -1477,3 +1478,59 @@ func TestStatFile(t *testing.T) {
 		}
 	}
 }
+
+// TestPosixRealDir - Tests only directories which are symlinks are resolved once the parent is known.
+func TestPosixRealDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need privileges on windows")
+	}
+	posixStorage, path, err := newPosixTestSetup()
+	if err != nil {
+		t.Fatalf("Unable to create posix test setup, %s", err)
+	}
+	defer removeAll(path)
+	if err = posixStorage.MakeVol("success-vol"); err != nil {
+		t.Fatalf("Unable to create volume, %s", err)
+	}
+	if err = posixStorage.AppendFile("success-vol", "a/b/x", []byte("x")); err != nil {
+		t.Fatalf("Unable to create file, %s", err)
+	}
+	volumeDir := slashpath.Join(path, "success-vol")
+	if err = os.Symlink(slashpath.Join(volumeDir, "a", "b"), slashpath.Join(volumeDir, "a", "link")); err != nil {
+		t.Fatalf("Unable to create symlink, %s", err)
+	}
+	realB, err := filepath.EvalSymlinks(slashpath.Join(volumeDir, "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		dirPath     string
+		parentDir   string
+		expected    string
+		expectedErr error
+	}{
+		// Test case - 1.
+		// Without the parent the whole path is resolved.
+		{"a/b/", "", realB, nil},
+		// Test case - 2.
+		// With the parent a directory which is not a symlink is not resolved.
+		{"a/b/", "/parent", filepath.Join("/parent", "b"), nil},
+		// Test case - 3.
+		// A symlink is resolved whatever the parent.
+		{"a/link/", "/parent", realB, nil},
+		// Test case - 4.
+		// Directory which does not exist.
+		{"a/none/", "/parent", "", errFileNotFound},
+		{"a/none/", "", "", errFileNotFound},
+	}
+	for i, testCase := range testCases {
+		realDir, err := posixStorage.(*posix).RealDir("success-vol", testCase.dirPath, testCase.parentDir)
+		if err != testCase.expectedErr {
+			t.Fatalf("Test case %d: Expected: \"%s\", got: \"%s\"", i+1, testCase.expectedErr, err)
+		}
+		if realDir != testCase.expected {
+			t.Errorf("Test case %d: Expected \"%s\", got \"%s\"", i+1, testCase.expected, realDir)
+		}
+	}
+}
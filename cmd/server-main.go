@@ -65,6 +65,9 @@ ENVIRONMENT VARIABLES:
      MINIO_CACHE_SIZE: Set total cache size in NN[GB|MB|KB]. Defaults to 8GB.
      MINIO_CACHE_EXPIRY: Set cache expiration duration in NN[h|m|s]. Defaults to 72 hours.
 
+  LISTING:
+     MINIO_LIST_SOFT_DEADLINE: Set maximum duration of an object listing in NN[h|m|s], partial results are returned once it expires. Defaults to no deadline.
+
 EXAMPLES:
   1. Start minio server.
       $ minio {{.Name}} /home/shared
@@ -171,6 +174,13 @@ func initServerConfig(c *cli.Context) {
 		fatalIf(err, "Unable to convert MINIO_CACHE_EXPIRY=%s environment variable into its time.Duration value.", cacheExpiryStr)
 	}
 
+	// Fetch list soft deadline from environment variable.
+	if listSoftDeadlineStr := os.Getenv("MINIO_LIST_SOFT_DEADLINE"); listSoftDeadlineStr != "" {
+		// We need to parse list soft deadline to its time.Duration value.
+		globalListSoftDeadline, err = time.ParseDuration(listSoftDeadlineStr)
+		fatalIf(err, "Unable to convert MINIO_LIST_SOFT_DEADLINE=%s environment variable into its time.Duration value.", listSoftDeadlineStr)
+	}
+
 	// Fetch access keys from environment variables if any and update the config.
 	accessKey := os.Getenv("MINIO_ACCESS_KEY")
 	secretKey := os.Getenv("MINIO_SECRET_KEY")
@@ -31,6 +31,7 @@ type StorageAPI interface {
 
 	// File operations.
 	ListDir(volume, dirPath string) ([]string, error)
+	ListDirBatch(volume string, dirPaths []string) ([][]string, []error)
 	ReadFile(volume string, path string, offset int64, buf []byte) (n int64, err error)
 	AppendFile(volume string, path string, buf []byte) (err error)
 	RenameFile(srcVolume, srcPath, dstVolume, dstPath string) error
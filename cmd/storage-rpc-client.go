@@ -17,6 +17,7 @@
 package cmd
 
 import (
+	"errors"
 	"io"
 	"net"
 	"net/rpc"
@@ -237,6 +238,34 @@ func (n networkStorage) ListDir(volume, path string) (entries []string, err erro
 	return entries, nil
 }
 
+// ListDirBatch - list all entries of several directories in a single call.
+func (n networkStorage) ListDirBatch(volume string, paths []string) (entries [][]string, errs []error) {
+	reply := ListDirBatchReply{}
+	errs = make([]error, len(paths))
+	if err := n.rpcClient.Call("Storage.ListDirBatchHandler", &ListDirBatchArgs{
+		Vol:   volume,
+		Paths: paths,
+	}, &reply); err != nil {
+		for i := range errs {
+			errs[i] = toStorageErr(err)
+		}
+		return make([][]string, len(paths)), errs
+	}
+	if len(reply.Entries) != len(paths) || len(reply.Errs) != len(paths) {
+		for i := range errs {
+			errs[i] = errUnexpected
+		}
+		return make([][]string, len(paths)), errs
+	}
+	for i, errStr := range reply.Errs {
+		if errStr != "" {
+			errs[i] = toStorageErr(errors.New(errStr))
+		}
+	}
+	// Return successfully unmarshalled results.
+	return reply.Entries, errs
+}
+
 // DeleteFile - Delete a file at path.
 func (n networkStorage) DeleteFile(volume, path string) (err error) {
 	reply := GenericReply{}
@@ -112,6 +112,27 @@ type ListDirArgs struct {
 	Path string
 }
 
+// ListDirBatchArgs represents list contents of several directories RPC arguments.
+type ListDirBatchArgs struct {
+	// Authentication token generated by Login.
+	GenericArgs
+
+	// Name of the volume.
+	Vol string
+
+	// Name of the paths.
+	Paths []string
+}
+
+// ListDirBatchReply represents list contents of several directories RPC reply.
+type ListDirBatchReply struct {
+	// Entries of every path, in the same order.
+	Entries [][]string
+
+	// Error of every path, empty if it was listed.
+	Errs []string
+}
+
 // RenameFileArgs represents rename file RPC arguments.
 type RenameFileArgs struct {
 	// Authentication token generated by Login.
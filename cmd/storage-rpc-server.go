@@ -139,6 +139,24 @@ func (s *storageServer) ListDirHandler(args *ListDirArgs, reply *[]string) error
 	return nil
 }
 
+// ListDirBatchHandler - list directory batch handler is rpc wrapper to
+// list several directories, errors are sent as strings since they do not
+// travel over gob as is.
+func (s *storageServer) ListDirBatchHandler(args *ListDirBatchArgs, reply *ListDirBatchReply) error {
+	if !isRPCTokenValid(args.Token) {
+		return errInvalidToken
+	}
+	entries, errs := s.storage.ListDirBatch(args.Vol, args.Paths)
+	reply.Entries = entries
+	reply.Errs = make([]string, len(errs))
+	for i, err := range errs {
+		if err != nil {
+			reply.Errs[i] = err.Error()
+		}
+	}
+	return nil
+}
+
 // ReadAllHandler - read all handler is rpc wrapper to read all storage API.
 func (s *storageServer) ReadAllHandler(args *ReadFileArgs, reply *[]byte) error {
 	if !isRPCTokenValid(args.Token) {
@@ -0,0 +1,95 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Metadata key under which the last access time of an object is tracked,
+// the value is in RFC3339Nano format.
+const accessTimeMetaKey = "X-Minio-Last-Access"
+
+// objectAccessInfo - listing result carrying the last access time of the object.
+type objectAccessInfo struct {
+	ObjectInfo
+	// Last access time, zero if the object has no access time metadata.
+	accessTime time.Time
+}
+
+// Returns the last access time tracked in the metadata of the object, ok
+// is false if it is not tracked or unparseable.
+func objectAccessTime(info ObjectInfo) (accessTime time.Time, ok bool) {
+	value, ok := info.UserDefined[accessTimeMetaKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	accessTime, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return accessTime, true
+}
+
+// byLastAccess - returns a filter matching objects last accessed before
+// cutoff, i.e. cold objects. Objects without access time metadata match
+// if matchUntracked is set.
+func byLastAccess(cutoff time.Time, matchUntracked bool) func(ObjectInfo) bool {
+	return func(info ObjectInfo) bool {
+		accessTime, ok := objectAccessTime(info)
+		if !ok {
+			return matchUntracked
+		}
+		return accessTime.Before(cutoff)
+	}
+}
+
+// listObjectsAccessTime - walks all objects under prefix and calls fn with
+// the last access time of every object for which filter returns true, a
+// nil filter matches all objects. Walking stops at the first error
+// returned by fn.
+func listObjectsAccessTime(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc, filter func(ObjectInfo) bool, fn func(objectAccessInfo) error) error {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				return nil
+			}
+			return walkResult.err
+		}
+		info, err := objInfo(bucket, walkResult.entry)
+		if err != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(err) == errFileNotFound {
+				continue
+			}
+			return err
+		}
+		if filter != nil && !filter(info) {
+			continue
+		}
+		accessTime, _ := objectAccessTime(info)
+		if err = fn(objectAccessInfo{ObjectInfo: info, accessTime: accessTime}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
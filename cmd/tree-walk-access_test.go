@@ -0,0 +1,78 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test listing cold objects filtered by their last access time.
+func TestListObjectsAccessTime(t *testing.T) {
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b", "c/"},
+		"c/": {"d", "e"},
+	}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+
+	now := time.Now().UTC()
+	accessTimes := map[string]time.Time{
+		"a":   now.Add(-48 * time.Hour),
+		"b":   now.Add(-time.Hour),
+		"c/d": now.Add(-72 * time.Hour),
+		// "c/e" has no access time.
+	}
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		info := ObjectInfo{Bucket: bucket, Name: object, UserDefined: map[string]string{}}
+		if accessTime, ok := accessTimes[object]; ok {
+			info.UserDefined[accessTimeMetaKey] = accessTime.Format(time.RFC3339Nano)
+		}
+		return info, nil
+	}
+
+	cutoff := now.Add(-24 * time.Hour)
+	testCases := []struct {
+		filter   func(ObjectInfo) bool
+		expected []string
+	}{
+		{nil, []string{"a", "b", "c/d", "c/e"}},
+		{byLastAccess(cutoff, false), []string{"a", "c/d"}},
+		{byLastAccess(cutoff, true), []string{"a", "c/d", "c/e"}},
+		{byLastAccess(now.Add(-96*time.Hour), false), nil},
+	}
+	for i, testCase := range testCases {
+		var got []string
+		err := listObjectsAccessTime(volume, "", listDir, isLeaf, objInfo, testCase.filter, func(info objectAccessInfo) error {
+			if !info.accessTime.Equal(accessTimes[info.Name]) {
+				t.Errorf("Test %d: Expected access time %s for %s, got %s", i+1, accessTimes[info.Name], info.Name, info.accessTime)
+			}
+			got = append(got, info.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// prefixAggregate - number of objects and their total size beneath a
+// common prefix.
+type prefixAggregate struct {
+	prefix string
+	count  int64
+	size   int64
+}
+
+// aggregatePrefixes - walks all objects under prefix recursively and calls
+// fn with the aggregate of every common prefix directly beneath prefix,
+// i.e. "storage used per folder". Objects directly beneath prefix are
+// reported as a group of their own keyed by their name.
+//
+// Since the walk is sorted all the objects of a group are walked one after
+// another, only the current group is held in memory and it is passed to
+// fn as soon as the walk leaves it.
+func aggregatePrefixes(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc, fn func(prefixAggregate) error) error {
+	// Prefixes are cleaned like the prefixes of walks are, so that walked
+	// entries always start with prefixDir.
+	prefix, err := normalizeWalkPrefix(bucket, prefix)
+	if err != nil {
+		return err
+	}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+
+	// Directory part of prefix, groups are the entries beneath it.
+	prefixDir := prefix[:strings.LastIndex(prefix, slashSeparator)+1]
+	var group *prefixAggregate
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return walkResult.err
+		}
+		info, err := objInfo(bucket, walkResult.entry)
+		if err != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(err) == errFileNotFound {
+				continue
+			}
+			return err
+		}
+		groupPrefix := walkResult.entry
+		if i := strings.Index(walkResult.entry[len(prefixDir):], slashSeparator); i != -1 {
+			groupPrefix = walkResult.entry[:len(prefixDir)+i+1]
+		}
+		if group != nil && group.prefix != groupPrefix {
+			if err = fn(*group); err != nil {
+				return err
+			}
+			group = nil
+		}
+		if group == nil {
+			group = &prefixAggregate{prefix: groupPrefix}
+		}
+		group.count++
+		group.size += info.Size
+	}
+	if group != nil {
+		return fn(*group)
+	}
+	return nil
+}
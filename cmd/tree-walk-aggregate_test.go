@@ -0,0 +1,100 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test aggregates per common prefix match brute force sums.
+func TestAggregatePrefixes(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+	var files = []string{
+		"a",
+		"b/c",
+		"b/d/e",
+		"b/d/f",
+		"bc",
+		"g/h/i/j",
+		"g/k",
+	}
+	if err = createNamespace(disk, volume, files); err != nil {
+		t.Fatal(err)
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	// Size of every object is the length of its name.
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object, Size: int64(len(object))}, nil
+	}
+
+	// Brute force aggregate of files beneath prefix.
+	bruteForce := func(prefix string) prefixAggregate {
+		agg := prefixAggregate{prefix: prefix}
+		for _, file := range files {
+			if strings.HasPrefix(file, prefix) {
+				agg.count++
+				agg.size += int64(len(file))
+			}
+		}
+		return agg
+	}
+
+	testCases := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"", []string{"a", "b/", "bc", "g/"}},
+		{"b", []string{"b/", "bc"}},
+		{"b/", []string{"b/c", "b/d/"}},
+		{"g/h/", []string{"g/h/i/"}},
+		{"x/", nil},
+		// Prefixes are cleaned like those of walks.
+		{"b//", []string{"b/c", "b/d/"}},
+		{"./g//////h/", []string{"g/h/i/"}},
+	}
+	for i, testCase := range testCases {
+		var got []prefixAggregate
+		err = aggregatePrefixes(volume, testCase.prefix, listDir, isLeaf, objInfo, func(agg prefixAggregate) error {
+			got = append(got, agg)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		var expected []prefixAggregate
+		for _, groupPrefix := range testCase.expected {
+			expected = append(expected, bruteForce(groupPrefix))
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, expected, got)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+	"sync"
+)
+
+// Maximum number of directories listDirFactory() prefetches in a single
+// disk.ListDirBatch() call.
+const listDirBatchSize = 16
+
+// listDirEach - lists dirPaths on disk with a ListDir() call per directory,
+// for disks which gain nothing from implementing ListDirBatch() natively.
+func listDirEach(disk StorageAPI, volume string, dirPaths []string) ([][]string, []error) {
+	entries := make([][]string, len(dirPaths))
+	errs := make([]error, len(dirPaths))
+	for i, dirPath := range dirPaths {
+		entries[i], errs[i] = disk.ListDir(volume, dirPath)
+	}
+	return entries, errs
+}
+
+// listDirPrefetcher - prefetches the listings of sibling directories. Once
+// a walk descends into a sub-directory, it and the sub-directories after it
+// are listed in a single disk.ListDirBatch() round trip, since a walk which
+// descends into one of them descends into the others as well. Non recursive
+// walks never descend hence never prefetch.
+type listDirPrefetcher struct {
+	mutex *sync.Mutex
+	// Sub-directories of a listed directory not prefetched yet, in walk order.
+	subDirs map[listDirCacheKey][]string
+	// Prefetched listings, each handed out once hence without copying.
+	listings map[listDirCacheKey][]string
+}
+
+// newListDirPrefetcher - initialize a prefetcher without any listing.
+func newListDirPrefetcher() *listDirPrefetcher {
+	return &listDirPrefetcher{
+		mutex:    &sync.Mutex{},
+		subDirs:  make(map[listDirCacheKey][]string),
+		listings: make(map[listDirCacheKey][]string),
+	}
+}
+
+// listed - records the sub-directories among the sorted entries of prefixDir
+// which a walk may descend into. Entries ending in "/" may be objects, they
+// are recorded all the same.
+func (p *listDirPrefetcher) listed(bucket, prefixDir string, entries []string) {
+	var subDirs []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, slashSeparator) {
+			subDirs = append(subDirs, pathJoin(prefixDir, entry))
+		}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := listDirCacheKey{bucket, prefixDir}
+	if len(subDirs) == 0 {
+		delete(p.subDirs, key)
+		return
+	}
+	p.subDirs[key] = subDirs
+}
+
+// take - returns the prefetched entries of prefixDir, otherwise lists it
+// along with the sub-directories after it in its parent from the first disk
+// replying, ok is false if prefixDir could not be listed that way. Callers
+// then list it the regular way, which takes care of disk errors.
+func (p *listDirPrefetcher) take(bucket, prefixDir string, disks []StorageAPI) (entries []string, ok bool) {
+	key := listDirCacheKey{bucket, prefixDir}
+	p.mutex.Lock()
+	if entries, ok = p.listings[key]; ok {
+		delete(p.listings, key)
+		p.mutex.Unlock()
+		return entries, true
+	}
+	parentDir := ""
+	if i := strings.LastIndex(strings.TrimSuffix(prefixDir, slashSeparator), slashSeparator); i >= 0 {
+		parentDir = prefixDir[:i+1]
+	}
+	parentKey := listDirCacheKey{bucket, parentDir}
+	siblings := p.subDirs[parentKey]
+	index := -1
+	for i, subDir := range siblings {
+		if subDir == prefixDir {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		p.mutex.Unlock()
+		return nil, false
+	}
+	batch := siblings[index:]
+	if len(batch) > listDirBatchSize {
+		batch = batch[:listDirBatchSize]
+	}
+	if rest := siblings[index+len(batch):]; len(rest) > 0 {
+		p.subDirs[parentKey] = rest
+	} else {
+		delete(p.subDirs, parentKey)
+	}
+	p.mutex.Unlock()
+
+	batchEntries, errs, ok := listDirBatchAnyDisk(bucket, batch, disks)
+	if !ok {
+		return nil, false
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i := 1; i < len(batch); i++ {
+		if errs[i] == nil {
+			p.listings[listDirCacheKey{bucket, batch[i]}] = batchEntries[i]
+		}
+	}
+	return batchEntries[0], true
+}
+
+// listDirBatchAnyDisk - lists dirPaths on all disks concurrently and returns
+// the listings of the first disk which lists dirPaths[0], the disks still
+// listing are not waited for. ok is false if no disk could list it.
+func listDirBatchAnyDisk(bucket string, dirPaths []string, disks []StorageAPI) (entries [][]string, errs []error, ok bool) {
+	type listDirBatchReply struct {
+		entries [][]string
+		errs    []error
+	}
+	// Buffered so that listings finishing after a reply was picked never block.
+	replyCh := make(chan listDirBatchReply, len(disks))
+	pending := 0
+	for _, disk := range disks {
+		if disk == nil {
+			continue
+		}
+		pending++
+		go func(disk StorageAPI) {
+			entries, errs := disk.ListDirBatch(bucket, dirPaths)
+			replyCh <- listDirBatchReply{entries, errs}
+		}(disk)
+	}
+	for ; pending > 0; pending-- {
+		reply := <-replyCh
+		if len(reply.entries) == len(dirPaths) && len(reply.errs) == len(dirPaths) && reply.errs[0] == nil {
+			return reply.entries, reply.errs, true
+		}
+	}
+	return nil, nil, false
+}
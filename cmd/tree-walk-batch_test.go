@@ -0,0 +1,159 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// batchListDirDisk - countingListDirDisk which lists several directories
+// in a single ListDirBatch() call, failing to list failDir in a batch.
+type batchListDirDisk struct {
+	countingListDirDisk
+	batches [][]string
+	failDir string
+}
+
+func (d *batchListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	d.batches = append(d.batches, dirPaths)
+	entries, errs := listDirEach(&d.listDirDisk, volume, dirPaths)
+	for i, dirPath := range dirPaths {
+		if dirPath == d.failDir {
+			entries[i], errs[i] = nil, errFaultyDisk
+		}
+	}
+	return entries, errs
+}
+
+// Test listing several directories a directory at a time.
+func TestListDirEach(t *testing.T) {
+	disk := &countingListDirDisk{listDirDisk: listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "b/", "x"},
+		"a/": {"c/", "y"},
+		"b/": {"w"},
+	}}}
+	entries, errs := listDirEach(disk, volume, []string{"a/", "d/", "b/"})
+	expectedEntries := [][]string{{"c/", "y"}, nil, {"w"}}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	expectedErrs := []error{nil, errFileNotFound, nil}
+	if !reflect.DeepEqual(errs, expectedErrs) {
+		t.Errorf("Expected %v, got %v", expectedErrs, errs)
+	}
+	if disk.calls != 3 {
+		t.Errorf("Expected 3 ListDir calls, got %d", disk.calls)
+	}
+}
+
+// Test recursive walks over listDirFactory() list the sub-directories of
+// every directory walked into in a single round trip, while non recursive
+// walks never prefetch.
+func TestListDirPrefetch(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":     {"a/", "b/", "e/", "x"},
+		"a/":   {"c/", "d/", "y"},
+		"a/c/": {"z"},
+		"a/d/": {"v"},
+		"b/":   {"w"},
+		"e/":   {"u"},
+	}
+	testCases := []struct {
+		prefix          string
+		marker          string
+		recursive       bool
+		expected        []string
+		expectedCalls   int
+		expectedBatches [][]string
+	}{
+		// The root is listed on its own, "a/" along with "b/" and "e/", "a/c/" along with "a/d/".
+		{"", "", true, []string{"a/c/z", "a/d/v", "a/y", "b/w", "e/u", "x"}, 1, [][]string{{"a/", "b/", "e/"}, {"a/c/", "a/d/"}}},
+		// Only the sub-directories after the marker are prefetched.
+		{"", "b/w", true, []string{"e/u", "x"}, 1, [][]string{{"b/", "e/"}}},
+		// Sub-directories filtered out by the prefix are not prefetched.
+		{"a/", "", true, []string{"a/c/z", "a/d/v", "a/y"}, 1, [][]string{{"a/c/", "a/d/"}}},
+		// Non recursive walks never descend hence never prefetch.
+		{"", "", false, []string{"a/", "b/", "e/", "x"}, 1, nil},
+	}
+	for i, testCase := range testCases {
+		disk := &batchListDirDisk{countingListDirDisk: countingListDirDisk{listDirDisk: listDirDisk{dirs: dirs}}}
+		listDir := listDirFactory(isLeaf, disk)
+		endWalkCh := make(chan struct{})
+		var entries []string
+		for res := range startTreeWalk(context.Background(), volume, testCase.prefix, testCase.marker, testCase.recursive, listDir, isLeaf, endWalkCh) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if disk.calls != testCase.expectedCalls {
+			t.Errorf("Test %d: Expected %d ListDir calls, got %d", i+1, testCase.expectedCalls, disk.calls)
+		}
+		if !reflect.DeepEqual(disk.batches, testCase.expectedBatches) {
+			t.Errorf("Test %d: Expected batches %v, got %v", i+1, testCase.expectedBatches, disk.batches)
+		}
+	}
+}
+
+// Test directories failing to list in a batch are listed on their own,
+// as are batches of more than listDirBatchSize directories.
+func TestListDirPrefetchFallback(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{"": nil}
+	var expected []string
+	for i := 0; i < listDirBatchSize+2; i++ {
+		dir := string(rune('a'+i)) + "/"
+		dirs[""] = append(dirs[""], dir)
+		dirs[dir] = []string{"x"}
+		expected = append(expected, dir+"x")
+	}
+	disk := &batchListDirDisk{
+		countingListDirDisk: countingListDirDisk{listDirDisk: listDirDisk{dirs: dirs}},
+		failDir:             "b/",
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	var entries []string
+	for res := range startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, nil) {
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		entries = append(entries, res.entry)
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+	// The root and the directory which failed are listed on their own.
+	if disk.calls != 2 {
+		t.Errorf("Expected 2 ListDir calls, got %d", disk.calls)
+	}
+	if len(disk.batches) != 2 || len(disk.batches[0]) != listDirBatchSize || len(disk.batches[1]) != 2 {
+		t.Errorf("Expected batches of %d and 2 directories, got %v", listDirBatchSize, disk.batches)
+	}
+}
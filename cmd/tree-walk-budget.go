@@ -0,0 +1,194 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/net/context"
+)
+
+// errWalkMemoryBudget - walk buffered more than its memory budget allows.
+var errWalkMemoryBudget = errors.New("Walk exceeded its memory budget")
+
+// Approximate memory held by a buffered key besides its bytes.
+const walkBufferEntryOverhead = 16
+
+// Approximate memory held by a buffered ObjectInfo besides its strings.
+const walkObjectInfoOverhead = 128
+
+// walkMemoryBudget - bounds the memory a single walk buffers results in.
+type walkMemoryBudget struct {
+	maxBytes int64 // Zero means unbounded.
+	// When set, keys buffered in a walkBuffer are spilled to a temporary
+	// file in spillDir (the default temporary directory if empty) once
+	// maxBytes is reached, otherwise buffering fails with
+	// errWalkMemoryBudget. Walks holding their results in memory in any
+	// other form always fail.
+	spill    bool
+	spillDir string
+}
+
+// allows - returns true if size bytes fit in the budget.
+func (b walkMemoryBudget) allows(size int64) bool {
+	return b.maxBytes <= 0 || size <= b.maxBytes
+}
+
+// Returns the approximate memory held by a buffered ObjectInfo.
+func objectInfoBytes(info ObjectInfo) int64 {
+	size := len(info.Bucket) + len(info.Name) + len(info.MD5Sum) + len(info.ContentType) + len(info.ContentEncoding)
+	for k, v := range info.UserDefined {
+		size += len(k) + len(v)
+	}
+	return int64(size) + walkObjectInfoOverhead
+}
+
+// walkBuffer - keys buffered by a walk within a memory budget. Keys are
+// held in memory until the budget is reached and then either spilled
+// to disk or refused. Close() must be called to remove spilled keys.
+type walkBuffer struct {
+	budget   walkMemoryBudget
+	keys     []string
+	keyBytes int64
+
+	spillFile    *os.File
+	spillWriter  *bufio.Writer
+	spillEncoder *json.Encoder
+	spilled      int
+}
+
+// Len - returns the number of keys buffered.
+func (b *walkBuffer) Len() int {
+	return b.spilled + len(b.keys)
+}
+
+// add - buffers key.
+func (b *walkBuffer) add(key string) error {
+	size := int64(len(key)) + walkBufferEntryOverhead
+	if !b.budget.allows(b.keyBytes + size) {
+		if !b.budget.spill {
+			return traceError(errWalkMemoryBudget)
+		}
+		if err := b.spillKeys(); err != nil {
+			return err
+		}
+		// A key larger than the whole budget is spilled right away.
+		if !b.budget.allows(size) {
+			b.keys = []string{key}
+			return b.spillKeys()
+		}
+	}
+	b.keys = append(b.keys, key)
+	b.keyBytes += size
+	return nil
+}
+
+// Moves the keys held in memory to the spill file.
+func (b *walkBuffer) spillKeys() error {
+	if b.spillFile == nil {
+		spillFile, err := ioutil.TempFile(b.budget.spillDir, "minio-walk-")
+		if err != nil {
+			return traceError(err)
+		}
+		b.spillFile = spillFile
+		b.spillWriter = bufio.NewWriter(spillFile)
+		// Keys are JSON encoded as they may hold new lines.
+		b.spillEncoder = json.NewEncoder(b.spillWriter)
+	}
+	for _, key := range b.keys {
+		if err := b.spillEncoder.Encode(key); err != nil {
+			return traceError(err)
+		}
+	}
+	b.spilled += len(b.keys)
+	b.keys = nil
+	b.keyBytes = 0
+	return nil
+}
+
+// forEach - calls fn with every buffered key in the order they were added.
+func (b *walkBuffer) forEach(fn func(key string) error) error {
+	if b.spillFile != nil {
+		if err := b.spillWriter.Flush(); err != nil {
+			return traceError(err)
+		}
+		if _, err := b.spillFile.Seek(0, 0); err != nil {
+			return traceError(err)
+		}
+		decoder := json.NewDecoder(bufio.NewReader(b.spillFile))
+		for {
+			var key string
+			if err := decoder.Decode(&key); err == io.EOF {
+				break
+			} else if err != nil {
+				return traceError(err)
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		// Position at the end for further spills.
+		if _, err := b.spillFile.Seek(0, 2); err != nil {
+			return traceError(err)
+		}
+	}
+	for _, key := range b.keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - removes the spill file if any.
+func (b *walkBuffer) Close() error {
+	if b.spillFile == nil {
+		return nil
+	}
+	b.spillFile.Close()
+	err := os.Remove(b.spillFile.Name())
+	b.spillFile = nil
+	return err
+}
+
+// listBuffered - walks prefix and buffers all the keys within budget, for
+// consumers which need the whole listing before they can process it.
+func listBuffered(bucket, prefix string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, budget walkMemoryBudget) (*walkBuffer, error) {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	buffer := &walkBuffer{budget: budget}
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", recursive, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			buffer.Close()
+			return nil, walkResult.err
+		}
+		if err := buffer.add(walkResult.entry); err != nil {
+			buffer.Close()
+			return nil, err
+		}
+	}
+	return buffer, nil
+}
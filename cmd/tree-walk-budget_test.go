@@ -0,0 +1,95 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test buffering past the memory budget spills or fails as configured.
+func TestListBuffered(t *testing.T) {
+	dirs := map[string][]string{"": {"dir/", "new\nline"}}
+	for i := 0; i < 100; i++ {
+		dirs["dir/"] = append(dirs["dir/"], fmt.Sprintf("obj%03d", i))
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: dirs})
+	expected := walkEntries("", "", true, listDir, isLeaf)
+
+	spillDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(spillDir)
+
+	testCases := []struct {
+		budget        walkMemoryBudget
+		expectedSpill bool
+		expectedErr   error
+	}{
+		// Unbounded.
+		{walkMemoryBudget{}, false, nil},
+		// Within budget.
+		{walkMemoryBudget{maxBytes: 1 << 20}, false, nil},
+		// Past the budget.
+		{walkMemoryBudget{maxBytes: 256, spill: true, spillDir: spillDir}, true, nil},
+		// Keys larger than the whole budget are spilled as well.
+		{walkMemoryBudget{maxBytes: 8, spill: true, spillDir: spillDir}, true, nil},
+		{walkMemoryBudget{maxBytes: 256}, false, errWalkMemoryBudget},
+	}
+	for i, testCase := range testCases {
+		buffer, err := listBuffered(volume, "", true, listDir, isLeaf, testCase.budget)
+		if errorCause(err) != testCase.expectedErr {
+			t.Fatalf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if (buffer.spilled > 0) != testCase.expectedSpill {
+			t.Errorf("Test %d: Expected spill %v, spilled %d keys", i+1, testCase.expectedSpill, buffer.spilled)
+		}
+		if buffer.keyBytes > testCase.budget.maxBytes && testCase.budget.maxBytes > 0 {
+			t.Errorf("Test %d: Expected at most %d bytes in memory, got %d", i+1, testCase.budget.maxBytes, buffer.keyBytes)
+		}
+		if buffer.Len() != len(expected) {
+			t.Errorf("Test %d: Expected %d keys, got %d", i+1, len(expected), buffer.Len())
+		}
+		var got []string
+		if err = buffer.forEach(func(key string) error {
+			got = append(got, key)
+			return nil
+		}); err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, expected, got)
+		}
+		if err = buffer.Close(); err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+	}
+	// Spill files are removed on Close().
+	if names, _ := ioutil.ReadDir(spillDir); len(names) != 0 {
+		t.Errorf("Expected spill files to be removed, found %d", len(names))
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listDirCacheKey - directory whose listing is cached.
+type listDirCacheKey struct {
+	bucket    string
+	prefixDir string
+}
+
+// listDirCacheEntry - cached disk.ListDir() result of a directory.
+type listDirCacheEntry struct {
+	key      listDirCacheKey
+	entries  []string
+	cachedAt time.Time
+}
+
+// listDirCache - LRU cache of disk.ListDir() results, consulted by
+// listDirFactoryWithOpts() before listing the disks. Upto maxEntries
+// directories are cached, each for at most ttl. Writes to a bucket should
+// call Invalidate() so that listings reflect them right away.
+type listDirCache struct {
+	maxEntries int
+	ttl        time.Duration // Zero means entries never expire.
+
+	mutex   *sync.Mutex
+	lru     *list.List // Most recently used entry at the front.
+	entries map[listDirCacheKey]*list.Element
+}
+
+// newListDirCache - initialize a cache of maxEntries directories.
+func newListDirCache(maxEntries int, ttl time.Duration) *listDirCache {
+	return &listDirCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		mutex:      &sync.Mutex{},
+		lru:        list.New(),
+		entries:    make(map[listDirCacheKey]*list.Element),
+	}
+}
+
+// get - returns a copy of the cached entries of prefixDir, ok is false
+// if they are not cached or expired.
+func (c *listDirCache) get(bucket, prefixDir string) (entries []string, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.entries[listDirCacheKey{bucket, prefixDir}]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*listDirCacheEntry)
+	if c.ttl > 0 && time.Since(entry.cachedAt) > c.ttl {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	// Callers sort and modify the entries in place.
+	return append([]string(nil), entry.entries...), true
+}
+
+// set - caches a copy of the entries of prefixDir, evicting the least
+// recently used directory if the cache is full.
+func (c *listDirCache) set(bucket, prefixDir string, entries []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := listDirCacheKey{bucket, prefixDir}
+	entry := &listDirCacheEntry{
+		key:      key,
+		entries:  append([]string(nil), entries...),
+		cachedAt: time.Now(),
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove - drops elem from the cache, caller should hold the lock.
+func (c *listDirCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*listDirCacheEntry).key)
+}
+
+// Invalidate - drops the cached listings affected by a write to path,
+// i.e. those of its parent directories and of the directories beneath it,
+// so that an object or a whole prefix can be invalidated.
+func (c *listDirCache) Invalidate(bucket, path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, elem := range c.entries {
+		if key.bucket != bucket {
+			continue
+		}
+		if strings.HasPrefix(path, key.prefixDir) || strings.HasPrefix(key.prefixDir, path) {
+			c.remove(elem)
+		}
+	}
+}
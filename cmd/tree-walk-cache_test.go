@@ -0,0 +1,120 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test cached listings avoid listing the disk until they are invalidated.
+func TestListDirCache(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &countingListDirDisk{listDirDisk: listDirDisk{dirs: map[string][]string{
+		"":     {"a/", "b"},
+		"a/":   {"c/", "d"},
+		"a/c/": {"e"},
+	}}}
+	cache := newListDirCache(10, 0)
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{cache: cache}, disk)
+
+	list := func(prefixDir string, expected []string, expectedCalls int) {
+		entries, _, err := listDir(volume, prefixDir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(entries, expected) {
+			t.Errorf("%q: Expected %v, got %v", prefixDir, expected, entries)
+		}
+		if disk.calls != expectedCalls {
+			t.Errorf("%q: Expected %d disk calls, got %d", prefixDir, expectedCalls, disk.calls)
+		}
+	}
+	list("", []string{"a/", "b"}, 1)
+	list("a/", []string{"c/", "d"}, 2)
+	list("a/c/", []string{"e"}, 3)
+	// Cache hits.
+	list("", []string{"a/", "b"}, 3)
+	list("a/", []string{"c/", "d"}, 3)
+	list("a/c/", []string{"e"}, 3)
+
+	// Writing "a/f" invalidates its parents only.
+	disk.dirs["a/"] = []string{"c/", "d", "f"}
+	cache.Invalidate(volume, "a/f")
+	list("", []string{"a/", "b"}, 4)
+	list("a/", []string{"c/", "d", "f"}, 5)
+	list("a/c/", []string{"e"}, 5)
+
+	// Invalidating a prefix drops everything beneath it too.
+	cache.Invalidate(volume, "a/")
+	list("a/c/", []string{"e"}, 6)
+	list("", []string{"a/", "b"}, 7)
+
+	// Other buckets are not affected.
+	cache.Invalidate("otherbucket", "")
+	list("", []string{"a/", "b"}, 7)
+
+	// Without a cache every listing hits the disk.
+	disk.calls = 0
+	listDir = listDirFactory(isLeaf, disk)
+	list("", []string{"a/", "b"}, 1)
+	list("", []string{"a/", "b"}, 2)
+}
+
+// Test cached listings are evicted in LRU order and expire after the ttl.
+func TestListDirCacheEviction(t *testing.T) {
+	cache := newListDirCache(2, 0)
+	cache.set(volume, "a/", []string{"1"})
+	cache.set(volume, "b/", []string{"2"})
+	// "a/" becomes the most recently used, "b/" is evicted.
+	if _, ok := cache.get(volume, "a/"); !ok {
+		t.Fatal("Expected a/ to be cached")
+	}
+	cache.set(volume, "c/", []string{"3"})
+	if _, ok := cache.get(volume, "b/"); ok {
+		t.Error("Expected b/ to be evicted")
+	}
+	for _, prefixDir := range []string{"a/", "c/"} {
+		if _, ok := cache.get(volume, prefixDir); !ok {
+			t.Errorf("Expected %s to be cached", prefixDir)
+		}
+	}
+
+	// Returned entries are copies.
+	entries, _ := cache.get(volume, "a/")
+	entries[0] = "modified"
+	if entries, _ = cache.get(volume, "a/"); entries[0] != "1" {
+		t.Errorf("Expected cached entries to be unmodified, got %v", entries)
+	}
+
+	cache = newListDirCache(2, 10*time.Millisecond)
+	cache.set(volume, "a/", []string{"1"})
+	if _, ok := cache.get(volume, "a/"); !ok {
+		t.Fatal("Expected a/ to be cached")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := cache.get(volume, "a/"); ok {
+		t.Error("Expected a/ to be expired")
+	}
+	if cache.lru.Len() != 0 || len(cache.entries) != 0 {
+		t.Error("Expected expired entries to be removed")
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "strings"
+
+// Splits a media type like "text/html; charset=utf-8" into its type and
+// subtype, parameters are dropped and case is ignored.
+func splitMediaType(mediaType string) (typ, subType string, ok bool) {
+	if i := strings.Index(mediaType, ";"); i != -1 {
+		mediaType = mediaType[:i]
+	}
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(mediaType)), slashSeparator, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// byContentType - returns a filter matching objects whose Content-Type
+// matches one of patterns. Patterns are media types where either half
+// may be "*", e.g. "image/*" matches all images and "*/json" matches
+// "application/json". Objects without a Content-Type never match.
+func byContentType(patterns ...string) func(ObjectInfo) bool {
+	return func(info ObjectInfo) bool {
+		typ, subType, ok := splitMediaType(info.ContentType)
+		if !ok {
+			return false
+		}
+		for _, pattern := range patterns {
+			patternType, patternSubType, ok := splitMediaType(pattern)
+			if !ok {
+				continue
+			}
+			if (patternType == "*" || patternType == typ) && (patternSubType == "*" || patternSubType == subType) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test filtering objects by content type with wildcard patterns.
+func TestByContentType(t *testing.T) {
+	objects := []ObjectInfo{
+		{Name: "a.png", ContentType: "image/png"},
+		{Name: "b.jpg", ContentType: "IMAGE/JPEG"},
+		{Name: "c.json", ContentType: "application/json"},
+		{Name: "d.html", ContentType: "text/html; charset=utf-8"},
+		{Name: "e.txt", ContentType: "text/plain"},
+		{Name: "f"},
+		{Name: "g", ContentType: "invalid"},
+	}
+	testCases := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"image/*"}, []string{"a.png", "b.jpg"}},
+		{[]string{"image/png"}, []string{"a.png"}},
+		{[]string{"*/json"}, []string{"c.json"}},
+		{[]string{"text/html"}, []string{"d.html"}},
+		{[]string{"image/*", "text/plain"}, []string{"a.png", "b.jpg", "e.txt"}},
+		// Objects without a content type are always excluded.
+		{[]string{"*/*"}, []string{"a.png", "b.jpg", "c.json", "d.html", "e.txt"}},
+		{[]string{"video/*"}, nil},
+		{[]string{"invalid"}, nil},
+	}
+	for i, testCase := range testCases {
+		filter := byContentType(testCase.patterns...)
+		var got []string
+		for _, object := range objects {
+			if filter(object) {
+				got = append(got, object.Name)
+			}
+		}
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "golang.org/x/net/context"
+
+// CountObjects - returns the number of objects a walk of prefix would
+// send, counted by the walk itself without building their names or
+// sending them on a channel. Non-recursive walks count the objects
+// directly under prefix only.
+func CountObjects(bucket, prefix string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc) (int64, error) {
+	// Prefixes are cleaned like the prefixes of walks are.
+	prefix, err := normalizeWalkPrefix(bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	prefixDir, entryPrefixMatch := splitWalkPrefix(prefix)
+	opts := treeWalkOpts{countOnly: true}
+	// Errors are the only results of a counting walk, sent before the
+	// walk returns them.
+	resultCh := make(chan treeWalkResult, 1)
+	err = doTreeWalk(context.Background(), bucket, prefixDir, entryPrefixMatch, "", recursive, listDir, isLeaf, resultCh, true, &opts)
+	if err != nil {
+		// Nothing under prefix.
+		if errorCause(err) == errFileNotFound && opts.summary.dirs == 0 {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return opts.objectCount, nil
+}
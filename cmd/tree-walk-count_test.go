@@ -0,0 +1,93 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// Test counting objects matches the number of objects walks send.
+func TestCountObjects(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":       {"a", "b/", "bb", "c/"},
+		"b/":     {"x", "y/", "z"},
+		"b/y/":   {"1", "2"},
+		"c/":     {"d/"},
+		"c/d/":   {"e/"},
+		"c/d/e/": {"3"},
+	}})
+	testCases := []struct {
+		prefix    string
+		recursive bool
+	}{
+		{"", true},
+		{"", false},
+		{"b", true},
+		{"b", false},
+		{"b/", true},
+		{"b/", false},
+		{"c/", false},
+		{"c/d/e/", true},
+		{"d", true},
+		{"x/", true},
+		// Prefixes are cleaned like those of walks.
+		{"b//", true},
+		{"./b/y/", false},
+		{"c//d/e/", true},
+	}
+	for i, testCase := range testCases {
+		var expected int64
+		endWalkCh := make(chan struct{})
+		for res := range startTreeWalk(context.Background(), volume, testCase.prefix, "", testCase.recursive, listDir, isLeaf, endWalkCh) {
+			if res.err != nil {
+				if errorCause(res.err) == errFileNotFound {
+					break
+				}
+				t.Fatal(res.err)
+			}
+			if !res.isDir {
+				expected++
+			}
+		}
+		close(endWalkCh)
+		count, err := CountObjects(volume, testCase.prefix, testCase.recursive, listDir, isLeaf)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		if count != expected {
+			t.Errorf("Test %d: Expected %d objects, got %d", i+1, expected, count)
+		}
+	}
+	// Walk of the whole tree sends 7 objects.
+	if count, _ := CountObjects(volume, "", true, listDir, isLeaf); count != 7 {
+		t.Errorf("Expected 7 objects, got %d", count)
+	}
+	// Walk of "b//" counts the objects under "b/".
+	if count, _ := CountObjects(volume, "b//", true, listDir, isLeaf); count != 4 {
+		t.Errorf("Expected 4 objects, got %d", count)
+	}
+	// Prefixes leading outside of the bucket are invalid.
+	if _, err := CountObjects(volume, "../b/", true, listDir, isLeaf); errorCause(err) != (ObjectNameInvalid{Bucket: volume, Object: "../b/"}) {
+		t.Errorf("Expected %v, got %v", ObjectNameInvalid{Bucket: volume, Object: "../b/"}, err)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+)
+
+// Returns function "listDir" of the type listDirFunc for backends which
+// store every path component under an escaped name, e.g. with "%"
+// percent-encoded as "%25". decodeEntry maps the names listed in a
+// directory on the disk to entries and is applied before sorting and
+// filtering, so that the walk operates on keys while the backend stores
+// encoded names. encodeEntry is its inverse, it maps the directories of
+// the walk to the directories on the disk and is used to only decode
+// names which can match the prefix listed, hence it needs to preserve
+// prefixes, i.e. if a is a prefix of b then encodeEntry(a) is a prefix of
+// encodeEntry(b).
+//
+// Names which fail to decode, or which decode to more than one path
+// component, are skipped. Directories are listed with a trailing "/",
+// the matching isLeaf is one returning false for entries ending in "/".
+func listDirDecodeFactory(decodeEntry func(string) (string, error), encodeEntry func(string) string, disks ...StorageAPI) listDirFunc {
+	listDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
+		for _, disk := range disks {
+			if disk == nil {
+				continue
+			}
+			var names []string
+			names, err = disk.ListDir(bucket, encodeDir(prefixDir, encodeEntry))
+			if err == nil {
+				encodedPrefix := encodeEntry(prefixEntry)
+				for _, name := range names {
+					if !strings.HasPrefix(name, encodedPrefix) {
+						continue
+					}
+					entry, dErr := decodeEntry(strings.TrimSuffix(name, slashSeparator))
+					if dErr != nil || entry == "" || strings.Contains(entry, slashSeparator) {
+						continue
+					}
+					if strings.HasSuffix(name, slashSeparator) {
+						entry += slashSeparator
+					}
+					entries = append(entries, entry)
+				}
+				// Encoded names do not sort like entries, e.g. "%25" sorts
+				// before "-" while "%" sorts after it.
+				sort.Strings(entries)
+				return filterMatchingPrefix(entries, prefixEntry), false, nil
+			}
+			// For any reason disk was deleted or goes offline, continue
+			// and list from other disks if possible.
+			if isErrIgnored(err, walkResultIgnoredErrs) {
+				continue
+			}
+			break
+		}
+		// Return error at the end.
+		return nil, false, traceError(err)
+	}
+	return listDir
+}
+
+// encodeDir - returns the directory on the disk holding the entries of
+// prefixDir, with every path component of it encoded.
+func encodeDir(prefixDir string, encodeEntry func(string) string) string {
+	if prefixDir == "" {
+		return ""
+	}
+	components := strings.Split(strings.TrimSuffix(prefixDir, slashSeparator), slashSeparator)
+	for i, component := range components {
+		components[i] = encodeEntry(component)
+	}
+	return strings.Join(components, slashSeparator) + slashSeparator
+}
@@ -0,0 +1,68 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test walks over a backend storing path components percent-encoded,
+// including "/" in names.
+func TestListDirDecode(t *testing.T) {
+	// Keys "a-b", "a/c", "a/d/e", "f%g" and "h:i/j".
+	disk := &countingListDirDisk{listDirDisk: listDirDisk{dirs: map[string][]string{
+		// Names which are not valid encodings, or decode to a name
+		// with "/" in it, are skipped.
+		"":       {"a-b", "a/", "bad%zz", "f%25g", "h%3Ai/", "x%2Fy"},
+		"a/":     {"c", "d/"},
+		"a/d/":   {"e"},
+		"h%3Ai/": {"j"},
+	}}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirDecodeFactory(url.QueryUnescape, url.QueryEscape, disk)
+
+	testCases := []struct {
+		prefix    string
+		marker    string
+		recursive bool
+		expected  []string
+		calls     int // ListDir() calls, one per directory walked.
+	}{
+		{"", "", true, []string{"a-b", "a/c", "a/d/e", "f%g", "h:i/j"}, 4},
+		{"", "", false, []string{"a-b", "a/", "f%g", "h:i/"}, 1},
+		{"a/", "", false, []string{"a/c", "a/d/"}, 1},
+		{"a", "", true, []string{"a-b", "a/c", "a/d/e"}, 3},
+		{"", "a/c", true, []string{"a/d/e", "f%g", "h:i/j"}, 4},
+		{"f%", "", true, []string{"f%g"}, 1},
+		{"h:i/", "", true, []string{"h:i/j"}, 1},
+	}
+	for i, testCase := range testCases {
+		disk.calls = 0
+		got := walkEntries(testCase.prefix, testCase.marker, testCase.recursive, listDir, isLeaf)
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+		if disk.calls != testCase.calls {
+			t.Errorf("Test %d: Expected %d ListDir calls, got %d", i+1, testCase.calls, disk.calls)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Maximum number of deletes listAndDelete() runs concurrently.
+const listAndDeleteConcurrency = 16
+
+// ListAndDeleteError - keys which listAndDelete() failed to delete along
+// with the error returned for each of them.
+type ListAndDeleteError struct {
+	Errs map[string]error
+}
+
+func (e ListAndDeleteError) Error() string {
+	return fmt.Sprintf("Failed to delete %d objects", len(e.Errs))
+}
+
+// listAndDelete - walks all objects under prefix and calls deleteFn for
+// every object for which filter returns true, a nil filter matches all
+// objects. Up to listAndDeleteConcurrency deletes run concurrently.
+//
+// deleted is the number of objects deleted so far even when an error is
+// returned. Failed deletes do not stop the walk, they are returned
+// together as ListAndDeleteError once the walk is done.
+func listAndDelete(ctx context.Context, bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc, filter func(ObjectInfo) bool, deleteFn func(key string) error) (deleted int, err error) {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	walkResultCh := startTreeWalk(ctx, bucket, prefix, "", true, listDir, isLeaf, endWalkCh)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex // Protects deleted and keyErrs.
+	keyErrs := make(map[string]error)
+	deleteCh := make(chan struct{}, listAndDeleteConcurrency)
+
+walk:
+	for {
+		// Check for cancellation first, a ready walk result must not win over it.
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var walkResult treeWalkResult
+		var ok bool
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break walk
+		case walkResult, ok = <-walkResultCh:
+		}
+		if !ok {
+			break
+		}
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) != errFileNotFound {
+				err = walkResult.err
+			}
+			break
+		}
+		info, oErr := objInfo(bucket, walkResult.entry)
+		if oErr != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(oErr) == errFileNotFound {
+				continue
+			}
+			err = oErr
+			break
+		}
+		if filter != nil && !filter(info) {
+			continue
+		}
+
+		// Wait for a free delete slot.
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break walk
+		case deleteCh <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			dErr := deleteFn(key)
+			<-deleteCh
+			mu.Lock()
+			if dErr != nil {
+				keyErrs[key] = dErr
+			} else {
+				deleted++
+			}
+			mu.Unlock()
+		}(info.Name)
+	}
+
+	// Wait for the deletes in progress.
+	wg.Wait()
+	if err == nil && len(keyErrs) > 0 {
+		err = ListAndDeleteError{Errs: keyErrs}
+	}
+	return deleted, err
+}
@@ -0,0 +1,163 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// Test listAndDelete with a filter and a deleteFn failing for some keys.
+func TestListAndDelete(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+	var files = []string{
+		"logs/a.tmp",
+		"logs/b.log",
+		"logs/c/d.tmp",
+		"logs/c/e.tmp",
+		"other/f.tmp",
+	}
+	if err = createNamespace(disk, volume, files); err != nil {
+		t.Fatal(err)
+	}
+
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object}, nil
+	}
+	filter := func(info ObjectInfo) bool {
+		return strings.HasSuffix(info.Name, ".tmp")
+	}
+
+	errDeleteFailed := errors.New("delete failed")
+	var mu sync.Mutex
+	var deletedKeys []string
+	deleteFn := func(key string) error {
+		if key == "logs/c/d.tmp" {
+			return errDeleteFailed
+		}
+		mu.Lock()
+		deletedKeys = append(deletedKeys, key)
+		mu.Unlock()
+		return nil
+	}
+
+	deleted, err := listAndDelete(context.Background(), volume, "logs/", listDir, isLeaf, objInfo, filter, deleteFn)
+	if deleted != 2 {
+		t.Errorf("Expected 2 objects to be deleted, got %d", deleted)
+	}
+	sort.Strings(deletedKeys)
+	if !reflect.DeepEqual(deletedKeys, []string{"logs/a.tmp", "logs/c/e.tmp"}) {
+		t.Errorf("Unexpected deleted keys %v", deletedKeys)
+	}
+	deleteErr, ok := err.(ListAndDeleteError)
+	if !ok {
+		t.Fatalf("Expected ListAndDeleteError, got %v", err)
+	}
+	if !reflect.DeepEqual(deleteErr.Errs, map[string]error{"logs/c/d.tmp": errDeleteFailed}) {
+		t.Errorf("Unexpected per-key errors %v", deleteErr.Errs)
+	}
+}
+
+// Test listAndDelete stops deleting once cancelled.
+func TestListAndDeleteCancel(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"": {"a", "b", "c", "d"},
+	}})
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object}, nil
+	}
+	deleteFn := func(key string) error {
+		t.Errorf("Unexpected delete of %s after cancel", key)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	deleted, err := listAndDelete(ctx, volume, "", listDir, isLeaf, objInfo, nil, deleteFn)
+	if err != context.Canceled {
+		t.Fatalf("Expected %s, got %v", context.Canceled, err)
+	}
+	if deleted != 0 {
+		t.Errorf("Expected no objects to be deleted, got %d", deleted)
+	}
+}
+
+// Test ListAndDelete on both backends.
+func TestObjectLayerListAndDelete(t *testing.T) {
+	ExecObjectLayerTest(t, testObjectLayerListAndDelete)
+}
+
+func testObjectLayerListAndDelete(obj ObjectLayer, instanceType string, t TestErrHandler) {
+	bucket := "bucket"
+	if err := obj.MakeBucket(bucket); err != nil {
+		t.Fatalf("%s: %s", instanceType, err)
+	}
+	for _, object := range []string{"logs/a.tmp", "logs/b.log", "logs/c/d.tmp", "other/e.tmp"} {
+		if _, err := obj.PutObject(bucket, object, 1, bytes.NewBufferString("x"), nil); err != nil {
+			t.Fatalf("%s: %s", instanceType, err)
+		}
+	}
+	filter := func(info ObjectInfo) bool {
+		return strings.HasSuffix(info.Name, ".tmp")
+	}
+	// Objects are deleted by DeleteObject() without deleteFn.
+	deleted, err := obj.ListAndDelete(context.Background(), bucket, "logs/", filter, nil)
+	if err != nil {
+		t.Fatalf("%s: %s", instanceType, err)
+	}
+	if deleted != 2 {
+		t.Errorf("%s: Expected 2 objects to be deleted, got %d", instanceType, deleted)
+	}
+	result, err := obj.ListObjects(bucket, "", "", "", 1000)
+	if err != nil {
+		t.Fatalf("%s: %s", instanceType, err)
+	}
+	var remaining []string
+	for _, objInfo := range result.Objects {
+		remaining = append(remaining, objInfo.Name)
+	}
+	if !reflect.DeepEqual(remaining, []string{"logs/b.log", "other/e.tmp"}) {
+		t.Errorf("%s: Unexpected remaining objects %v", instanceType, remaining)
+	}
+
+	if _, err = obj.ListAndDelete(context.Background(), "missing-bucket", "", nil, nil); errorCause(err) != (BucketNotFound{Bucket: "missing-bucket"}) {
+		t.Errorf("%s: Expected %s, got %v", instanceType, BucketNotFound{Bucket: "missing-bucket"}, err)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// Number of random probes EstimateListCost() samples the tree with.
+const listCostProbes = 32
+
+// ListCostEstimate - projected cost of a listing.
+type ListCostEstimate struct {
+	// Projected number of directories a full walk lists.
+	Dirs int64
+	// Projected number of entries, objects and directories, a full walk touches.
+	Entries int64
+	// Number of directories actually listed to make the projection.
+	SampledDirs int64
+}
+
+// estimateListCost - projects the cost of walking prefix without walking
+// all of it. Non recursive listings only list a single directory and are
+// hence exact. For recursive listings every probe walks down a random
+// path from the top of the tree multiplying the number of sub-directories
+// seen at each level, the average over all probes is an unbiased estimate
+// of the size of the tree (Knuth's estimator). Directory listings are
+// cached across probes so the top of the tree is only listed once.
+func estimateListCost(bucket, prefix string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, probes int, seed int64) (ListCostEstimate, error) {
+	// Prefixes are cleaned like the prefixes of walks are.
+	prefix, err := normalizeWalkPrefix(bucket, prefix)
+	if err != nil {
+		return ListCostEstimate{}, err
+	}
+	prefixDir, entryPrefixMatch := splitWalkPrefix(prefix)
+
+	type dirListing struct {
+		entries int64
+		subDirs []string
+	}
+	var estimate ListCostEstimate
+	listings := make(map[string]dirListing)
+	list := func(dir, entryPrefix string) (dirListing, error) {
+		if listing, ok := listings[dir]; ok {
+			return listing, nil
+		}
+		entries, delayIsLeaf, err := listDir(bucket, dir, entryPrefix)
+		if err != nil && errorCause(err) != errFileNotFound {
+			return dirListing{}, err
+		}
+		estimate.SampledDirs++
+		listing := dirListing{entries: int64(len(entries))}
+		for _, entry := range entries {
+			if delayIsLeaf && isLeaf(bucket, pathJoin(dir, entry)) {
+				continue
+			}
+			if strings.HasSuffix(entry, slashSeparator) {
+				listing.subDirs = append(listing.subDirs, pathJoin(dir, entry))
+			}
+		}
+		listings[dir] = listing
+		return listing, nil
+	}
+
+	root, err := list(prefixDir, entryPrefixMatch)
+	if err != nil {
+		return ListCostEstimate{}, err
+	}
+	if !recursive {
+		estimate.Dirs = 1
+		estimate.Entries = root.entries
+		return estimate, nil
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	var dirsSum, entriesSum float64
+	for i := 0; i < probes; i++ {
+		// Number of directories at the current level if all of them
+		// looked like the directory this probe is at.
+		weight := 1.0
+		listing := root
+		for {
+			dirsSum += weight
+			entriesSum += weight * float64(listing.entries)
+			if len(listing.subDirs) == 0 {
+				break
+			}
+			weight *= float64(len(listing.subDirs))
+			if listing, err = list(listing.subDirs[r.Intn(len(listing.subDirs))], ""); err != nil {
+				return ListCostEstimate{}, err
+			}
+		}
+	}
+	estimate.Dirs = int64(dirsSum/float64(probes) + 0.5)
+	estimate.Entries = int64(entriesSum/float64(probes) + 0.5)
+	return estimate, nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Returns a synthetic tree where directory "i/" at depth d holds fanOut(d, i)
+// sub-directories and files(d, i) objects.
+func syntheticTree(depth int, fanOut, files func(d, i int) int) map[string][]string {
+	dirs := make(map[string][]string)
+	var build func(dir string, d, i int)
+	build = func(dir string, d, i int) {
+		var entries []string
+		for f := 0; f < files(d, i); f++ {
+			entries = append(entries, fmt.Sprintf("obj%d", f))
+		}
+		if d < depth {
+			for s := 0; s < fanOut(d, i); s++ {
+				entries = append(entries, fmt.Sprintf("dir%d/", s))
+				build(fmt.Sprintf("%sdir%d/", dir, s), d+1, s)
+			}
+		}
+		dirs[dir] = entries
+	}
+	build("", 0, 0)
+	return dirs
+}
+
+// Test list cost estimates against the actual cost of walking synthetic trees.
+func TestEstimateListCost(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	testCases := []struct {
+		dirs      map[string][]string
+		prefix    string
+		recursive bool
+		tolerance float64
+	}{
+		// Regular tree, every probe sees the same shape hence exact.
+		{syntheticTree(3, func(d, i int) int { return 4 }, func(d, i int) int { return 2 }), "", true, 0},
+		{syntheticTree(3, func(d, i int) int { return 4 }, func(d, i int) int { return 2 }), "dir1/", true, 0},
+		// Prefixes are cleaned like those of walks.
+		{syntheticTree(3, func(d, i int) int { return 4 }, func(d, i int) int { return 2 }), "dir1//", true, 0},
+		{syntheticTree(3, func(d, i int) int { return 4 }, func(d, i int) int { return 2 }), "./dir1/", false, 0},
+		// Irregular tree.
+		{syntheticTree(4, func(d, i int) int { return 1 + (i+d)%4 }, func(d, i int) int { return i % 5 }), "", true, 0.25},
+		// Non recursive listing is exact.
+		{syntheticTree(4, func(d, i int) int { return 1 + (i+d)%4 }, func(d, i int) int { return i % 5 }), "dir2/", false, 0},
+	}
+	for i, testCase := range testCases {
+		disk := &listDirDisk{dirs: testCase.dirs}
+		// Count the actual cost of walking.
+		var actual ListCostEstimate
+		listDir := listDirFactory(isLeaf, disk)
+		countListDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+			entries, delayIsLeaf, err := listDir(bucket, prefixDir, prefixEntry)
+			actual.Dirs++
+			actual.Entries += int64(len(entries))
+			return entries, delayIsLeaf, err
+		}
+		walkEntries(testCase.prefix, "", testCase.recursive, countListDir, isLeaf)
+
+		estimate, err := estimateListCost(volume, testCase.prefix, testCase.recursive, listDir, isLeaf, 200, 1)
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if estimate.SampledDirs > actual.Dirs {
+			t.Errorf("Test %d: Expected at most %d directories to be sampled, got %d", i+1, actual.Dirs, estimate.SampledDirs)
+		}
+		if math.Abs(float64(estimate.Dirs-actual.Dirs)) > testCase.tolerance*float64(actual.Dirs) {
+			t.Errorf("Test %d: Expected about %d directories, estimated %d", i+1, actual.Dirs, estimate.Dirs)
+		}
+		if math.Abs(float64(estimate.Entries-actual.Entries)) > testCase.tolerance*float64(actual.Entries) {
+			t.Errorf("Test %d: Expected about %d entries, estimated %d", i+1, actual.Entries, estimate.Entries)
+		}
+	}
+}
+
+// Test EstimateListCost on both backends.
+func TestObjectLayerEstimateListCost(t *testing.T) {
+	ExecObjectLayerTest(t, testObjectLayerEstimateListCost)
+}
+
+func testObjectLayerEstimateListCost(obj ObjectLayer, instanceType string, t TestErrHandler) {
+	bucket := "bucket"
+	if err := obj.MakeBucket(bucket); err != nil {
+		t.Fatalf("%s: %s", instanceType, err)
+	}
+	for _, object := range []string{"a", "b/c", "b/d", "e/f/g"} {
+		if _, err := obj.PutObject(bucket, object, 1, bytes.NewBufferString("x"), nil); err != nil {
+			t.Fatalf("%s: %s", instanceType, err)
+		}
+	}
+	estimate, err := obj.EstimateListCost(bucket, "", false)
+	if err != nil {
+		t.Fatalf("%s: %s", instanceType, err)
+	}
+	expected := ListCostEstimate{Dirs: 1, Entries: 3, SampledDirs: 1}
+	if estimate != expected {
+		t.Errorf("%s: Expected %v, got %v", instanceType, expected, estimate)
+	}
+}
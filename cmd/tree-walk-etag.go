@@ -0,0 +1,105 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sort"
+
+	"golang.org/x/net/context"
+)
+
+// etagGroup - keys of objects sharing an ETag, i.e. likely identical content.
+type etagGroup struct {
+	etag string
+	keys []string
+}
+
+// groupByETag - walks all objects under prefix and calls fn, in ETag
+// order, for every group of two or more objects sharing an ETag.
+//
+// Since the walk is sorted by key and not by ETag a group can only be
+// known to be complete once the walk is done, hence groups are buffered.
+// To bound memory at most maxETags distinct ETags are tracked, beyond it
+// ETags seen by a single object so far are dropped oldest first and
+// their duplicates appearing later are missed. evicted is the number of
+// ETags dropped, zero means the grouping is exact. Such ETags are dropped
+// as well to keep the groups within budget, errWalkMemoryBudget is
+// returned if the groups of two or more objects alone outgrow it.
+func groupByETag(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc, maxETags int, budget walkMemoryBudget, fn func(etagGroup) error) (evicted int, err error) {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+
+	groups := make(map[string][]string)
+	var groupBytes int64
+	// ETags with a single object in the order they were first seen, the
+	// candidates for eviction.
+	var singles []string
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return evicted, walkResult.err
+		}
+		info, oErr := objInfo(bucket, walkResult.entry)
+		if oErr != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(oErr) == errFileNotFound {
+				continue
+			}
+			return evicted, oErr
+		}
+		if info.MD5Sum == "" {
+			continue
+		}
+		keys, ok := groups[info.MD5Sum]
+		groups[info.MD5Sum] = append(keys, info.Name)
+		groupBytes += int64(len(info.Name)) + walkBufferEntryOverhead
+		if !ok {
+			singles = append(singles, info.MD5Sum)
+			groupBytes += int64(len(info.MD5Sum)) + walkBufferEntryOverhead
+		}
+		for (len(groups) > maxETags || !budget.allows(groupBytes)) && len(singles) > 0 {
+			etag := singles[0]
+			singles = singles[1:]
+			// Only evict ETags still seen by a single object.
+			if keys = groups[etag]; len(keys) == 1 {
+				delete(groups, etag)
+				groupBytes -= int64(len(etag)+len(keys[0])) + 2*walkBufferEntryOverhead
+				evicted++
+			}
+		}
+		if !budget.allows(groupBytes) {
+			return evicted, traceError(errWalkMemoryBudget)
+		}
+	}
+
+	etags := make([]string, 0, len(groups))
+	for etag, keys := range groups {
+		if len(keys) > 1 {
+			etags = append(etags, etag)
+		}
+	}
+	sort.Strings(etags)
+	for _, etag := range etags {
+		if err = fn(etagGroup{etag: etag, keys: groups[etag]}); err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test objects sharing an ETag are grouped together.
+func TestGroupByETag(t *testing.T) {
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b", "c/", "d", "e", "f"},
+		"c/": {"x", "y"},
+	}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	etags := map[string]string{
+		"a":   "1111",
+		"b":   "2222",
+		"c/x": "1111",
+		"c/y": "3333",
+		"d":   "2222",
+		"e":   "1111",
+		"f":   "", // No ETag.
+	}
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object, MD5Sum: etags[object]}, nil
+	}
+
+	testCases := []struct {
+		maxETags        int
+		budget          walkMemoryBudget
+		expected        []etagGroup
+		expectedEvicted int
+	}{
+		{
+			maxETags: 100,
+			expected: []etagGroup{
+				{etag: "1111", keys: []string{"a", "c/x", "e"}},
+				{etag: "2222", keys: []string{"b", "d"}},
+			},
+		},
+		// "3333" pushes out "2222" which is still a single by then,
+		// "2222" seen again by "d" then pushes out "3333".
+		{
+			maxETags: 2,
+			expected: []etagGroup{
+				{etag: "1111", keys: []string{"a", "c/x", "e"}},
+			},
+			expectedEvicted: 2,
+		},
+		// Singles are evicted to stay within budget.
+		{
+			maxETags: 100,
+			budget:   walkMemoryBudget{maxBytes: 80},
+			expected: []etagGroup{
+				{etag: "1111", keys: []string{"a", "c/x", "e"}},
+			},
+			expectedEvicted: 3,
+		},
+	}
+	for i, testCase := range testCases {
+		var got []etagGroup
+		evicted, err := groupByETag(volume, "", listDir, isLeaf, objInfo, testCase.maxETags, testCase.budget, func(group etagGroup) error {
+			got = append(got, group)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if evicted != testCase.expectedEvicted {
+			t.Errorf("Test %d: Expected %d evicted ETags, got %d", i+1, testCase.expectedEvicted, evicted)
+		}
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
+
+// Test grouping fails once groups alone outgrow the memory budget.
+func TestGroupByETagBudget(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"": {"a", "b", "c"},
+	}})
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object, MD5Sum: "1111"}, nil
+	}
+	_, err := groupByETag(volume, "", listDir, isLeaf, objInfo, 100, walkMemoryBudget{maxBytes: 60}, func(group etagGroup) error {
+		t.Errorf("Unexpected group %v", group)
+		return nil
+	})
+	if errorCause(err) != errWalkMemoryBudget {
+		t.Errorf("Expected error %v, got %v", errWalkMemoryBudget, err)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "strings"
+
+// ObjectsExistUnderPrefix - returns true if any object exists under
+// prefix, which like the prefix of startTreeWalk() need not end in "/".
+// Unlike a walk it lists directories right here without go-routines or
+// channels and returns as soon as an object is found, objects in a
+// directory are looked for before any of its sub-directories is listed.
+func ObjectsExistUnderPrefix(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc) (bool, error) {
+	// Prefixes are cleaned like the prefixes of walks are.
+	prefix, err := normalizeWalkPrefix(bucket, prefix)
+	if err != nil {
+		return false, err
+	}
+	prefixDir, entryPrefixMatch := splitWalkPrefix(prefix)
+	dirs := []string{prefixDir}
+	for len(dirs) > 0 {
+		dir := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		entries, delayIsLeaf, err := listDir(bucket, dir, entryPrefixMatch)
+		entryPrefixMatch = "" // Valid only for the first directory.
+		if err != nil {
+			// Nothing under prefix, or a directory removed after its
+			// parent was listed.
+			if errorCause(err) == errFileNotFound {
+				continue
+			}
+			return false, err
+		}
+		var subDirs []string
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry, slashSeparator) {
+				return true, nil
+			}
+			// Decision to do isLeaf check was pushed from listDir() to here.
+			if delayIsLeaf && isLeaf(bucket, pathJoin(dir, entry)) {
+				return true, nil
+			}
+			subDirs = append(subDirs, pathJoin(dir, entry))
+		}
+		// Pushed in reverse so that sub-directories are listed in order.
+		for i := len(subDirs) - 1; i >= 0; i-- {
+			dirs = append(dirs, subDirs[i])
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test looking for objects under a prefix stops at the first object.
+func TestObjectsExistUnderPrefix(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	errFaultyListDir := errors.New("faulty listDir")
+	testCases := []struct {
+		dirs          map[string][]string
+		failDir       string // Listing it fails with errFaultyListDir.
+		prefix        string
+		exists        bool
+		err           error
+		expectedCalls int
+	}{
+		// Empty bucket.
+		{map[string][]string{"": {}}, "", "", false, nil, 1},
+		// Bucket which does not exist, or a prefix which does not.
+		{map[string][]string{}, "", "", false, nil, 1},
+		{map[string][]string{"": {"a"}}, "", "b/", false, nil, 1},
+		// Prefix with only sub-directories, all of them listed.
+		{map[string][]string{"a/": {"b/", "c/"}, "a/b/": {}, "a/c/": {"d/"}, "a/c/d/": {}}, "", "a/", false, nil, 4},
+		// Object beneath sub-directories.
+		{map[string][]string{"a/": {"b/", "c/"}, "a/b/": {"x"}, "a/c/": {"y"}}, "", "a/", true, nil, 2},
+		// Object directly under prefix, sub-directories are not listed.
+		{map[string][]string{"a/": {"b/", "x"}, "a/b/": {"y"}}, "", "a/", true, nil, 1},
+		// Prefix not ending in "/".
+		{map[string][]string{"": {"ab/", "b"}, "ab/": {"x"}}, "", "a", true, nil, 2},
+		{map[string][]string{"": {"ab/", "b"}, "ab/": {}}, "", "a", false, nil, 2},
+		// Prefixes are cleaned like those of walks.
+		{map[string][]string{"a/": {"x"}}, "", "a//", true, nil, 1},
+		{map[string][]string{"a/b/": {"x"}}, "", "./a//b/", true, nil, 1},
+		// Prefixes leading outside of the bucket are invalid.
+		{map[string][]string{"a/": {"x"}}, "", "../a/", false, ObjectNameInvalid{Bucket: volume, Object: "../a/"}, 0},
+		// Errors other than errFileNotFound are returned.
+		{map[string][]string{"a/": {"b/"}, "a/b/": {"x"}}, "a/b/", "a/", false, errFaultyListDir, 2},
+	}
+	for i, testCase := range testCases {
+		disk := &countingListDirDisk{listDirDisk: listDirDisk{dirs: testCase.dirs}}
+		// Without prefetching, so that only the directories walked are listed.
+		diskListDir := listDirFactoryWithOpts(isLeaf, listDirOpts{}, disk)
+		listDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+			if testCase.failDir != "" && prefixDir == testCase.failDir {
+				disk.calls++
+				return nil, false, traceError(errFaultyListDir)
+			}
+			return diskListDir(bucket, prefixDir, prefixEntry)
+		}
+		exists, err := ObjectsExistUnderPrefix(volume, testCase.prefix, listDir, isLeaf)
+		if errorCause(err) != testCase.err {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.err, err)
+		}
+		if exists != testCase.exists {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.exists, exists)
+		}
+		if disk.calls != testCase.expectedCalls {
+			t.Errorf("Test %d: Expected %d ListDir calls, got %d", i+1, testCase.expectedCalls, disk.calls)
+		}
+	}
+}
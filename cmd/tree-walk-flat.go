@@ -0,0 +1,69 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+)
+
+// Returns function "listDir" of the type listDirFunc for a flat key-prefix
+// backend holding keys with no directories of their own, like gateways
+// to other object stores. A directory entry is synthesized for every
+// intermediate path component of the keys, so that POSIX style clients
+// see a folder at every level even when only deep keys exist. Keys with
+// a trailing "/" are treated as real directory markers and are merged
+// with the synthesized entries instead of being listed twice.
+//
+// keys need to be sorted, entries are returned with delayIsLeaf set and
+// the matching isLeaf is one returning false for entries ending in "/".
+func listDirFlatKeysFactory(keys []string) listDirFunc {
+	listDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
+		prefix := prefixDir + prefixEntry
+		start := sort.SearchStrings(keys, prefix)
+		for _, key := range keys[start:] {
+			if !strings.HasPrefix(key, prefix) {
+				break
+			}
+			entry := strings.TrimPrefix(key, prefixDir)
+			if entry == "" {
+				// Directory marker of prefixDir itself.
+				continue
+			}
+			if i := strings.Index(entry, slashSeparator); i != -1 {
+				entry = entry[:i+1]
+			}
+			// Keys sharing a directory are adjacent, skip duplicates.
+			if len(entries) > 0 && entries[len(entries)-1] == entry {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 && prefixDir != "" && !keyDirExists(keys, prefixDir) {
+			return nil, false, traceError(errFileNotFound)
+		}
+		// Truncating sorted keys to their first "/" keeps them sorted.
+		return entries, true, nil
+	}
+	return listDir
+}
+
+// Returns true if some key is beneath dir.
+func keyDirExists(keys []string, dir string) bool {
+	i := sort.SearchStrings(keys, dir)
+	return i < len(keys) && strings.HasPrefix(keys[i], dir)
+}
@@ -0,0 +1,63 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test intermediate folders are listed on a backend holding only deep keys.
+func TestListDirFlatKeys(t *testing.T) {
+	keys := []string{
+		"a!b",
+		"a/b/c/d",
+		"a/b/e",
+		"a/x",
+		"f/",
+		"f/g/h",
+		"i",
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFlatKeysFactory(keys)
+
+	testCases := []struct {
+		prefix    string
+		recursive bool
+		expected  []string
+	}{
+		{"", false, []string{"a!b", "a/", "f/", "i"}},
+		{"a/", false, []string{"a/b/", "a/x"}},
+		{"a/b/", false, []string{"a/b/c/", "a/b/e"}},
+		{"a/b/c/", false, []string{"a/b/c/d"}},
+		// Directory marker "f/" is not listed twice.
+		{"f", false, []string{"f/"}},
+		{"f/", false, []string{"f/g/"}},
+		{"a", true, []string{"a!b", "a/b/c/d", "a/b/e", "a/x"}},
+		// Prefix which does not exist.
+		{"x/", false, []string{errFileNotFound.Error()}},
+	}
+	for i, testCase := range testCases {
+		got := walkEntries(testCase.prefix, "", testCase.recursive, listDir, isLeaf)
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"path"
+	"strings"
+)
+
+// Glob patterns of tree walks use path.Match() semantics for every path
+// component, '*' hence never matches a '/'. A component which is "**"
+// matches zero or more whole path components, e.g. "logs/**" matches
+// everything under "logs/". Malformed patterns match nothing.
+
+// Returns the path components of name, directories may have a trailing "/".
+func globComponents(name string) []string {
+	name = strings.TrimSuffix(name, slashSeparator)
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, slashSeparator)
+}
+
+// Returns true if pattern consists of "**" components only.
+func isGlobStarStar(pattern []string) bool {
+	for _, component := range pattern {
+		if component != "**" {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the path components name match pattern.
+func matchGlobComponents(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobComponents(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], name[0]); err != nil || !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchGlob - returns true if name matches pattern.
+func matchGlob(pattern, name string) bool {
+	return matchGlobComponents(globComponents(pattern), globComponents(name))
+}
+
+// globMatchBeneath - returns true if pattern may match a path beneath
+// directory dir, so that directories which can not hold any match need
+// not be listed.
+func globMatchBeneath(pattern, dir string) bool {
+	patternComponents := globComponents(pattern)
+	for _, component := range globComponents(dir) {
+		if len(patternComponents) == 0 {
+			return false
+		}
+		if patternComponents[0] == "**" {
+			// Rest of dir and anything beneath it may match.
+			return true
+		}
+		if matched, err := path.Match(patternComponents[0], component); err != nil || !matched {
+			return false
+		}
+		patternComponents = patternComponents[1:]
+	}
+	// Entries beneath dir have at least one more component.
+	return len(patternComponents) > 0
+}
+
+// Returns true if pattern matches every path beneath the path components dir.
+func globMatchAllBeneathComponents(pattern, dir []string) bool {
+	if len(pattern) > 0 && isGlobStarStar(pattern) {
+		return true
+	}
+	if len(pattern) == 0 || len(dir) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(dir); i++ {
+			if globMatchAllBeneathComponents(pattern[1:], dir[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if matched, err := path.Match(pattern[0], dir[0]); err != nil || !matched {
+		return false
+	}
+	return globMatchAllBeneathComponents(pattern[1:], dir[1:])
+}
+
+// globMatchAllBeneath - returns true if pattern matches every path beneath
+// directory dir, e.g. "tmp/**" for "tmp/", so that a directory whose
+// entries are all excluded need not be listed.
+func globMatchAllBeneath(pattern, dir string) bool {
+	return globMatchAllBeneathComponents(globComponents(pattern), globComponents(dir))
+}
+
+// includeObject - returns true if object matches one of the include
+// patterns, if any, and none of the exclude patterns.
+func (opts *treeWalkOpts) includeObject(object string) bool {
+	for _, pattern := range opts.exclude {
+		if matchGlob(pattern, object) {
+			return false
+		}
+	}
+	if len(opts.include) == 0 {
+		return true
+	}
+	for _, pattern := range opts.include {
+		if matchGlob(pattern, object) {
+			return true
+		}
+	}
+	return false
+}
+
+// includeDir - returns false if no object beneath dir can be included.
+func (opts *treeWalkOpts) includeDir(dir string) bool {
+	for _, pattern := range opts.exclude {
+		if globMatchAllBeneath(pattern, dir) {
+			return false
+		}
+	}
+	if len(opts.include) == 0 {
+		return true
+	}
+	for _, pattern := range opts.include {
+		if globMatchBeneath(pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// Test glob patterns match full paths with path.Match() semantics and "**".
+func TestMatchGlob(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*.tmp", "a.tmp", true},
+		{"*.tmp", "a/b.tmp", false},
+		{"**/*.tmp", "a.tmp", true},
+		{"**/*.tmp", "a/b/c.tmp", true},
+		{"**/*.tmp", "a/b/c.txt", false},
+		{"logs/**", "logs/a", true},
+		{"logs/**", "logs/a/b", true},
+		{"logs/**", "logsa", false},
+		{"logs/**", "data/logs/a", false},
+		{"a/?/c", "a/b/c", true},
+		{"a/[", "a/[", false},
+	}
+	for i, testCase := range testCases {
+		if matched := matchGlob(testCase.pattern, testCase.name); matched != testCase.matched {
+			t.Errorf("Test %d: %q on %q: Expected %v, got %v", i+1, testCase.pattern, testCase.name, testCase.matched, matched)
+		}
+	}
+
+	dirTestCases := []struct {
+		pattern    string
+		dir        string
+		beneath    bool
+		allBeneath bool
+	}{
+		{"*.tmp", "a/", false, false},
+		{"**/*.tmp", "a/b/", true, false},
+		{"logs/**", "logs/", true, true},
+		{"logs/**", "logs/x/", true, true},
+		{"logs/**", "data/", false, false},
+		{"logs/*.log", "logs/", true, false},
+		{"logs/*.log", "logs/x/", false, false},
+		{"**/tmp/**", "a/tmp/", true, true},
+	}
+	for i, testCase := range dirTestCases {
+		if beneath := globMatchBeneath(testCase.pattern, testCase.dir); beneath != testCase.beneath {
+			t.Errorf("Test %d: %q beneath %q: Expected %v, got %v", i+1, testCase.pattern, testCase.dir, testCase.beneath, beneath)
+		}
+		if allBeneath := globMatchAllBeneath(testCase.pattern, testCase.dir); allBeneath != testCase.allBeneath {
+			t.Errorf("Test %d: %q all beneath %q: Expected %v, got %v", i+1, testCase.pattern, testCase.dir, testCase.allBeneath, allBeneath)
+		}
+	}
+}
+
+// Test include and exclude patterns filter walks and prune directories
+// which can not hold any match.
+func TestTreeWalkGlob(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	testCases := []struct {
+		include       []string
+		exclude       []string
+		recursive     bool
+		expected      []string
+		expectedCalls int
+	}{
+		// No patterns, everything is listed.
+		{nil, nil, true, []string{"a.tmp", "b.txt", "data/c.tmp", "data/d.txt", "logs/1.log", "logs/old/2.log"}, 4},
+		{nil, []string{"*.tmp"}, true, []string{"b.txt", "data/c.tmp", "data/d.txt", "logs/1.log", "logs/old/2.log"}, 4},
+		{nil, []string{"**/*.tmp"}, true, []string{"b.txt", "data/d.txt", "logs/1.log", "logs/old/2.log"}, 4},
+		// Only "logs/" is walked.
+		{[]string{"logs/**"}, nil, true, []string{"logs/1.log", "logs/old/2.log"}, 3},
+		// "logs/old/" is excluded as a whole, "data/" is never included.
+		{[]string{"logs/**"}, []string{"logs/old/**"}, true, []string{"logs/1.log"}, 2},
+		{[]string{"logs/**"}, nil, false, []string{"logs/"}, 1},
+		{[]string{"*.txt"}, nil, false, []string{"b.txt"}, 1},
+	}
+	for i, testCase := range testCases {
+		disk := &countingListDirDisk{listDirDisk: listDirDisk{dirs: map[string][]string{
+			"":          {"a.tmp", "b.txt", "data/", "logs/"},
+			"data/":     {"c.tmp", "d.txt"},
+			"logs/":     {"1.log", "old/"},
+			"logs/old/": {"2.log"},
+		}}}
+		listDir := listDirFactory(isLeaf, disk)
+		opts := treeWalkOpts{include: testCase.include, exclude: testCase.exclude}
+		endWalkCh := make(chan struct{})
+		var entries []string
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if disk.calls != testCase.expectedCalls {
+			t.Errorf("Test %d: Expected %d ListDir calls, got %d", i+1, testCase.expectedCalls, disk.calls)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// trieIndexNode - a directory in the listing index. Entries are named the
+// way listDir() returns them after the isLeaf() check, directories with a
+// trailing "/" pointing to their own node and objects pointing to nil.
+type trieIndexNode struct {
+	entries map[string]*trieIndexNode
+}
+
+func newTrieIndexNode() *trieIndexNode {
+	return &trieIndexNode{entries: make(map[string]*trieIndexNode)}
+}
+
+// trieIndex - in-memory listing index of a bucket, a trie of path
+// components built from a full walk. Listings served from the index do
+// not touch the disks, the index is rebuilt once it is older than ttl or
+// after Invalidate() was called, for example on writes to the bucket.
+type trieIndex struct {
+	bucket  string
+	listDir listDirFunc // Used to (re)build the index.
+	isLeaf  isLeafFunc
+	ttl     time.Duration // Zero means the index never expires.
+
+	mu      sync.RWMutex
+	root    *trieIndexNode
+	builtAt time.Time
+	stale   bool
+}
+
+// buildTrieIndex - walks the whole bucket using listDir and returns the
+// listing index built from it.
+func buildTrieIndex(bucket string, listDir listDirFunc, isLeaf isLeafFunc, ttl time.Duration) (*trieIndex, error) {
+	index := &trieIndex{
+		bucket:  bucket,
+		listDir: listDir,
+		isLeaf:  isLeaf,
+		ttl:     ttl,
+	}
+	if err := index.build(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// build - (re)builds the index from a full recursive walk, caller should
+// hold the write lock if the index is shared.
+func (t *trieIndex) build() error {
+	root := newTrieIndexNode()
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for walkResult := range startTreeWalk(context.Background(), t.bucket, "", "", true, t.listDir, t.isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, bucket is empty.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return walkResult.err
+		}
+		node := root
+		components := strings.Split(walkResult.entry, slashSeparator)
+		for _, dir := range components[:len(components)-1] {
+			dir += slashSeparator
+			child := node.entries[dir]
+			if child == nil {
+				child = newTrieIndexNode()
+				node.entries[dir] = child
+			}
+			node = child
+		}
+		node.entries[components[len(components)-1]] = nil
+	}
+	t.root = root
+	t.builtAt = time.Now().UTC()
+	t.stale = false
+	return nil
+}
+
+// Invalidate - marks the index stale, the next listing rebuilds it.
+func (t *trieIndex) Invalidate() {
+	t.mu.Lock()
+	t.stale = true
+	t.mu.Unlock()
+}
+
+// isStale - returns true if the index needs to be rebuilt, caller should
+// hold at least the read lock.
+func (t *trieIndex) isStale() bool {
+	if t.stale {
+		return true
+	}
+	return t.ttl > 0 && time.Since(t.builtAt) > t.ttl
+}
+
+// Returns sorted entries of prefixDir from the index which have the prefix
+// prefixEntry, rebuilding the index first if it is stale.
+func (t *trieIndex) listEntries(prefixDir, prefixEntry string) ([]string, error) {
+	t.mu.RLock()
+	if t.isStale() {
+		t.mu.RUnlock()
+		t.mu.Lock()
+		// Check again, another listing could have rebuilt the index meanwhile.
+		if t.isStale() {
+			if err := t.build(); err != nil {
+				t.mu.Unlock()
+				return nil, err
+			}
+		}
+		t.mu.Unlock()
+		t.mu.RLock()
+	}
+	defer t.mu.RUnlock()
+
+	node := t.root
+	for _, dir := range strings.SplitAfter(prefixDir, slashSeparator) {
+		if dir == "" {
+			continue
+		}
+		node = node.entries[dir]
+		if node == nil {
+			return nil, errFileNotFound
+		}
+	}
+	entries := make([]string, 0, len(node.entries))
+	for entry := range node.entries {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	return filterMatchingPrefix(entries, prefixEntry), nil
+}
+
+// Returns function "listDir" of the type listDirFunc serving listings of
+// index.bucket from the index, other buckets are listed using the listDir
+// the index was built with.
+func listDirTrieIndexFactory(index *trieIndex) listDirFunc {
+	listDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
+		if bucket != index.bucket {
+			return index.listDir(bucket, prefixDir, prefixEntry)
+		}
+		entries, err = index.listEntries(prefixDir, prefixEntry)
+		if err != nil {
+			return nil, false, traceError(err)
+		}
+		// Objects were stored without the trailing "/", isLeaf() need not be delayed.
+		return entries, false, nil
+	}
+	return listDir
+}
+
+// Returns the time since the index was last built, a stale index is
+// infinitely old.
+func (t *trieIndex) age() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.stale {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Since(t.builtAt)
+}
+
+// Returns function "listDir" of the type listDirFunc for a walk which
+// needs to reflect all writes older than maxAge. If the index is at most
+// maxAge old listings are served from it like listDirTrieIndexFactory(),
+// otherwise the index is bypassed and everything is read using the
+// listDir the index was built with, e.g. a quorum listing of the disks.
+// The choice is made once so that a walk never mixes both.
+func listDirTrieIndexFreshFactory(index *trieIndex, maxAge time.Duration) listDirFunc {
+	if index.age() > maxAge {
+		return index.listDir
+	}
+	return listDirTrieIndexFactory(index)
+}
@@ -0,0 +1,182 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Returns all entries of a walk.
+func walkEntries(prefix, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc) []string {
+	var entries []string
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for walkResult := range startTreeWalk(context.Background(), volume, prefix, marker, recursive, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			entries = append(entries, walkResult.err.Error())
+			continue
+		}
+		entries = append(entries, walkResult.entry)
+	}
+	return entries
+}
+
+// Test listings served from the trie index are identical to disk listings.
+func TestTrieIndexListDir(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+	var files = []string{
+		"a-b",
+		"a/c",
+		"d/e",
+		"d/f",
+		"d/g/h",
+		"i/j/k",
+		"lmn",
+	}
+	if err = createNamespace(disk, volume, files); err != nil {
+		t.Fatal(err)
+	}
+
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	diskListDir := listDirFactory(isLeaf, disk)
+	index, err := buildTrieIndex(volume, diskListDir, isLeaf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexListDir := listDirTrieIndexFactory(index)
+
+	testCases := []struct {
+		prefix    string
+		marker    string
+		recursive bool
+	}{
+		{"", "", false},
+		{"", "", true},
+		{"", "d/e", false},
+		{"", "d/e", true},
+		{"a", "", false},
+		{"d/", "", false},
+		{"d/", "", true},
+		{"d/", "d/e", true},
+		{"d/g", "", true},
+		// Prefix which does not exist.
+		{"x/", "", true},
+	}
+	for i, testCase := range testCases {
+		expected := walkEntries(testCase.prefix, testCase.marker, testCase.recursive, diskListDir, isLeaf)
+		got := walkEntries(testCase.prefix, testCase.marker, testCase.recursive, indexListDir, isLeaf)
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, expected, got)
+		}
+	}
+
+	// New objects are not visible until the index is invalidated.
+	if err = disk.AppendFile(volume, "d/new", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"d/e", "d/f", "d/g/h"}
+	if got := walkEntries("d/", "", true, indexListDir, isLeaf); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	index.Invalidate()
+	expected = []string{"d/e", "d/f", "d/g/h", "d/new"}
+	if got := walkEntries("d/", "", true, indexListDir, isLeaf); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+// Test the trie index is rebuilt once its ttl has expired.
+func TestTrieIndexTTL(t *testing.T) {
+	disk := &listDirDisk{dirs: map[string][]string{
+		"": {"a"},
+	}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	index, err := buildTrieIndex(volume, listDirFactory(isLeaf, disk), isLeaf, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listDir := listDirTrieIndexFactory(index)
+
+	disk.dirs[""] = []string{"a", "b"}
+	if got := walkEntries("", "", true, listDir, isLeaf); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected listing to be served from the index, got %v", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := walkEntries("", "", true, listDir, isLeaf); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Expected index to be rebuilt after ttl, got %v", got)
+	}
+}
+
+// Test the index is bypassed when it is older than the freshness bound.
+func TestTrieIndexFreshness(t *testing.T) {
+	disk := &listDirDisk{dirs: map[string][]string{
+		"": {"a"},
+	}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	index, err := buildTrieIndex(volume, listDirFactory(isLeaf, disk), isLeaf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk.dirs[""] = []string{"a", "b"}
+	time.Sleep(50 * time.Millisecond)
+
+	testCases := []struct {
+		maxAge   time.Duration
+		expected []string
+	}{
+		// Cached listing is fresh enough.
+		{time.Hour, []string{"a"}},
+		// Cached listing is too old, read from disk.
+		{10 * time.Millisecond, []string{"a", "b"}},
+	}
+	for i, testCase := range testCases {
+		listDir := listDirTrieIndexFreshFactory(index, testCase.maxAge)
+		if got := walkEntries("", "", true, listDir, isLeaf); !reflect.DeepEqual(got, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+
+	// Bypassing does not rebuild the index.
+	if got := walkEntries("", "", true, listDirTrieIndexFactory(index), isLeaf); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected index to be unchanged, got %v", got)
+	}
+	// An invalidated index is always bypassed.
+	index.Invalidate()
+	if got := walkEntries("", "", true, listDirTrieIndexFreshFactory(index, time.Hour), isLeaf); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Expected invalidated index to be bypassed, got %v", got)
+	}
+}
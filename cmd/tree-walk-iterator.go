@@ -0,0 +1,88 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "golang.org/x/net/context"
+
+// TreeWalkIterator - iterates over the entries of a tree walk, taking
+// care of its results and endWalkCh so that callers can write
+//
+//	it := newTreeWalkIterator(...)
+//	defer it.Close()
+//	for entry, ok := it.Next(); ok; entry, ok = it.Next() {
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type TreeWalkIterator struct {
+	resultCh  chan treeWalkResult
+	endWalkCh chan struct{}
+	err       error
+	done      bool // Set once the walk ended, failed or was closed.
+}
+
+// newTreeWalkIterator - starts a walk like startTreeWalkWithOpts() and
+// returns an iterator over its entries.
+func newTreeWalkIterator(ctx context.Context, bucket, prefix, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, opts treeWalkOpts) *TreeWalkIterator {
+	endWalkCh := make(chan struct{})
+	return &TreeWalkIterator{
+		resultCh:  startTreeWalkWithOpts(ctx, bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh, opts),
+		endWalkCh: endWalkCh,
+	}
+}
+
+// Next - returns the next entry of the walk, ok is false once the walk
+// has ended, failed or was closed. Heartbeat, denied and summary results
+// are not entries and skipped. A prefix without any entries is not an
+// error, Next then returns false right away.
+func (it *TreeWalkIterator) Next() (entry string, ok bool) {
+	for !it.done {
+		result, ok := <-it.resultCh
+		if !ok {
+			it.Close()
+			break
+		}
+		if result.err != nil {
+			if errorCause(result.err) != errFileNotFound {
+				it.err = result.err
+			}
+			it.Close()
+			break
+		}
+		if result.heartbeat || result.denied || result.summary != nil {
+			continue
+		}
+		return result.entry, true
+	}
+	return "", false
+}
+
+// Err - returns the error the walk failed with, if any.
+func (it *TreeWalkIterator) Err() error {
+	return it.err
+}
+
+// Close - aborts the walk if it has not ended yet, Next returns false
+// afterwards. Close can be called any number of times.
+func (it *TreeWalkIterator) Close() {
+	if it.done {
+		return
+	}
+	it.done = true
+	close(it.endWalkCh)
+}
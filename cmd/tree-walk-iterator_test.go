@@ -0,0 +1,81 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Test iterating over walks, closing them early and walk errors.
+func TestTreeWalkIterator(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b/", "c"},
+		"b/": {"x", "y"},
+	}}
+	listDir := listDirFactory(isLeaf, disk)
+	errFaultyListDir := errors.New("faulty listDir")
+	faultyListDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		if prefixDir == "b/" {
+			return nil, false, traceError(errFaultyListDir)
+		}
+		return listDir(bucket, prefixDir, prefixEntry)
+	}
+
+	testCases := []struct {
+		prefix   string
+		listDir  listDirFunc
+		closeAt  int // Close after this many entries when non-zero.
+		expected []string
+		err      error
+	}{
+		{"", listDir, 0, []string{"a", "b/x", "b/y", "c"}, nil},
+		{"b/", listDir, 0, []string{"b/x", "b/y"}, nil},
+		// Nothing under the prefix is not an error.
+		{"d/", listDir, 0, nil, nil},
+		{"", listDir, 2, []string{"a", "b/x"}, nil},
+		{"", faultyListDir, 0, []string{"a"}, errFaultyListDir},
+	}
+	for i, testCase := range testCases {
+		it := newTreeWalkIterator(context.Background(), volume, testCase.prefix, "", true, testCase.listDir, isLeaf, treeWalkOpts{heartbeatInterval: time.Millisecond})
+		var entries []string
+		for entry, ok := it.Next(); ok; entry, ok = it.Next() {
+			entries = append(entries, entry)
+			if len(entries) == testCase.closeAt {
+				it.Close()
+			}
+		}
+		it.Close()
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if err := errorCause(it.Err()); err != testCase.err {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.err, err)
+		}
+		if _, ok := it.Next(); ok {
+			t.Errorf("Test %d: Expected no entries once the walk is done", i+1)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// diskLoadTracker - number of outstanding requests on each disk of a set,
+// disks are identified by their index in the set. Set as
+// listDirOpts.loadTracker listings go to the least loaded disks first.
+type diskLoadTracker struct {
+	loads []int64
+}
+
+// newDiskLoadTracker - initialize a load tracker for numDisks disks.
+func newDiskLoadTracker(numDisks int) *diskLoadTracker {
+	return &diskLoadTracker{loads: make([]int64, numDisks)}
+}
+
+// start - marks the start of a request on disk index.
+func (d *diskLoadTracker) start(index int) {
+	atomic.AddInt64(&d.loads[index], 1)
+}
+
+// done - marks the end of a request on disk index.
+func (d *diskLoadTracker) done(index int) {
+	atomic.AddInt64(&d.loads[index], -1)
+}
+
+// leastLoaded - returns disk indices ordered by their current load, least
+// loaded first. Disks with equal load are shuffled like
+// getLoadBalancedDisks() does, so that idle disks share listings.
+func (d *diskLoadTracker) leastLoaded() []int {
+	if len(d.loads) == 0 {
+		return nil
+	}
+	indices := make([]int, len(d.loads))
+	loads := make([]int64, len(d.loads))
+	for i, j := range hashOrder(time.Now().UTC().String(), len(d.loads)) {
+		indices[i] = j - 1
+	}
+	for i := range d.loads {
+		loads[i] = atomic.LoadInt64(&d.loads[i])
+	}
+	sort.Stable(byDiskLoad{indices, loads})
+	return indices
+}
+
+// byDiskLoad - sorts disk indices by their load.
+type byDiskLoad struct {
+	indices []int
+	loads   []int64 // Load by disk index.
+}
+
+func (b byDiskLoad) Len() int           { return len(b.indices) }
+func (b byDiskLoad) Swap(i, j int)      { b.indices[i], b.indices[j] = b.indices[j], b.indices[i] }
+func (b byDiskLoad) Less(i, j int) bool { return b.loads[b.indices[i]] < b.loads[b.indices[j]] }
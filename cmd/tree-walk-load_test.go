@@ -0,0 +1,157 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingListDirDisk - listDirDisk counting calls to ListDir().
+type countingListDirDisk struct {
+	listDirDisk
+	calls int
+}
+
+func (d *countingListDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	d.calls++
+	return d.listDirDisk.ListDir(volume, dirPath)
+}
+
+func (d *countingListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
+// startedListDirDisk - listDirDisk sending its index on startedCh when
+// ListDir() is called, which then blocks until unblockCh is closed.
+type startedListDirDisk struct {
+	listDirDisk
+	index     int
+	startedCh chan int
+	unblockCh chan struct{}
+}
+
+func (d *startedListDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	d.startedCh <- d.index
+	<-d.unblockCh
+	return d.listDirDisk.ListDir(volume, dirPath)
+}
+
+func (d *startedListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
+// Test listings go to the least loaded disks first.
+func TestListDirLoadBalanced(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{"": {"a", "b"}}
+
+	// More disks than listDirConcurrency, only the least loaded are listed at first.
+	testCases := []struct {
+		loads    []int64
+		offline  int // Index of an offline disk, -1 for none.
+		expected map[int]bool
+	}{
+		{[]int64{5, 0, 3, 1, 4, 2}, -1, map[int]bool{1: true, 2: true, 3: true, 5: true}},
+		{[]int64{0, 9, 0, 9, 0, 0}, -1, map[int]bool{0: true, 2: true, 4: true, 5: true}},
+		// Least loaded disk is offline, the next least loaded are listed.
+		{[]int64{5, 0, 3, 1, 4, 2}, 1, map[int]bool{2: true, 3: true, 4: true, 5: true}},
+	}
+	for i, testCase := range testCases {
+		startedCh := make(chan int, len(testCase.loads))
+		unblockCh := make(chan struct{})
+		disks := make([]StorageAPI, len(testCase.loads))
+		for j := range disks {
+			if j != testCase.offline {
+				disks[j] = &startedListDirDisk{listDirDisk{dirs: dirs}, j, startedCh, unblockCh}
+			}
+		}
+		tracker := newDiskLoadTracker(len(disks))
+		copy(tracker.loads, testCase.loads)
+		listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{loadTracker: tracker}, disks...)
+
+		type listDirReply struct {
+			entries []string
+			err     error
+		}
+		replyCh := make(chan listDirReply, 1)
+		go func() {
+			entries, _, err := listDir(volume, "", "")
+			replyCh <- listDirReply{entries, err}
+		}()
+		started := make(map[int]bool)
+		for len(started) < listDirConcurrency {
+			select {
+			case index := <-startedCh:
+				started[index] = true
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Test %d: Expected %d disks to be listed, got %v", i+1, listDirConcurrency, started)
+			}
+		}
+		if !reflect.DeepEqual(started, testCase.expected) {
+			t.Errorf("Test %d: Expected disks %v to be listed, got %v", i+1, testCase.expected, started)
+		}
+		// Listings in progress are accounted for.
+		for index := range started {
+			if load := atomic.LoadInt64(&tracker.loads[index]); load != testCase.loads[index]+1 {
+				t.Errorf("Test %d: Expected load %d of disk %d while listing, got %d", i+1, testCase.loads[index]+1, index, load)
+			}
+		}
+		close(unblockCh)
+		reply := <-replyCh
+		if reply.err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, reply.err)
+		}
+		if !reflect.DeepEqual(reply.entries, dirs[""]) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, dirs[""], reply.entries)
+		}
+	}
+
+	// Missing directory on all disks.
+	tracker := newDiskLoadTracker(2)
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{loadTracker: tracker}, &listDirDisk{dirs: dirs}, &listDirDisk{dirs: dirs})
+	if _, _, err := listDir(volume, "missing/", ""); errorCause(err) != errFileNotFound {
+		t.Errorf("Expected %s, got %v", errFileNotFound, err)
+	}
+}
+
+// Test disks with equal load are all picked first at times.
+func TestDiskLoadTrackerLeastLoaded(t *testing.T) {
+	tracker := newDiskLoadTracker(4)
+	copy(tracker.loads, []int64{1, 0, 1, 0})
+	first := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		order := tracker.leastLoaded()
+		// Less loaded disks always come first.
+		if order[0]%2 != 1 || order[1]%2 != 1 || order[2]%2 != 0 || order[3]%2 != 0 {
+			t.Fatalf("Expected disks 1 and 3 before 0 and 2, got %v", order)
+		}
+		first[order[0]] = true
+		time.Sleep(time.Microsecond)
+	}
+	if len(first) != 2 {
+		t.Errorf("Expected both idle disks to be picked first, got %v", first)
+	}
+	if order := newDiskLoadTracker(0).leastLoaded(); len(order) != 0 {
+		t.Errorf("Expected no disks, got %v", order)
+	}
+}
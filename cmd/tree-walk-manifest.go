@@ -0,0 +1,193 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// errUnsortedListing - listing entries are not in sorted key order.
+var errUnsortedListing = errors.New("Listing is not sorted by key")
+
+// A function of type objectInfoFunc returns the metadata of an object, it
+// is satisfied by xl.getObjectInfo() and fs.getObjectInfo().
+type objectInfoFunc func(bucket, object string) (ObjectInfo, error)
+
+// manifestEntry - a key and its etag as recorded by a previous sync.
+type manifestEntry struct {
+	key  string
+	etag string
+}
+
+// manifestDiffType - how a key differs from the manifest.
+type manifestDiffType int
+
+const (
+	// Key is in the bucket but not in the manifest.
+	manifestKeyAdded manifestDiffType = iota
+	// Key is in the manifest but not in the bucket.
+	manifestKeyRemoved
+	// Key is in both but the etags differ.
+	manifestKeyChanged
+)
+
+// manifestDiff - a key which differs from the manifest. etag is the
+// current etag of the key and is empty for removed keys.
+type manifestDiff struct {
+	key      string
+	etag     string
+	diffType manifestDiffType
+}
+
+// byManifestKey - sort manifest entries by key.
+type byManifestKey []manifestEntry
+
+func (m byManifestKey) Len() int           { return len(m) }
+func (m byManifestKey) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byManifestKey) Less(i, j int) bool { return m[i].key < m[j].key }
+
+// diffManifest - walks all objects under prefix and calls diffFn for every
+// key which was added, removed or changed compared to manifest. Manifest
+// entries outside prefix are ignored. Since the tree walk emits keys in
+// sorted order the manifest is merge-walked against it, so each key is
+// looked at exactly once. The current etags are taken from the info the
+// walk sends along with every key, as returned by statEntry which hence
+// is required, errInvalidArgument is returned without it.
+func diffManifest(bucket, prefix string, manifest []manifestEntry, listDir listDirFunc, isLeaf isLeafFunc, statEntry statEntryFunc, diffFn func(manifestDiff) error) error {
+	if statEntry == nil {
+		return traceError(errInvalidArgument)
+	}
+	// Pick manifest entries under prefix and sort them the way the tree walk does.
+	var entries []manifestEntry
+	for _, entry := range manifest {
+		if strings.HasPrefix(entry.key, prefix) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Sort(byManifestKey(entries))
+
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	// Objects removed after they were listed are skipped by the walk, their
+	// manifest entries if any are reported as removed.
+	walkResultCh := startTreeWalkWithOpts(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{statEntry: statEntry})
+
+	i := 0
+	for walkResult := range walkResultCh {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return walkResult.err
+		}
+		key := walkResult.entry
+		// Manifest keys sorted before the current key are no longer present.
+		for ; i < len(entries) && entries[i].key < key; i++ {
+			if err := diffFn(manifestDiff{key: entries[i].key, diffType: manifestKeyRemoved}); err != nil {
+				return err
+			}
+		}
+		etag := walkResult.info.etag
+		if i < len(entries) && entries[i].key == key {
+			manifestETag := entries[i].etag
+			i++
+			if manifestETag == etag {
+				continue
+			}
+			if err := diffFn(manifestDiff{key: key, etag: etag, diffType: manifestKeyChanged}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := diffFn(manifestDiff{key: key, etag: etag, diffType: manifestKeyAdded}); err != nil {
+			return err
+		}
+	}
+	// Remaining manifest keys sort after every key in the bucket.
+	for ; i < len(entries); i++ {
+		if err := diffFn(manifestDiff{key: entries[i].key, diffType: manifestKeyRemoved}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the next entry of a sorted listing, ok is false at its end.
+func nextSortedEntry(listingCh <-chan manifestEntry, prev *manifestEntry) (entry manifestEntry, ok bool, err error) {
+	entry, ok = <-listingCh
+	if ok && prev != nil && entry.key <= prev.key {
+		return entry, ok, traceError(errUnsortedListing)
+	}
+	return entry, ok, nil
+}
+
+// DiffListings - calls diffFn for every key added, removed or changed in
+// newListing compared to oldListing, e.g. two snapshots of a bucket. Both
+// listings have to be sorted by key like tree walks are, they are then
+// merge-compared a key at a time so memory use does not grow with the
+// size of the listings. Fails with errUnsortedListing on out of order or
+// duplicate keys. Either listing is left partially drained on errors.
+func DiffListings(oldListing, newListing <-chan manifestEntry, diffFn func(manifestDiff) error) error {
+	oldEntry, oldOK, err := nextSortedEntry(oldListing, nil)
+	if err != nil {
+		return err
+	}
+	newEntry, newOK, err := nextSortedEntry(newListing, nil)
+	if err != nil {
+		return err
+	}
+	for oldOK || newOK {
+		var diff *manifestDiff
+		advanceOld, advanceNew := false, false
+		switch {
+		case !newOK || (oldOK && oldEntry.key < newEntry.key):
+			diff = &manifestDiff{key: oldEntry.key, diffType: manifestKeyRemoved}
+			advanceOld = true
+		case !oldOK || newEntry.key < oldEntry.key:
+			diff = &manifestDiff{key: newEntry.key, etag: newEntry.etag, diffType: manifestKeyAdded}
+			advanceNew = true
+		default:
+			if oldEntry.etag != newEntry.etag {
+				diff = &manifestDiff{key: newEntry.key, etag: newEntry.etag, diffType: manifestKeyChanged}
+			}
+			advanceOld, advanceNew = true, true
+		}
+		if diff != nil {
+			if err = diffFn(*diff); err != nil {
+				return err
+			}
+		}
+		if advanceOld {
+			prev := oldEntry
+			if oldEntry, oldOK, err = nextSortedEntry(oldListing, &prev); err != nil {
+				return err
+			}
+		}
+		if advanceNew {
+			prev := newEntry
+			if newEntry, newOK, err = nextSortedEntry(newListing, &prev); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test diffManifest for added, removed, changed and unchanged keys.
+func TestDiffManifest(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+
+	// Current etags of the objects in the bucket.
+	etags := map[string]string{
+		"a/b":   "etag-ab",
+		"a/c":   "etag-ac-new",
+		"d/e/f": "etag-def",
+		"g":     "etag-g",
+	}
+	var files []string
+	for file := range etags {
+		files = append(files, file)
+	}
+	if err = createNamespace(disk, volume, files); err != nil {
+		t.Fatal(err)
+	}
+
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	statEntry := func(bucket, entry string) (treeWalkEntryInfo, error) {
+		return treeWalkEntryInfo{etag: etags[entry]}, nil
+	}
+
+	// Manifest as recorded by a previous sync, deliberately unsorted.
+	manifest := []manifestEntry{
+		{"g", "etag-g"},
+		{"a/c", "etag-ac-old"},
+		{"a/b", "etag-ab"},
+		{"a/a", "etag-aa"},
+		{"z", "etag-z"},
+	}
+
+	testCases := []struct {
+		prefix   string
+		expected []manifestDiff
+	}{
+		{"", []manifestDiff{
+			{key: "a/a", diffType: manifestKeyRemoved},
+			{key: "a/c", etag: "etag-ac-new", diffType: manifestKeyChanged},
+			{key: "d/e/f", etag: "etag-def", diffType: manifestKeyAdded},
+			{key: "z", diffType: manifestKeyRemoved},
+		}},
+		{"a/", []manifestDiff{
+			{key: "a/a", diffType: manifestKeyRemoved},
+			{key: "a/c", etag: "etag-ac-new", diffType: manifestKeyChanged},
+		}},
+		// Nothing under prefix, all manifest keys under it are removed.
+		{"z", []manifestDiff{
+			{key: "z", diffType: manifestKeyRemoved},
+		}},
+		// Nothing differs.
+		{"g", nil},
+	}
+	for i, testCase := range testCases {
+		var diffs []manifestDiff
+		err = diffManifest(volume, testCase.prefix, manifest, listDir, isLeaf, statEntry, func(diff manifestDiff) error {
+			diffs = append(diffs, diff)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, diffs) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, diffs)
+		}
+	}
+
+	// Etags can not be compared without statEntry.
+	err = diffManifest(volume, "", manifest, listDir, isLeaf, nil, func(diff manifestDiff) error {
+		t.Errorf("Unexpected diff %v", diff)
+		return nil
+	})
+	if errorCause(err) != errInvalidArgument {
+		t.Errorf("Expected %s, got %v", errInvalidArgument, err)
+	}
+}
+
+// Returns a channel streaming entries.
+func streamManifestEntries(entries []manifestEntry) <-chan manifestEntry {
+	entryCh := make(chan manifestEntry, len(entries))
+	for _, entry := range entries {
+		entryCh <- entry
+	}
+	close(entryCh)
+	return entryCh
+}
+
+// Test DiffListings between two synthetic listings.
+func TestDiffListings(t *testing.T) {
+	testCases := []struct {
+		oldListing  []manifestEntry
+		newListing  []manifestEntry
+		expected    []manifestDiff
+		expectedErr error
+	}{
+		{
+			oldListing: []manifestEntry{{"a", "1"}, {"b", "2"}, {"d", "4"}, {"e", "5"}},
+			newListing: []manifestEntry{{"b", "2"}, {"c", "3"}, {"d", "4-new"}, {"f", "6"}},
+			expected: []manifestDiff{
+				{key: "a", diffType: manifestKeyRemoved},
+				{key: "c", etag: "3", diffType: manifestKeyAdded},
+				{key: "d", etag: "4-new", diffType: manifestKeyChanged},
+				{key: "e", diffType: manifestKeyRemoved},
+				{key: "f", etag: "6", diffType: manifestKeyAdded},
+			},
+		},
+		// Identical listings.
+		{
+			oldListing: []manifestEntry{{"a", "1"}, {"b/c", "2"}},
+			newListing: []manifestEntry{{"a", "1"}, {"b/c", "2"}},
+		},
+		// Empty listings.
+		{
+			newListing: []manifestEntry{{"a", "1"}},
+			expected:   []manifestDiff{{key: "a", etag: "1", diffType: manifestKeyAdded}},
+		},
+		{
+			oldListing: []manifestEntry{{"a", "1"}},
+			expected:   []manifestDiff{{key: "a", diffType: manifestKeyRemoved}},
+		},
+		// Unsorted listing.
+		{
+			oldListing: []manifestEntry{{"a", "1"}},
+			newListing: []manifestEntry{{"b", "1"}, {"a", "1"}},
+			expected: []manifestDiff{
+				{key: "a", diffType: manifestKeyRemoved},
+				{key: "b", etag: "1", diffType: manifestKeyAdded},
+			},
+			expectedErr: errUnsortedListing,
+		},
+	}
+	for i, testCase := range testCases {
+		var diffs []manifestDiff
+		err := DiffListings(streamManifestEntries(testCase.oldListing), streamManifestEntries(testCase.newListing), func(diff manifestDiff) error {
+			diffs = append(diffs, diff)
+			return nil
+		})
+		if errorCause(err) != testCase.expectedErr {
+			t.Fatalf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, diffs) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, diffs)
+		}
+	}
+}
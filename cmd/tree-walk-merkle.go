@@ -0,0 +1,158 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"sort"
+
+	"golang.org/x/net/context"
+)
+
+// errKeyNotInListing - key is not part of the listing.
+var errKeyNotInListing = errors.New("Key not found in listing")
+
+// Leaf and interior node hashes are domain separated so that an interior
+// node can never be passed off as a leaf.
+func merkleLeafHash(key, etag string) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	// Keys can not contain a NUL byte, hence key and etag can not be shifted into each other.
+	h.Write([]byte{0})
+	h.Write([]byte(etag))
+	return h.Sum(nil)
+}
+
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleProofStep - sibling hash on the path from a leaf to the root.
+type merkleProofStep struct {
+	hash []byte
+	left bool // Set if the sibling is the left child.
+}
+
+// listingMerkleTree - Merkle tree over the (key, etag) pairs of a listing
+// in key order. levels[0] holds the leaf hashes and the last level holds
+// the root, a node without a sibling is carried up unchanged.
+type listingMerkleTree struct {
+	keys   []string
+	levels [][][]byte
+}
+
+// buildListingMerkleTree - walks all objects under prefix and returns the
+// Merkle tree of their keys and etags, an empty listing has a nil root.
+// The whole tree is held in memory, errWalkMemoryBudget is returned if it
+// outgrows budget.
+func buildListingMerkleTree(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc, budget walkMemoryBudget) (*listingMerkleTree, error) {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+
+	tree := &listingMerkleTree{}
+	var leaves [][]byte
+	var treeBytes int64
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return nil, walkResult.err
+		}
+		info, err := objInfo(bucket, walkResult.entry)
+		if err != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(err) == errFileNotFound {
+				continue
+			}
+			return nil, err
+		}
+		tree.keys = append(tree.keys, info.Name)
+		leaves = append(leaves, merkleLeafHash(info.Name, info.MD5Sum))
+		// The key, its leaf hash and about as many interior node hashes.
+		treeBytes += int64(len(info.Name)) + 2*sha256.Size + 3*walkBufferEntryOverhead
+		if !budget.allows(treeBytes) {
+			return nil, traceError(errWalkMemoryBudget)
+		}
+	}
+	if len(leaves) == 0 {
+		return tree, nil
+	}
+	tree.levels = append(tree.levels, leaves)
+	for level := leaves; len(level) > 1; {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+	return tree, nil
+}
+
+// MerkleRoot - returns the root hash of the listing.
+func (t *listingMerkleTree) MerkleRoot() []byte {
+	if len(t.levels) == 0 {
+		return nil
+	}
+	return t.levels[len(t.levels)-1][0]
+}
+
+// InclusionProof - returns the sibling hashes proving key is part of the
+// listing, see verifyInclusionProof().
+func (t *listingMerkleTree) InclusionProof(key string) ([]merkleProofStep, error) {
+	i := sort.SearchStrings(t.keys, key)
+	if i == len(t.keys) || t.keys[i] != key {
+		return nil, traceError(errKeyNotInListing)
+	}
+	var proof []merkleProofStep
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if i%2 == 1 {
+			proof = append(proof, merkleProofStep{hash: level[i-1], left: true})
+		} else if i+1 < len(level) {
+			proof = append(proof, merkleProofStep{hash: level[i+1]})
+		}
+		i /= 2
+	}
+	return proof, nil
+}
+
+// verifyInclusionProof - returns true if proof shows that key with etag
+// is part of the listing with Merkle root.
+func verifyInclusionProof(root []byte, key, etag string, proof []merkleProofStep) bool {
+	hash := merkleLeafHash(key, etag)
+	for _, step := range proof {
+		if step.left {
+			hash = merkleNodeHash(step.hash, hash)
+		} else {
+			hash = merkleNodeHash(hash, step.hash)
+		}
+	}
+	return bytes.Equal(hash, root)
+}
@@ -0,0 +1,82 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Test inclusion proofs of listings of various sizes.
+func TestListingMerkleTree(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object, MD5Sum: "etag-" + object}, nil
+	}
+	// Odd and even numbers of objects exercise carried up nodes.
+	for _, numObjects := range []int{1, 2, 3, 5, 8} {
+		dirs := map[string][]string{"": {"dir/"}}
+		for i := 0; i < numObjects; i++ {
+			dirs["dir/"] = append(dirs["dir/"], fmt.Sprintf("obj%d", i))
+		}
+		listDir := listDirFactory(isLeaf, &listDirDisk{dirs: dirs})
+		tree, err := buildListingMerkleTree(volume, "", listDir, isLeaf, objInfo, walkMemoryBudget{})
+		if err != nil {
+			t.Fatalf("%d objects: Unexpected error %s", numObjects, err)
+		}
+		root := tree.MerkleRoot()
+		for i := 0; i < numObjects; i++ {
+			key := fmt.Sprintf("dir/obj%d", i)
+			proof, err := tree.InclusionProof(key)
+			if err != nil {
+				t.Fatalf("%d objects: Unexpected error %s", numObjects, err)
+			}
+			if !verifyInclusionProof(root, key, "etag-"+key, proof) {
+				t.Errorf("%d objects: Expected proof of %s to validate", numObjects, key)
+			}
+			// Modified key or etag invalidates the proof.
+			if verifyInclusionProof(root, key+"x", "etag-"+key, proof) {
+				t.Errorf("%d objects: Expected proof of modified key %s to fail", numObjects, key)
+			}
+			if verifyInclusionProof(root, key, "modified", proof) {
+				t.Errorf("%d objects: Expected proof of %s with modified etag to fail", numObjects, key)
+			}
+		}
+		if _, err = tree.InclusionProof("missing"); errorCause(err) != errKeyNotInListing {
+			t.Errorf("%d objects: Expected %s, got %v", numObjects, errKeyNotInListing, err)
+		}
+
+		// Root changes with the listing.
+		dirs["dir/"] = append(dirs["dir/"], "objz")
+		tree, err = buildListingMerkleTree(volume, "", listDir, isLeaf, objInfo, walkMemoryBudget{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(root, tree.MerkleRoot()) {
+			t.Errorf("%d objects: Expected root to change with an added object", numObjects)
+		}
+
+		// The tree outgrows a budget smaller than a single object.
+		if _, err = buildListingMerkleTree(volume, "", listDir, isLeaf, objInfo, walkMemoryBudget{maxBytes: 64}); errorCause(err) != errWalkMemoryBudget {
+			t.Errorf("%d objects: Expected error %v, got %v", numObjects, errWalkMemoryBudget, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "golang.org/x/net/context"
+
+// startMultiTreeWalk - walks every prefix in prefixes like startTreeWalk()
+// and merges their results into one sorted result channel. Keys beneath
+// more than one of the prefixes, e.g. with prefixes "a/" and "a/b", are
+// sent once. Unlike its marker, marker here need not be beneath every
+// prefix, each walk starts after it. Closing endWalkCh ends all the walks.
+// A prefix with nothing under it is skipped, the first other error
+// received from any of the walks ends the merged walk. Like with
+// startTreeWalk() endWalkCh may be nil for walks only cancelled via ctx.
+func startMultiTreeWalk(ctx context.Context, bucket string, prefixes []string, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, endWalkCh chan struct{}) chan treeWalkResult {
+	resultCh := make(chan treeWalkResult, maxObjectList)
+	walkCtx, cancel := context.WithCancel(ctx)
+	// The walks are ended once the merged walk is, even if endWalkCh is nil.
+	walksEndCh := make(chan struct{})
+	walkResultChs := make([]chan treeWalkResult, len(prefixes))
+	for i, prefix := range prefixes {
+		opts := treeWalkOpts{startAfter: marker}
+		walkResultChs[i] = startTreeWalkWithOpts(walkCtx, bucket, prefix, "", recursive, listDir, isLeaf, walksEndCh, opts)
+	}
+	go func() {
+		select {
+		case <-endWalkCh:
+			cancel()
+		case <-walkCtx.Done():
+		}
+	}()
+	go func() {
+		defer cancel()
+		defer close(walksEndCh)
+		defer close(resultCh)
+
+		// heads[i] is the next result of walkResultChs[i], nil once the walk is done.
+		heads := make([]*treeWalkResult, len(walkResultChs))
+		next := func(i int) {
+			heads[i] = nil
+			walkResult, ok := <-walkResultChs[i]
+			if !ok {
+				return
+			}
+			// Nothing under the prefix, the walk is done.
+			if errorCause(walkResult.err) == errFileNotFound {
+				return
+			}
+			heads[i] = &walkResult
+		}
+		// send - returns false if the result was not sent since ctx is
+		// done or the consumer is gone.
+		send := func(result treeWalkResult) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-endWalkCh:
+				return false
+			case resultCh <- result:
+				return true
+			}
+		}
+		for i := range walkResultChs {
+			next(i)
+		}
+	mergeLoop:
+		for {
+			var result *treeWalkResult
+			for _, head := range heads {
+				if head == nil {
+					continue
+				}
+				if head.err != nil {
+					if ctx.Err() == nil && send(treeWalkResult{err: head.err, end: true}) {
+						return
+					}
+					break mergeLoop
+				}
+				if result == nil || head.entry < result.entry {
+					result = head
+				}
+			}
+			if result == nil {
+				// All the walks are done.
+				return
+			}
+			entry, isDir := result.entry, result.isDir
+			// Advance every walk at entry so that it is sent only once.
+			end := true
+			for i, head := range heads {
+				if head != nil && head.entry == entry {
+					next(i)
+				}
+				if heads[i] != nil {
+					end = false
+				}
+			}
+			if !send(treeWalkResult{entry: entry, isDir: isDir, end: end}) {
+				break
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// Cancelled by the caller, tell the consumer why like
+			// startTreeWalk() does.
+			select {
+			case <-endWalkCh:
+			case resultCh <- treeWalkResult{err: traceError(ctxErr), end: true}:
+			}
+		}
+	}()
+	return resultCh
+}
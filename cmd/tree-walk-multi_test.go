@@ -0,0 +1,114 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// Test merging the walks of several prefixes.
+func TestStartMultiTreeWalk(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":     {"a/", "b/", "c"},
+		"a/":   {"b/", "bar", "foo"},
+		"a/b/": {"x", "y"},
+		"b/":   {"z"},
+	}})
+	testCases := []struct {
+		prefixes  []string
+		marker    string
+		recursive bool
+		expected  []string
+	}{
+		// Overlapping prefixes.
+		{[]string{"a/b", "a/", "a/b/"}, "", true, []string{"a/b/x", "a/b/y", "a/bar", "a/foo"}},
+		{[]string{"b/", "a/b/", "a/f"}, "", true, []string{"a/b/x", "a/b/y", "a/foo", "b/z"}},
+		{[]string{"c", "", "a/"}, "", true, []string{"a/b/x", "a/b/y", "a/bar", "a/foo", "b/z", "c"}},
+		{[]string{"a/", "a/b", ""}, "", false, []string{"a/", "a/b/", "a/bar", "a/foo", "b/", "c"}},
+		// Marker beneath some of the prefixes only.
+		{[]string{"a/b/", "b/", "a/f"}, "a/b/x", true, []string{"a/b/y", "a/foo", "b/z"}},
+		{[]string{"a/b/", "b/", "a/f"}, "a/c", true, []string{"a/foo", "b/z"}},
+		// Nothing to walk.
+		{nil, "", true, nil},
+		{[]string{"d", "e"}, "", true, nil},
+		// Prefixes with nothing under them are skipped.
+		{[]string{"a/", "zz/"}, "", true, []string{"a/b/x", "a/b/y", "a/bar", "a/foo"}},
+		{[]string{"zz/", "b/", "a/b/c/"}, "", true, []string{"b/z"}},
+		{[]string{"zz/", "yy/"}, "", false, nil},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		var end bool
+		for res := range startMultiTreeWalk(context.Background(), volume, testCase.prefixes, testCase.marker, testCase.recursive, listDir, isLeaf, endWalkCh) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			if end {
+				t.Errorf("Test %d: Unexpected %q after the end", i+1, res.entry)
+			}
+			end = res.end
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if len(entries) > 0 && !end {
+			t.Errorf("Test %d: Expected the last entry to be the end", i+1)
+		}
+	}
+
+	// The first other error ends the merged walk.
+	errFaultyListDir := errors.New("faulty listDir")
+	faultyListDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		if prefixDir == "b/" {
+			return nil, false, traceError(errFaultyListDir)
+		}
+		return listDir(bucket, prefixDir, prefixEntry)
+	}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	var errs []error
+	for res := range startMultiTreeWalk(context.Background(), volume, []string{"a/", "b/"}, "", true, faultyListDir, isLeaf, endWalkCh) {
+		if res.err != nil {
+			errs = append(errs, res.err)
+		}
+	}
+	if len(errs) != 1 || errorCause(errs[0]) != errFaultyListDir {
+		t.Errorf("Expected a single %v, got %v", errFaultyListDir, errs)
+	}
+
+	// Cancelled walks without endWalkCh end with the context's error.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var results []treeWalkResult
+	for res := range startMultiTreeWalk(ctx, volume, []string{"a/", "b/"}, "", true, listDir, isLeaf, nil) {
+		results = append(results, res)
+	}
+	if len(results) != 1 || errorCause(results[0].err) != context.Canceled {
+		t.Errorf("Expected a single %v, got %v", context.Canceled, results)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	// Compressed output is flushed at least this often so that readers
+	// of a slow walk are not kept waiting on the compressor.
+	walkGzipFlushInterval = time.Second
+	// A new gzip member is started after this many entries so that the
+	// compressor state of long lived streams is reset.
+	walkGzipResetEntries = 100000
+)
+
+// walkNDJSONEntry - a line of NDJSON encoded walk results.
+type walkNDJSONEntry struct {
+	Key string `json:"key,omitempty"`
+	Err string `json:"error,omitempty"`
+}
+
+// encodeWalkNDJSON - writes the walk results of resultCh to w as newline
+// delimited JSON, one line per entry. A walk error is written as a line
+// of its own and returned, heartbeats and summaries are not written.
+func encodeWalkNDJSON(w io.Writer, resultCh chan treeWalkResult) error {
+	encoder := json.NewEncoder(w)
+	for walkResult := range resultCh {
+		if err := encodeWalkResultNDJSON(encoder, walkResult); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes a single walk result, returns the walk error if any.
+func encodeWalkResultNDJSON(encoder *json.Encoder, walkResult treeWalkResult) error {
+	if walkResult.heartbeat || walkResult.summary != nil {
+		return nil
+	}
+	if walkResult.err != nil {
+		if err := encoder.Encode(walkNDJSONEntry{Err: errorCause(walkResult.err).Error()}); err != nil {
+			return traceError(err)
+		}
+		return walkResult.err
+	}
+	if err := encoder.Encode(walkNDJSONEntry{Key: walkResult.entry}); err != nil {
+		return traceError(err)
+	}
+	return nil
+}
+
+// EncodeWalkGzipNDJSON - writes the walk results of resultCh to w like
+// encodeWalkNDJSON() but gzip compressed as they stream. The output is
+// flushed every walkGzipFlushInterval and a new gzip member is started
+// every walkGzipResetEntries entries, gzip readers read across members.
+func EncodeWalkGzipNDJSON(w io.Writer, resultCh chan treeWalkResult) error {
+	return encodeWalkGzipNDJSON(w, resultCh, walkGzipFlushInterval, walkGzipResetEntries)
+}
+
+func encodeWalkGzipNDJSON(w io.Writer, resultCh chan treeWalkResult, flushInterval time.Duration, resetEntries int) (err error) {
+	gzipWriter := gzip.NewWriter(w)
+	defer func() {
+		// Complete the gzip member even on errors, so that everything
+		// written so far can be decompressed.
+		if cErr := gzipWriter.Close(); cErr != nil && err == nil {
+			err = traceError(cErr)
+		}
+	}()
+	encoder := json.NewEncoder(gzipWriter)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var entries int
+	for {
+		select {
+		case <-ticker.C:
+			if err = gzipWriter.Flush(); err != nil {
+				return traceError(err)
+			}
+		case walkResult, ok := <-resultCh:
+			if !ok {
+				return nil
+			}
+			if err = encodeWalkResultNDJSON(encoder, walkResult); err != nil {
+				return err
+			}
+			entries++
+			if entries%resetEntries != 0 {
+				continue
+			}
+			if err = gzipWriter.Close(); err != nil {
+				return traceError(err)
+			}
+			gzipWriter.Reset(w)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Test gzip compressed NDJSON decompresses to the uncompressed NDJSON.
+func TestEncodeWalkGzipNDJSON(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b/", "c"},
+		"b/": {"d", "e"},
+	}}
+	listDir := listDirFactory(isLeaf, disk)
+
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	var expected bytes.Buffer
+	if err := encodeWalkNDJSON(&expected, startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh)); err != nil {
+		t.Fatal(err)
+	}
+	expectedNDJSON := `{"key":"a"}
+{"key":"b/d"}
+{"key":"b/e"}
+{"key":"c"}
+`
+	if expected.String() != expectedNDJSON {
+		t.Fatalf("Expected %q, got %q", expectedNDJSON, expected.String())
+	}
+
+	// Resetting every 2 entries writes multiple gzip members.
+	for _, resetEntries := range []int{walkGzipResetEntries, 2} {
+		var compressed bytes.Buffer
+		resultCh := startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh)
+		if err := encodeWalkGzipNDJSON(&compressed, resultCh, time.Millisecond, resetEntries); err != nil {
+			t.Fatal(err)
+		}
+		gzipReader, err := gzip.NewReader(&compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(gzipReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected.Bytes(), got) {
+			t.Errorf("Reset every %d: Expected %q, got %q", resetEntries, expected.String(), string(got))
+		}
+	}
+
+	// Walk errors are written and returned.
+	var compressed bytes.Buffer
+	resultCh := startTreeWalk(context.Background(), volume, "missing/", "", true, listDir, isLeaf, endWalkCh)
+	if err := EncodeWalkGzipNDJSON(&compressed, resultCh); errorCause(err) != errFileNotFound {
+		t.Fatalf("Expected %s, got %v", errFileNotFound, err)
+	}
+	gzipReader, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expectedErr := `{"error":"` + errFileNotFound.Error() + `"}` + "\n"; string(got) != expectedErr {
+		t.Errorf("Expected %q, got %q", expectedErr, string(got))
+	}
+}
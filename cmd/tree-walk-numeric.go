@@ -0,0 +1,54 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// errNoCaptureGroup - numeric key pattern has no capture group.
+var errNoCaptureGroup = errors.New("Pattern needs a capture group for the number")
+
+// byKeyNumberRange - returns a filter matching objects whose name holds a
+// number within [min, max], the number is the first capture group of
+// pattern, e.g. `part-(\d+)$`. Numbers are compared numerically so
+// "part-00042" and "part-42" are both 42 and fall between 9 and 100
+// whatever their padding. Names which do not match pattern or whose
+// captured text is not a number never match.
+func byKeyNumberRange(pattern string, min, max int64) (func(ObjectInfo) bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, traceError(err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, traceError(errNoCaptureGroup)
+	}
+	filter := func(info ObjectInfo) bool {
+		match := re.FindStringSubmatch(info.Name)
+		if match == nil {
+			return false
+		}
+		n, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return false
+		}
+		return n >= min && n <= max
+	}
+	return filter, nil
+}
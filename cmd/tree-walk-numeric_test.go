@@ -0,0 +1,68 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test filtering keys by the number embedded in them.
+func TestByKeyNumberRange(t *testing.T) {
+	names := []string{
+		"logs/part-00009",
+		"logs/part-00042",
+		"logs/part-100",
+		"logs/part-150",
+		"logs/part-0200",
+		"logs/part-201",
+		"logs/part-abc",
+		"logs/part-99999999999999999999", // Overflows.
+		"logs/other-150",
+	}
+	testCases := []struct {
+		pattern     string
+		min, max    int64
+		expected    []string
+		expectedErr bool
+	}{
+		{`part-(\d+)$`, 100, 200, []string{"logs/part-100", "logs/part-150", "logs/part-0200"}, false},
+		{`part-(\d+)$`, 9, 42, []string{"logs/part-00009", "logs/part-00042"}, false},
+		{`-(\d+)$`, 150, 150, []string{"logs/part-150", "logs/other-150"}, false},
+		{`part-(\w+)$`, 0, 10, []string{"logs/part-00009"}, false},
+		{`part-\d+`, 0, 10, nil, true},
+		{`part-(\d+`, 0, 10, nil, true},
+	}
+	for i, testCase := range testCases {
+		filter, err := byKeyNumberRange(testCase.pattern, testCase.min, testCase.max)
+		if (err != nil) != testCase.expectedErr {
+			t.Fatalf("Test %d: Unexpected error %v", i+1, err)
+		}
+		if err != nil {
+			continue
+		}
+		var got []string
+		for _, name := range names {
+			if filter(ObjectInfo{Name: name}) {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// Version of the continuation tokens issued by listObjectsPaged(), to be
+// bumped whenever the token layout or its meaning changes.
+const pagedListTokenVersion = 2
+
+// errListTokenVersion - continuation token was issued by another version.
+var errListTokenVersion = errors.New("List continuation token version is not supported")
+
+// pagedListToken - listing state encoded in continuation tokens.
+type pagedListToken struct {
+	Version   int    `json:"v"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	Recursive bool   `json:"recursive"`
+	Marker    string `json:"marker"` // Last entry returned.
+}
+
+// Returns the opaque continuation token of state.
+func encodePagedListToken(state pagedListToken) (string, error) {
+	state.Version = pagedListTokenVersion
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return "", traceError(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(stateBytes), nil
+}
+
+// Returns the state encoded in token, tokens of other versions are
+// rejected with errListTokenVersion whatever their layout is.
+func decodePagedListToken(token string) (pagedListToken, error) {
+	stateBytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pagedListToken{}, traceError(errInvalidListToken)
+	}
+	var version struct {
+		Version int `json:"v"`
+	}
+	if err = json.Unmarshal(stateBytes, &version); err != nil {
+		return pagedListToken{}, traceError(errInvalidListToken)
+	}
+	if version.Version != pagedListTokenVersion {
+		return pagedListToken{}, traceError(errListTokenVersion)
+	}
+	var state pagedListToken
+	if err = json.Unmarshal(stateBytes, &state); err != nil {
+		return pagedListToken{}, traceError(errInvalidListToken)
+	}
+	return state, nil
+}
+
+// listObjectsPaged - lists upto limit entries under prefix resuming after
+// the entry encoded in token, an empty token starts a new listing. If the
+// listing is truncated the walk is parked in pool and the returned
+// nextToken resumes it, so the next page carries on from where this one
+// stopped instead of listing the directories already returned again.
+func listObjectsPaged(bucket, prefix, token string, limit int, recursive bool, pool *treeWalkPool, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc) (result ListObjectsInfo, nextToken string, err error) {
+	marker := ""
+	if token != "" {
+		state, err := decodePagedListToken(token)
+		if err != nil {
+			return ListObjectsInfo{}, "", err
+		}
+		// Token can only resume the listing it was issued for.
+		if state.Bucket != bucket || state.Prefix != prefix || state.Recursive != recursive {
+			return ListObjectsInfo{}, "", traceError(errInvalidListToken)
+		}
+		marker = state.Marker
+	}
+	if limit <= 0 || limit > maxObjectList {
+		limit = maxObjectList
+	}
+
+	heal := false
+	walkResultCh, endWalkCh := pool.Release(listParams{bucket, recursive, marker, prefix, heal})
+	if walkResultCh == nil {
+		endWalkCh = make(chan struct{})
+		walkResultCh = startTreeWalk(context.Background(), bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh)
+	}
+
+	eof := false
+	for i := 0; i < limit; {
+		walkResult, ok := <-walkResultCh
+		if !ok {
+			eof = true
+			break
+		}
+		if walkResult.err != nil {
+			close(endWalkCh)
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				return ListObjectsInfo{}, "", nil
+			}
+			return ListObjectsInfo{}, "", walkResult.err
+		}
+		entry := walkResult.entry
+		marker = entry
+		if walkResult.isDir {
+			result.Prefixes = append(result.Prefixes, entry)
+		} else {
+			info, oErr := objInfo(bucket, entry)
+			if oErr != nil {
+				if errorCause(oErr) != errFileNotFound {
+					close(endWalkCh)
+					return ListObjectsInfo{}, "", oErr
+				}
+				// Object was removed after it was listed.
+				if walkResult.end {
+					eof = true
+					break
+				}
+				continue
+			}
+			result.Objects = append(result.Objects, info)
+		}
+		i++
+		if walkResult.end {
+			eof = true
+			break
+		}
+	}
+	if eof {
+		return result, "", nil
+	}
+
+	nextToken, err = encodePagedListToken(pagedListToken{
+		Bucket:    bucket,
+		Prefix:    prefix,
+		Recursive: recursive,
+		Marker:    marker,
+	})
+	if err != nil {
+		close(endWalkCh)
+		return ListObjectsInfo{}, "", err
+	}
+	pool.Set(listParams{bucket, recursive, marker, prefix, heal}, walkResultCh, endWalkCh)
+	result.IsTruncated = true
+	result.NextMarker = marker
+	return result, nextToken, nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test pages resume in the middle of a deep subtree without listing any
+// directory twice.
+func TestListObjectsPaged(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":         {"a/", "z"},
+		"a/":       {"b/", "y"},
+		"a/b/":     {"c/", "x"},
+		"a/b/c/":   {"1", "2", "3", "d/"},
+		"a/b/c/d/": {"4", "5"},
+	}
+	var mu sync.Mutex
+	listed := make(map[string]int)
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		mu.Lock()
+		listed[prefixDir]++
+		mu.Unlock()
+		return dirs[prefixDir], true, nil
+	}
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object}, nil
+	}
+
+	pool := newTreeWalkPool(time.Minute)
+	var keys []string
+	var token string
+	for page := 0; ; page++ {
+		result, nextToken, err := listObjectsPaged(volume, "", token, 2, true, pool, listDir, isLeaf, objInfo)
+		if err != nil {
+			t.Fatalf("Page %d: Unexpected error %s", page+1, err)
+		}
+		for _, info := range result.Objects {
+			keys = append(keys, info.Name)
+		}
+		if nextToken == "" {
+			if result.IsTruncated {
+				t.Errorf("Page %d: Expected a token for a truncated page", page+1)
+			}
+			break
+		}
+		token = nextToken
+	}
+	expected := []string{"a/b/c/1", "a/b/c/2", "a/b/c/3", "a/b/c/d/4", "a/b/c/d/5", "a/b/x", "a/y", "z"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected %v, got %v", expected, keys)
+	}
+	for dir, count := range listed {
+		if count != 1 {
+			t.Errorf("Expected %q to be listed once, listed %d times", dir, count)
+		}
+	}
+
+	// A token resumes the listing even once its walk is gone from the pool.
+	result, _, err := listObjectsPaged(volume, "", token, 2, true, newTreeWalkPool(time.Minute), listDir, isLeaf, objInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Objects) != 2 || result.Objects[0].Name != "a/y" || result.Objects[1].Name != "z" {
+		t.Errorf("Expected [a/y z], got %v", result.Objects)
+	}
+
+	testCases := []struct {
+		prefix      string
+		token       string
+		expectedErr error
+	}{
+		// Token is not base64.
+		{"", "!", errInvalidListToken},
+		// Token is not JSON.
+		{"", base64.RawURLEncoding.EncodeToString([]byte("junk")), errInvalidListToken},
+		// Token of another version.
+		{"", base64.RawURLEncoding.EncodeToString([]byte(`{"v":0,"marker":"a"}`)), errListTokenVersion},
+		{"", base64.RawURLEncoding.EncodeToString([]byte(`{"v":3,"layout":"new"}`)), errListTokenVersion},
+		// Token of another listing.
+		{"a/", token, errInvalidListToken},
+	}
+	for i, testCase := range testCases {
+		_, _, err = listObjectsPaged(volume, testCase.prefix, testCase.token, 2, true, pool, listDir, isLeaf, objInfo)
+		if errorCause(err) != testCase.expectedErr {
+			t.Errorf("Test %d: Expected %s, got %v", i+1, testCase.expectedErr, err)
+		}
+	}
+	// Token of the same listing in another bucket.
+	if _, _, err = listObjectsPaged("other-bucket", "", token, 2, true, pool, listDir, isLeaf, objInfo); errorCause(err) != errInvalidListToken {
+		t.Errorf("Expected %s, got %v", errInvalidListToken, err)
+	}
+}
+
+// Test paged listing on both backends.
+func TestObjectLayerListObjectsPaged(t *testing.T) {
+	ExecObjectLayerTest(t, testObjectLayerListObjectsPaged)
+}
+
+func testObjectLayerListObjectsPaged(obj ObjectLayer, instanceType string, t TestErrHandler) {
+	bucket := "bucket"
+	err := obj.MakeBucket(bucket)
+	if err != nil {
+		t.Fatalf("%s: %s", instanceType, err)
+	}
+	objects := []string{"a", "b/c/d/e", "b/c/d/f", "b/c/g", "h"}
+	for _, object := range objects {
+		_, err = obj.PutObject(bucket, object, int64(len(object)), bytes.NewBufferString(object), nil)
+		if err != nil {
+			t.Fatalf("%s: %s", instanceType, err)
+		}
+	}
+
+	var listed []string
+	var token string
+	for {
+		result, nextToken, err := obj.ListObjectsPaged(bucket, "", token, "", 2)
+		if err != nil {
+			t.Fatalf("%s: %s", instanceType, err)
+		}
+		for _, objInfo := range result.Objects {
+			listed = append(listed, objInfo.Name)
+		}
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	if !reflect.DeepEqual(objects, listed) {
+		t.Errorf("%s: Expected %v, got %v", instanceType, objects, listed)
+	}
+
+	if _, _, err = obj.ListObjectsPaged("missing-bucket", "", "", "", 2); err == nil {
+		t.Errorf("%s: Expected an error listing a missing bucket", instanceType)
+	}
+
+	// Delimited listings return common prefixes.
+	var prefixes []string
+	listed, token = nil, ""
+	for {
+		result, nextToken, err := obj.ListObjectsPaged(bucket, "", token, slashSeparator, 2)
+		if err != nil {
+			t.Fatalf("%s: %s", instanceType, err)
+		}
+		for _, objInfo := range result.Objects {
+			listed = append(listed, objInfo.Name)
+		}
+		prefixes = append(prefixes, result.Prefixes...)
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	if !reflect.DeepEqual(listed, []string{"a", "h"}) || !reflect.DeepEqual(prefixes, []string{"b/"}) {
+		t.Errorf("%s: Expected [a h] and [b/], got %v and %v", instanceType, listed, prefixes)
+	}
+	if _, _, err = obj.ListObjectsPaged(bucket, "", "", "*", 2); errorCause(err) != (UnsupportedDelimiter{Delimiter: "*"}) {
+		t.Errorf("%s: Expected %s, got %v", instanceType, UnsupportedDelimiter{Delimiter: "*"}, err)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// partitionSpec - describes the template of partitioned keys like
+// "logs/year=2023/month=04/day=01/file", here root is "logs/" and
+// fields are "year", "month" and "day" in that order. Each field is
+// a directory level of the form "field=value/".
+type partitionSpec struct {
+	root   string
+	fields []string
+}
+
+// partitionRange - inclusive range of values of a partition field, an
+// empty min or max leaves that end unbounded. Values which are both
+// integers are compared numerically so "4" and "04" are equal and
+// "10" is greater than "9", all other values are compared as strings.
+type partitionRange struct {
+	field string
+	min   string
+	max   string
+}
+
+// Compares partition values a and b, returns -1, 0 or 1.
+func comparePartitionValues(a, b string) int {
+	ai, aErr := strconv.ParseInt(a, 10, 64)
+	bi, bErr := strconv.ParseInt(b, 10, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// Returns true if value is within the range.
+func (r partitionRange) contains(value string) bool {
+	if r.min != "" && comparePartitionValues(value, r.min) < 0 {
+		return false
+	}
+	if r.max != "" && comparePartitionValues(value, r.max) > 0 {
+		return false
+	}
+	return true
+}
+
+// Returns the value of field if the directory entry is of the form "field=value/".
+func parsePartitionEntry(field, entry string) (value string, ok bool) {
+	if !strings.HasSuffix(entry, slashSeparator) || !strings.HasPrefix(entry, field+"=") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(entry, field+"="), slashSeparator), true
+}
+
+// partitionValues - returns the partition values of key, ok is false if
+// key does not match the spec.
+func (p partitionSpec) partitionValues(key string) (values map[string]string, ok bool) {
+	if !strings.HasPrefix(key, p.root) {
+		return nil, false
+	}
+	components := strings.SplitAfter(strings.TrimPrefix(key, p.root), slashSeparator)
+	// Partition directories need to be followed by at least one more component.
+	if len(components) <= len(p.fields) {
+		return nil, false
+	}
+	values = make(map[string]string)
+	for i, field := range p.fields {
+		value, ok := parsePartitionEntry(field, components[i])
+		if !ok {
+			return nil, false
+		}
+		values[field] = value
+	}
+	return values, true
+}
+
+// Returns function "listDir" of the type listDirFunc which lists using
+// listDir but only returns partition directories matching spec and whose
+// values are within ranges, hence the walk never descends into partitions
+// out of range. Entries outside of spec.root are returned unfiltered.
+//
+// Since partition directories sort by value, the walk emits all the keys
+// of a partition one after another, consumers can group the results by
+// partitionValues() without buffering.
+func listDirPartitionFactory(spec partitionSpec, ranges []partitionRange, listDir listDirFunc) listDirFunc {
+	partitionListDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
+		entries, delayIsLeaf, err = listDir(bucket, prefixDir, prefixEntry)
+		if err != nil || !strings.HasPrefix(prefixDir, spec.root) {
+			return entries, delayIsLeaf, err
+		}
+		depth := strings.Count(strings.TrimPrefix(prefixDir, spec.root), slashSeparator)
+		if depth >= len(spec.fields) {
+			return entries, delayIsLeaf, nil
+		}
+		field := spec.fields[depth]
+		var filtered []string
+	nextEntry:
+		for _, entry := range entries {
+			value, ok := parsePartitionEntry(field, entry)
+			if !ok {
+				continue
+			}
+			for _, r := range ranges {
+				if r.field == field && !r.contains(value) {
+					continue nextEntry
+				}
+			}
+			filtered = append(filtered, entry)
+		}
+		return filtered, delayIsLeaf, nil
+	}
+	return partitionListDir
+}
@@ -0,0 +1,100 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test filtering a walk by partition field ranges.
+func TestListDirPartition(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+	var files = []string{
+		"logs/junk/file",
+		"logs/year=2022/month=12/file",
+		"logs/year=2023/month=1/file",
+		"logs/year=2023/month=04/file",
+		"logs/year=2023/month=10/file",
+		"logs/year=2023/month=10/day=01/file",
+		"logs/year=2024/month=01/file",
+		"logs/year=2024/orphan",
+	}
+	if err = createNamespace(disk, volume, files); err != nil {
+		t.Fatal(err)
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	spec := partitionSpec{root: "logs/", fields: []string{"year", "month"}}
+
+	testCases := []struct {
+		ranges   []partitionRange
+		expected []string
+	}{
+		// No ranges, only keys matching the spec are listed.
+		{nil, []string{
+			"logs/year=2022/month=12/file",
+			"logs/year=2023/month=04/file",
+			"logs/year=2023/month=1/file",
+			"logs/year=2023/month=10/day=01/file",
+			"logs/year=2023/month=10/file",
+			"logs/year=2024/month=01/file",
+		}},
+		// Numeric month range within a single year.
+		{[]partitionRange{{"year", "2023", "2023"}, {"month", "2", "10"}}, []string{
+			"logs/year=2023/month=04/file",
+			"logs/year=2023/month=10/day=01/file",
+			"logs/year=2023/month=10/file",
+		}},
+		// Open ended ranges.
+		{[]partitionRange{{"year", "2023", ""}, {"month", "", "01"}}, []string{
+			"logs/year=2023/month=1/file",
+			"logs/year=2024/month=01/file",
+		}},
+	}
+	for i, testCase := range testCases {
+		listDir := listDirPartitionFactory(spec, testCase.ranges, listDirFactory(isLeaf, disk))
+		got := walkEntries("logs/", "", true, listDir, isLeaf)
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+		for _, key := range got {
+			if _, ok := spec.partitionValues(key); !ok {
+				t.Errorf("Test %d: Expected %s to match the partition spec", i+1, key)
+			}
+		}
+	}
+
+	values, ok := spec.partitionValues("logs/year=2023/month=04/file")
+	if !ok || !reflect.DeepEqual(values, map[string]string{"year": "2023", "month": "04"}) {
+		t.Errorf("Unexpected partition values %v", values)
+	}
+	if _, ok = spec.partitionValues("logs/year=2024/orphan"); ok {
+		t.Error("Expected key with missing partitions not to match")
+	}
+}
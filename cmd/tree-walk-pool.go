@@ -18,6 +18,7 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -36,12 +37,35 @@ type listParams struct {
 	heal      bool
 }
 
-// errWalkAbort - returned by doTreeWalk() if it returns prematurely.
+// errWalkAbort - returned by doTreeWalk(), wrapped in WalkAborted, if it returns prematurely.
 // doTreeWalk() can return prematurely if
 // 1) treeWalk is timed out by the timer go-routine.
 // 2) there is an error during tree walk.
 var errWalkAbort = errors.New("treeWalk abort")
 
+// WalkAborted - errWalkAbort along with the walk which was aborted, so
+// that aborted walks can be told apart in logs.
+type WalkAborted struct {
+	Bucket string
+	Prefix string
+	Marker string
+	Reason error // Why the walk was aborted, e.g. context.Canceled.
+}
+
+func (e WalkAborted) Error() string {
+	return fmt.Sprintf("%s: bucket %s, prefix %s, marker %s: %v", errWalkAbort, e.Bucket, e.Prefix, e.Marker, e.Reason)
+}
+
+// isWalkAbort - returns true if err is errWalkAbort or WalkAborted.
+func isWalkAbort(err error) bool {
+	err = errorCause(err)
+	if err == errWalkAbort {
+		return true
+	}
+	_, ok := err.(WalkAborted)
+	return ok
+}
+
 // treeWalk - represents the go routine that does the file tree walk.
 type treeWalk struct {
 	resultCh   chan treeWalkResult
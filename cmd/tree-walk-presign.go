@@ -0,0 +1,63 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// urlSignerFunc - returns a presigned GET URL of key and its expiry.
+type urlSignerFunc func(bucket, key string) (url string, expiry time.Time, err error)
+
+// presignedEntry - listed object along with its presigned GET URL.
+type presignedEntry struct {
+	key    string
+	url    string
+	expiry time.Time
+}
+
+// listPresigned - walks all objects under prefix and calls fn with each
+// key along with the URL signer returned for it, e.g. to generate a
+// download manifest in a single pass. If signer fails the walk is
+// aborted with its error, unless skipSignErrors is set in which case the
+// object is left out.
+func listPresigned(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, signer urlSignerFunc, skipSignErrors bool, fn func(presignedEntry) error) error {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				return nil
+			}
+			return walkResult.err
+		}
+		url, expiry, err := signer(bucket, walkResult.entry)
+		if err != nil {
+			if skipSignErrors {
+				continue
+			}
+			return traceError(err)
+		}
+		if err = fn(presignedEntry{key: walkResult.entry, url: url, expiry: expiry}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test every listed object carries the URL of the signer.
+func TestListPresigned(t *testing.T) {
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b/", "secret"},
+		"b/": {"c d"},
+	}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+
+	expiry := time.Now().UTC().Add(time.Hour)
+	errSignFailed := errors.New("sign failed")
+	// Fake signer which refuses to sign "secret".
+	signer := func(bucket, key string) (string, time.Time, error) {
+		if key == "secret" {
+			return "", time.Time{}, errSignFailed
+		}
+		u := url.URL{
+			Scheme:   "https",
+			Host:     "localhost:9000",
+			Path:     "/" + bucket + "/" + key,
+			RawQuery: "X-Amz-Signature=" + key,
+		}
+		return u.String(), expiry, nil
+	}
+
+	var got []presignedEntry
+	err := listPresigned(volume, "", listDir, isLeaf, signer, true, func(entry presignedEntry) error {
+		got = append(got, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, entry := range got {
+		keys = append(keys, entry.key)
+		u, pErr := url.Parse(entry.url)
+		if pErr != nil {
+			t.Fatalf("Expected a valid URL for %s, got %s", entry.key, pErr)
+		}
+		if u.Path != "/"+volume+"/"+entry.key || u.Query().Get("X-Amz-Signature") != entry.key {
+			t.Errorf("Expected URL of %s, got %s", entry.key, entry.url)
+		}
+		if !entry.expiry.Equal(expiry) {
+			t.Errorf("Expected expiry %s for %s, got %s", expiry, entry.key, entry.expiry)
+		}
+	}
+	if expected := []string{"a", "b/c d"}; !reflect.DeepEqual(expected, keys) {
+		t.Errorf("Expected %v, got %v", expected, keys)
+	}
+
+	// Sign errors abort the walk unless skipped.
+	err = listPresigned(volume, "", listDir, isLeaf, signer, false, func(entry presignedEntry) error {
+		return nil
+	})
+	if errorCause(err) != errSignFailed {
+		t.Errorf("Expected %s, got %v", errSignFailed, err)
+	}
+}
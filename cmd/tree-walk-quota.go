@@ -0,0 +1,127 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// bucketWalkQuota - limits the number of walks active at once on each
+// bucket so that no single bucket can monopolize listing, independent of
+// any limits on the number of directories listed at once.
+type bucketWalkQuota struct {
+	maxWalks int
+
+	mutex      *sync.Mutex
+	workQueues map[string]*bucketWorkQueue
+}
+
+// bucketWorkQueue - walk slots of a bucket. The queue is removed once no
+// walk holds or waits for one of its slots, so that buckets listed once
+// do not keep a queue forever.
+type bucketWorkQueue struct {
+	slots chan struct{} // Holds a value for every active walk of the bucket.
+	refs  int           // Number of walks holding or waiting for a slot.
+}
+
+// newBucketWalkQuota - initialize a quota of maxWalks walks per bucket.
+func newBucketWalkQuota(maxWalks int) *bucketWalkQuota {
+	return &bucketWalkQuota{
+		maxWalks:   maxWalks,
+		mutex:      &sync.Mutex{},
+		workQueues: make(map[string]*bucketWorkQueue),
+	}
+}
+
+// Returns the slots of the work queue of bucket, the queue is kept until
+// unref() is called.
+func (q *bucketWalkQuota) ref(bucket string) chan struct{} {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	workQueue, ok := q.workQueues[bucket]
+	if !ok {
+		workQueue = &bucketWorkQueue{slots: make(chan struct{}, q.maxWalks)}
+		q.workQueues[bucket] = workQueue
+	}
+	workQueue.refs++
+	return workQueue.slots
+}
+
+// Drops a reference taken by ref(), removing the work queue of bucket
+// once it is not referenced anymore.
+func (q *bucketWalkQuota) unref(bucket string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	workQueue := q.workQueues[bucket]
+	workQueue.refs--
+	if workQueue.refs == 0 {
+		delete(q.workQueues, bucket)
+	}
+}
+
+// acquire - takes a walk slot of bucket. With wait unset it fails with
+// errTooManyRequests if the bucket has no free slot, otherwise it waits
+// for one until ctx is done or endWalkCh is closed.
+func (q *bucketWalkQuota) acquire(ctx context.Context, bucket string, wait bool, endWalkCh chan struct{}) error {
+	slots := q.ref(bucket)
+	if !wait {
+		select {
+		case slots <- struct{}{}:
+			return nil
+		default:
+			q.unref(bucket)
+			return traceError(errTooManyRequests)
+		}
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		q.unref(bucket)
+		return traceError(WalkAborted{Bucket: bucket, Reason: ctx.Err()})
+	case <-endWalkCh:
+		q.unref(bucket)
+		return traceError(WalkAborted{Bucket: bucket, Reason: errWalkAbort})
+	}
+}
+
+// release - frees a walk slot of bucket.
+func (q *bucketWalkQuota) release(bucket string) {
+	q.mutex.Lock()
+	slots := q.workQueues[bucket].slots
+	q.mutex.Unlock()
+	<-slots
+	q.unref(bucket)
+}
+
+// startTreeWalk - starts a walk like startTreeWalkWithOpts() once a walk
+// slot of bucket is acquired, the slot is released when the walk ends.
+func (q *bucketWalkQuota) startTreeWalk(ctx context.Context, bucket, prefix, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, endWalkCh chan struct{}, opts treeWalkOpts, wait bool) (chan treeWalkResult, error) {
+	if err := q.acquire(ctx, bucket, wait, endWalkCh); err != nil {
+		return nil, err
+	}
+	doneFn := opts.doneFn
+	opts.doneFn = func() {
+		q.release(bucket)
+		if doneFn != nil {
+			doneFn()
+		}
+	}
+	return startTreeWalkWithOpts(ctx, bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh, opts), nil
+}
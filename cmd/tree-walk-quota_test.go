@@ -0,0 +1,129 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Test the number of walks active on a bucket is limited by the quota.
+func TestBucketWalkQuota(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	// Walks block in listDir() till released.
+	releaseCh := make(chan struct{})
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		<-releaseCh
+		return []string{"a"}, true, nil
+	}
+	quota := newBucketWalkQuota(2)
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+
+	var resultChs []chan treeWalkResult
+	for i := 0; i < 2; i++ {
+		resultCh, err := quota.startTreeWalk(context.Background(), "bucket1", "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{}, false)
+		if err != nil {
+			t.Fatalf("Walk %d: Unexpected error %s", i+1, err)
+		}
+		resultChs = append(resultChs, resultCh)
+	}
+	// Quota of bucket1 is used up.
+	if _, err := quota.startTreeWalk(context.Background(), "bucket1", "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{}, false); errorCause(err) != errTooManyRequests {
+		t.Fatalf("Expected %s, got %v", errTooManyRequests, err)
+	}
+	// Other buckets are unaffected.
+	resultCh, err := quota.startTreeWalk(context.Background(), "bucket2", "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{}, false)
+	if err != nil {
+		t.Fatalf("Expected walk on another bucket to start, got %s", err)
+	}
+	resultChs = append(resultChs, resultCh)
+
+	// A queued walk starts once an active walk of bucket1 ends.
+	startedCh := make(chan chan treeWalkResult)
+	go func() {
+		resultCh, qErr := quota.startTreeWalk(context.Background(), "bucket1", "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{}, true)
+		if qErr != nil {
+			t.Error(qErr)
+		}
+		startedCh <- resultCh
+	}()
+	select {
+	case <-startedCh:
+		t.Fatal("Expected walk to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(releaseCh)
+	for _, resultCh := range resultChs {
+		for range resultCh {
+		}
+	}
+	select {
+	case resultCh = <-startedCh:
+		for range resultCh {
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected queued walk to start once a slot was released")
+	}
+	// Work queues are removed once their walks are done.
+	waitWorkQueues(t, quota, 0)
+
+	// A queued walk gives up when the walk is ended.
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+	blockedListDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		<-blockCh
+		return nil, false, nil
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = quota.startTreeWalk(context.Background(), "bucket3", "", "", true, blockedListDir, isLeaf, endWalkCh, treeWalkOpts{}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	abortCh := make(chan struct{})
+	close(abortCh)
+	if _, err = quota.startTreeWalk(context.Background(), "bucket3", "", "", true, listDir, isLeaf, abortCh, treeWalkOpts{}, true); !isWalkAbort(err) {
+		t.Errorf("Expected %s, got %v", errWalkAbort, err)
+	}
+	// Walks which did not get a slot do not keep the queue of bucket3.
+	quota.mutex.Lock()
+	refs := quota.workQueues["bucket3"].refs
+	quota.mutex.Unlock()
+	if refs != 2 {
+		t.Errorf("Expected 2 references to the work queue, got %d", refs)
+	}
+}
+
+// Waits until quota has count work queues.
+func waitWorkQueues(t *testing.T, quota *bucketWalkQuota, count int) {
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		quota.mutex.Lock()
+		workQueues := len(quota.workQueues)
+		quota.mutex.Unlock()
+		if workQueues == count {
+			return
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Expected %d work queues, got %d", count, workQueues)
+		}
+	}
+}
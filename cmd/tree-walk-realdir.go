@@ -0,0 +1,37 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+// dirResolver - implemented by disks which can resolve a directory to its
+// canonical path, e.g. with symlinks followed, so that walks can tell a
+// directory listing one of its ancestors under another name. Disks need
+// not implement it, see realDirFunc().
+type dirResolver interface {
+	// RealDir - returns the canonical path of directory dirPath in volume,
+	// parentDir is the one of its parent if known, empty otherwise.
+	RealDir(volume, dirPath, parentDir string) (string, error)
+}
+
+// realDirFunc - returns treeWalkOpts.realDir resolving directories on disk,
+// nil if disk does not implement dirResolver.
+func realDirFunc(disk StorageAPI) func(bucket, prefixDir, parentDir string) (string, error) {
+	resolver, ok := disk.(dirResolver)
+	if !ok {
+		return nil
+	}
+	return resolver.RealDir
+}
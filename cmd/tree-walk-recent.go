@@ -0,0 +1,113 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"container/heap"
+	"sort"
+
+	"golang.org/x/net/context"
+)
+
+// modTimeHeap - heap of objects whose root is the object ranked last, i.e.
+// the oldest one when looking for the newest objects and vice versa.
+type modTimeHeap struct {
+	objects []ObjectInfo
+	newest  bool
+}
+
+func (h *modTimeHeap) Len() int      { return len(h.objects) }
+func (h *modTimeHeap) Swap(i, j int) { h.objects[i], h.objects[j] = h.objects[j], h.objects[i] }
+func (h *modTimeHeap) Less(i, j int) bool {
+	// Ties are broken by name so that the result is deterministic.
+	return h.ranksBefore(h.objects[j], h.objects[i])
+}
+func (h *modTimeHeap) Push(x interface{}) { h.objects = append(h.objects, x.(ObjectInfo)) }
+func (h *modTimeHeap) Pop() interface{} {
+	last := h.objects[len(h.objects)-1]
+	h.objects = h.objects[:len(h.objects)-1]
+	return last
+}
+
+// Returns true if a ranks before b.
+func (h *modTimeHeap) ranksBefore(a, b ObjectInfo) bool {
+	if !a.ModTime.Equal(b.ModTime) {
+		if h.newest {
+			return a.ModTime.After(b.ModTime)
+		}
+		return a.ModTime.Before(b.ModTime)
+	}
+	return a.Name < b.Name
+}
+
+// listTopByModTime - walks all objects under prefix and returns the n most
+// recently modified objects, newest first, or with newest unset the n
+// least recently modified objects, oldest first. Only the n objects
+// ranked first so far are held in a heap, so memory stays bounded by n
+// whatever the number of objects walked. Should the heap outgrow budget
+// errWalkMemoryBudget is returned.
+func listTopByModTime(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc, objInfo objectInfoFunc, n int, newest bool, budget walkMemoryBudget) ([]ObjectInfo, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+
+	h := &modTimeHeap{newest: newest}
+	var heapBytes int64
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return nil, walkResult.err
+		}
+		info, err := objInfo(bucket, walkResult.entry)
+		if err != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(err) == errFileNotFound {
+				continue
+			}
+			return nil, err
+		}
+		if h.Len() < n {
+			heap.Push(h, info)
+			heapBytes += objectInfoBytes(info)
+		} else if h.ranksBefore(info, h.objects[0]) {
+			// Replace the object ranked last since this one ranks before it.
+			heapBytes += objectInfoBytes(info) - objectInfoBytes(h.objects[0])
+			h.objects[0] = info
+			heap.Fix(h, 0)
+		}
+		if !budget.allows(heapBytes) {
+			return nil, traceError(errWalkMemoryBudget)
+		}
+	}
+	objects := h.objects
+	sort.Sort(byModTimeRank{h})
+	return objects, nil
+}
+
+// byModTimeRank - sorts the objects of a heap by their rank.
+type byModTimeRank struct {
+	*modTimeHeap
+}
+
+func (b byModTimeRank) Less(i, j int) bool {
+	return b.ranksBefore(b.objects[i], b.objects[j])
+}
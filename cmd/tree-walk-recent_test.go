@@ -0,0 +1,83 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the n newest and oldest objects are returned in order.
+func TestListTopByModTime(t *testing.T) {
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b", "c/", "d", "e"},
+		"c/": {"f", "g"},
+	}}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	epoch := time.Unix(1000000, 0).UTC()
+	modTimes := map[string]time.Duration{
+		"a":   5,
+		"b":   1,
+		"c/f": 7,
+		"c/g": 3,
+		"d":   5, // Same as "a", ties are ranked by name.
+		"e":   2,
+	}
+	objInfo := func(bucket, object string) (ObjectInfo, error) {
+		return ObjectInfo{Bucket: bucket, Name: object, ModTime: epoch.Add(modTimes[object] * time.Hour)}, nil
+	}
+
+	testCases := []struct {
+		n        int
+		newest   bool
+		expected []string
+	}{
+		{3, true, []string{"c/f", "a", "d"}},
+		{2, true, []string{"c/f", "a"}},
+		{3, false, []string{"b", "e", "c/g"}},
+		{4, false, []string{"b", "e", "c/g", "a"}},
+		// More than there are objects.
+		{10, true, []string{"c/f", "a", "d", "c/g", "e", "b"}},
+		{0, true, nil},
+	}
+	for i, testCase := range testCases {
+		objects, err := listTopByModTime(volume, "", listDir, isLeaf, objInfo, testCase.n, testCase.newest, walkMemoryBudget{})
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		var got []string
+		for _, object := range objects {
+			got = append(got, object.Name)
+		}
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+
+	// Holding the objects ranked first outgrows a small budget.
+	if _, err := listTopByModTime(volume, "", listDir, isLeaf, objInfo, 3, true, walkMemoryBudget{maxBytes: 1 << 20}); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+	if _, err := listTopByModTime(volume, "", listDir, isLeaf, objInfo, 3, true, walkMemoryBudget{maxBytes: 200}); errorCause(err) != errWalkMemoryBudget {
+		t.Errorf("Expected error %v, got %v", errWalkMemoryBudget, err)
+	}
+}
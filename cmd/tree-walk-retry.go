@@ -0,0 +1,101 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// errWalkInconsistent - disks returned diverging listings or too many of them failed.
+var errWalkInconsistent = errors.New("Inconsistent listing across disks")
+
+// Returns function "listDir" of the type listDirFunc which lists all the
+// disks and fails with errWalkInconsistent if the disks which succeeded
+// do not return the same entries or if more than maxIgnoredErrs disks
+// failed with an error listDirFactory() would have ignored.
+func listDirConsistentFactory(isLeaf isLeafFunc, maxIgnoredErrs int, disks ...StorageAPI) listDirFunc {
+	listDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
+		var listed bool
+		var ignoredErrs int
+		for _, disk := range disks {
+			if disk == nil {
+				ignoredErrs++
+				continue
+			}
+			diskEntries, diskDelayIsLeaf, dErr := listDirFactory(isLeaf, disk)(bucket, prefixDir, prefixEntry)
+			if dErr != nil {
+				if !isErrIgnored(dErr, walkResultIgnoredErrs) {
+					return nil, false, dErr
+				}
+				ignoredErrs++
+				continue
+			}
+			if !listed {
+				entries, delayIsLeaf, listed = diskEntries, diskDelayIsLeaf, true
+				continue
+			}
+			if !reflect.DeepEqual(entries, diskEntries) {
+				return nil, false, traceError(errWalkInconsistent)
+			}
+		}
+		if ignoredErrs > maxIgnoredErrs {
+			return nil, false, traceError(errWalkInconsistent)
+		}
+		if !listed {
+			return nil, false, traceError(errFileNotFound)
+		}
+		return entries, delayIsLeaf, nil
+	}
+	return listDir
+}
+
+// listAllWithRetry - walks prefix and returns all the entries, for one
+// shot listings which must not return a degraded result. If the walk
+// fails with errWalkInconsistent, e.g. with listDir returned by
+// listDirConsistentFactory(), everything listed so far is thrown away
+// and the walk starts over from scratch, up to retries times before
+// giving up. This trades latency for confidence in the result.
+func listAllWithRetry(bucket, prefix string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, retries int) (entries []string, err error) {
+	for attempt := 0; attempt <= retries; attempt++ {
+		entries, err = listAll(bucket, prefix, recursive, listDir, isLeaf)
+		if errorCause(err) != errWalkInconsistent {
+			return entries, err
+		}
+	}
+	return nil, err
+}
+
+// Returns all the entries of a single walk.
+func listAll(bucket, prefix string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc) ([]string, error) {
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	var entries []string
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", recursive, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return nil, walkResult.err
+		}
+		entries = append(entries, walkResult.entry)
+	}
+	return entries, nil
+}
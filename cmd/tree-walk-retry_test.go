@@ -0,0 +1,98 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// staleListDirDisk - listDirDisk returning the stale listings for the
+// first staleCalls calls to ListDir(), like a disk catching up.
+type staleListDirDisk struct {
+	listDirDisk
+	stale      map[string][]string
+	staleCalls int
+}
+
+func (d *staleListDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	if d.staleCalls > 0 {
+		d.staleCalls--
+		if entries, ok := d.stale[dirPath]; ok {
+			return append([]string(nil), entries...), nil
+		}
+	}
+	return d.listDirDisk.ListDir(volume, dirPath)
+}
+
+func (d *staleListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
+// Test walks over diverging disks are retried until they are consistent.
+func TestListAllWithRetry(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":   {"a", "b/"},
+		"b/": {"c", "d"},
+	}
+	stale := map[string][]string{
+		"b/": {"c"},
+	}
+	expected := []string{"a", "b/c", "b/d"}
+
+	testCases := []struct {
+		staleCalls     int
+		retries        int
+		maxIgnoredErrs int
+		offline        bool
+		expected       []string
+		expectedErr    error
+	}{
+		// Consistent disks.
+		{0, 0, 0, false, expected, nil},
+		// First attempt is degraded, the retry succeeds.
+		{2, 1, 0, false, expected, nil},
+		// Disk stays stale across all attempts.
+		{100, 2, 0, false, nil, errWalkInconsistent},
+		// An offline disk is tolerated upto maxIgnoredErrs.
+		{0, 0, 1, true, expected, nil},
+		{0, 2, 0, true, nil, errWalkInconsistent},
+	}
+	for i, testCase := range testCases {
+		staleDisk := &staleListDirDisk{
+			listDirDisk: listDirDisk{dirs: dirs},
+			stale:       stale,
+			staleCalls:  testCase.staleCalls,
+		}
+		disks := []StorageAPI{&listDirDisk{dirs: dirs}, staleDisk}
+		if testCase.offline {
+			disks = append(disks, nil)
+		}
+		listDir := listDirConsistentFactory(isLeaf, testCase.maxIgnoredErrs, disks...)
+		entries, err := listAllWithRetry(volume, "", true, listDir, isLeaf, testCase.retries)
+		if errorCause(err) != testCase.expectedErr {
+			t.Fatalf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "strings"
+
+// Metadata key under which an anti-virus integration tags the scan status of an object.
+const scanStatusMetaKey = "X-Minio-Scan-Status"
+
+// scanStatus - anti-virus scan status of an object.
+type scanStatus string
+
+// Scan statuses of objects.
+const (
+	scanStatusClean     scanStatus = "clean"
+	scanStatusInfected  scanStatus = "infected"
+	scanStatusUnscanned scanStatus = "unscanned"
+)
+
+// objectScanStatus - returns the scan status tagged in the metadata of the
+// object, objects without a tag or with an unknown one have status
+// unknownStatus.
+func objectScanStatus(info ObjectInfo, unknownStatus scanStatus) scanStatus {
+	switch status := scanStatus(strings.ToLower(info.UserDefined[scanStatusMetaKey])); status {
+	case scanStatusClean, scanStatusInfected, scanStatusUnscanned:
+		return status
+	}
+	return unknownStatus
+}
+
+// byScanStatus - returns a filter matching objects with scan status
+// status, e.g. only clean objects for serving or only unscanned objects
+// for the scanning backlog. Objects without a known tag are considered
+// to have status unknownStatus.
+func byScanStatus(status, unknownStatus scanStatus) func(ObjectInfo) bool {
+	return func(info ObjectInfo) bool {
+		return objectScanStatus(info, unknownStatus) == status
+	}
+}
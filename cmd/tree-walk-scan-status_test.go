@@ -0,0 +1,58 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test filtering objects with a mix of scan statuses.
+func TestByScanStatus(t *testing.T) {
+	objects := []ObjectInfo{
+		{Name: "a", UserDefined: map[string]string{scanStatusMetaKey: "clean"}},
+		{Name: "b", UserDefined: map[string]string{scanStatusMetaKey: "infected"}},
+		{Name: "c", UserDefined: map[string]string{scanStatusMetaKey: "unscanned"}},
+		{Name: "d", UserDefined: map[string]string{scanStatusMetaKey: "CLEAN"}},
+		{Name: "e", UserDefined: map[string]string{scanStatusMetaKey: "pending"}},
+		{Name: "f"},
+	}
+	testCases := []struct {
+		status        scanStatus
+		unknownStatus scanStatus
+		expected      []string
+	}{
+		{scanStatusClean, scanStatusUnscanned, []string{"a", "d"}},
+		{scanStatusInfected, scanStatusUnscanned, []string{"b"}},
+		{scanStatusUnscanned, scanStatusUnscanned, []string{"c", "e", "f"}},
+		// Objects without a known status treated as clean.
+		{scanStatusClean, scanStatusClean, []string{"a", "d", "e", "f"}},
+		{scanStatusUnscanned, scanStatusClean, []string{"c"}},
+	}
+	for i, testCase := range testCases {
+		filter := byScanStatus(testCase.status, testCase.unknownStatus)
+		var got []string
+		for _, object := range objects {
+			if filter(object) {
+				got = append(got, object.Name)
+			}
+		}
+		if !reflect.DeepEqual(testCase.expected, got) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// errListSessionNotFound - list session is unknown, done or timed out.
+var errListSessionNotFound = errors.New("List session not found")
+
+// listSession - a walk kept alive between page requests.
+type listSession struct {
+	resultCh  chan treeWalkResult
+	endWalkCh chan struct{}
+	idleTimer *time.Timer
+}
+
+// listSessionPool - pool of walks serving back to back page requests of
+// a listing, the paginated analog of treeWalkPool. Every page is served
+// from the same walk instead of a new walk resuming from a marker, a
+// walk idle for longer than idleTimeout is ended.
+type listSessionPool struct {
+	sessions    map[string]*listSession
+	idleTimeout time.Duration
+	lock        *sync.Mutex
+}
+
+// newListSessionPool - initialize new list session pool.
+func newListSessionPool(idleTimeout time.Duration) *listSessionPool {
+	return &listSessionPool{
+		sessions:    make(map[string]*listSession),
+		idleTimeout: idleTimeout,
+		lock:        &sync.Mutex{},
+	}
+}
+
+// Open - starts a walk of prefix and returns the ID of its session.
+func (p *listSessionPool) Open(bucket, prefix string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc) (sessionID string) {
+	endWalkCh := make(chan struct{})
+	session := &listSession{
+		resultCh:  startTreeWalk(context.Background(), bucket, prefix, "", recursive, listDir, isLeaf, endWalkCh),
+		endWalkCh: endWalkCh,
+	}
+	sessionID = getUUID()
+	p.park(sessionID, session)
+	return sessionID
+}
+
+// Adds session to the pool till the next page is requested or it times out.
+func (p *listSessionPool) park(sessionID string, session *listSession) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sessions[sessionID] = session
+	session.idleTimer = time.AfterFunc(p.idleTimeout, func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		// The session may have been picked up for a page meanwhile.
+		if p.sessions[sessionID] != session {
+			return
+		}
+		delete(p.sessions, sessionID)
+		close(session.endWalkCh)
+	})
+}
+
+// NextPage - returns upto maxKeys entries following the previous page of
+// the session. eof is set once the walk is done, the session is then
+// closed. Pages of a session must be requested one at a time.
+func (p *listSessionPool) NextPage(sessionID string, maxKeys int) (entries []string, eof bool, err error) {
+	p.lock.Lock()
+	session, ok := p.sessions[sessionID]
+	if ok {
+		delete(p.sessions, sessionID)
+		session.idleTimer.Stop()
+	}
+	p.lock.Unlock()
+	if !ok {
+		return nil, false, traceError(errListSessionNotFound)
+	}
+
+	for len(entries) < maxKeys {
+		walkResult, ok := <-session.resultCh
+		if !ok {
+			eof = true
+			break
+		}
+		if walkResult.err != nil {
+			close(session.endWalkCh)
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				return entries, true, nil
+			}
+			return nil, false, walkResult.err
+		}
+		entries = append(entries, walkResult.entry)
+		if walkResult.end {
+			eof = true
+			break
+		}
+	}
+	if eof {
+		close(session.endWalkCh)
+		return entries, true, nil
+	}
+	p.park(sessionID, session)
+	return entries, false, nil
+}
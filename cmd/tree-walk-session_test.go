@@ -0,0 +1,95 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test pages of a session are served by a single walk.
+func TestListSessionPool(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b/", "c", "d"},
+		"b/": {"e", "f"},
+	}}
+	diskListDir := listDirFactory(isLeaf, disk)
+	var rootListings int32
+	listDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		if prefixDir == "" {
+			atomic.AddInt32(&rootListings, 1)
+		}
+		return diskListDir(bucket, prefixDir, prefixEntry)
+	}
+
+	pool := newListSessionPool(time.Minute)
+	sessionID := pool.Open(volume, "", true, listDir, isLeaf)
+	expectedPages := [][]string{{"a", "b/e"}, {"b/f", "c"}, {"d"}}
+	for i, expected := range expectedPages {
+		entries, eof, err := pool.NextPage(sessionID, 2)
+		if err != nil {
+			t.Fatalf("Page %d: Unexpected error %s", i+1, err)
+		}
+		if !reflect.DeepEqual(expected, entries) {
+			t.Errorf("Page %d: Expected %v, got %v", i+1, expected, entries)
+		}
+		if eof != (i == len(expectedPages)-1) {
+			t.Errorf("Page %d: Unexpected eof %v", i+1, eof)
+		}
+	}
+	if n := atomic.LoadInt32(&rootListings); n != 1 {
+		t.Errorf("Expected all pages to be served by one walk, root was listed %d times", n)
+	}
+	// Session is closed at the end of the walk.
+	if _, _, err := pool.NextPage(sessionID, 2); errorCause(err) != errListSessionNotFound {
+		t.Errorf("Expected %s, got %v", errListSessionNotFound, err)
+	}
+}
+
+// Test idle sessions are torn down.
+func TestListSessionPoolIdleTimeout(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"": {"a", "b", "c"},
+	}}
+	pool := newListSessionPool(50 * time.Millisecond)
+	sessionID := pool.Open(volume, "", true, listDirFactory(isLeaf, disk), isLeaf)
+	if _, _, err := pool.NextPage(sessionID, 1); err != nil {
+		t.Fatal(err)
+	}
+	pool.lock.Lock()
+	session := pool.sessions[sessionID]
+	pool.lock.Unlock()
+
+	time.Sleep(200 * time.Millisecond)
+	if _, _, err := pool.NextPage(sessionID, 1); errorCause(err) != errListSessionNotFound {
+		t.Errorf("Expected %s, got %v", errListSessionNotFound, err)
+	}
+	select {
+	case <-session.endWalkCh:
+	default:
+		t.Error("Expected walk of the idle session to be ended")
+	}
+}
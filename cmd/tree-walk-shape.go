@@ -0,0 +1,100 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// levelFanOut - fan-out of all the directories at one level.
+type levelFanOut struct {
+	dirs       int64 // Number of directories at this level.
+	entries    int64 // Number of entries in all the directories at this level.
+	maxEntries int64 // Number of entries in the largest directory at this level.
+}
+
+// walkShape - shape of the namespace under a prefix as seen by a recursive walk.
+type walkShape struct {
+	// Number of objects by depth, an object "a/b/c" is at depth 3.
+	depths map[int]int64
+	// Fan-out by level, level 0 is the bucket root and level N holds the
+	// directories at depth N.
+	fanOut []levelFanOut
+}
+
+// walkShapeStats - walks all objects under prefix and returns the depth
+// histogram and the per level fan-out of the namespace. Only directories
+// holding objects are accounted for.
+func walkShapeStats(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc) (walkShape, error) {
+	shape := walkShape{depths: make(map[int]int64)}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+
+	// Path components of the previous object, directories retain the
+	// trailing "/" so that they never compare equal to an object.
+	var prev []string
+	// Number of entries seen so far in the currently open directory of each level.
+	var counts []int64
+	// Closes the open directories at levels >= level.
+	closeDirs := func(level int) {
+		if level >= len(counts) {
+			return
+		}
+		for l := level; l < len(counts); l++ {
+			if counts[l] > shape.fanOut[l].maxEntries {
+				shape.fanOut[l].maxEntries = counts[l]
+			}
+		}
+		counts = counts[:level]
+	}
+	for walkResult := range startTreeWalk(context.Background(), bucket, prefix, "", true, listDir, isLeaf, endWalkCh) {
+		if walkResult.err != nil {
+			// File not found is a valid case, there is nothing under prefix.
+			if errorCause(walkResult.err) == errFileNotFound {
+				break
+			}
+			return walkShape{}, walkResult.err
+		}
+		components := strings.SplitAfter(walkResult.entry, slashSeparator)
+		shape.depths[len(components)]++
+
+		// Find the first level where this object diverges from the previous one.
+		level := 0
+		for level < len(prev) && level < len(components) && prev[level] == components[level] {
+			level++
+		}
+		closeDirs(level + 1)
+		for len(shape.fanOut) < len(components) {
+			shape.fanOut = append(shape.fanOut, levelFanOut{})
+		}
+		// Every component from the diverging level onwards is a new entry,
+		// and every one of them below it is in a new directory.
+		for l := level; l < len(components); l++ {
+			if l >= len(counts) {
+				counts = append(counts, 0)
+				shape.fanOut[l].dirs++
+			}
+			counts[l]++
+			shape.fanOut[l].entries++
+		}
+		prev = components
+	}
+	closeDirs(0)
+	return shape, nil
+}
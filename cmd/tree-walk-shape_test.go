@@ -0,0 +1,89 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test walkShapeStats on a tree with a known depth distribution.
+func TestWalkShapeStats(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+	var files = []string{
+		"a/b/c",
+		"a/b/d",
+		"a/b/e",
+		"a/f/g",
+		"a/h",
+		"i",
+		"j/k",
+	}
+	if err = createNamespace(disk, volume, files); err != nil {
+		t.Fatal(err)
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+
+	testCases := []struct {
+		prefix   string
+		expected walkShape
+	}{
+		{"", walkShape{
+			depths: map[int]int64{1: 1, 2: 2, 3: 4},
+			fanOut: []levelFanOut{
+				// Root holds "a/", "i" and "j/".
+				{dirs: 1, entries: 3, maxEntries: 3},
+				// "a/" holds "b/", "f/" and "h", "j/" holds "k".
+				{dirs: 2, entries: 4, maxEntries: 3},
+				// "a/b/" holds 3 objects, "a/f/" holds 1.
+				{dirs: 2, entries: 4, maxEntries: 3},
+			},
+		}},
+		{"a/f", walkShape{
+			depths: map[int]int64{3: 1},
+			fanOut: []levelFanOut{
+				{dirs: 1, entries: 1, maxEntries: 1},
+				{dirs: 1, entries: 1, maxEntries: 1},
+				{dirs: 1, entries: 1, maxEntries: 1},
+			},
+		}},
+		// Prefix which does not exist.
+		{"x/", walkShape{depths: map[int]int64{}}},
+	}
+	for i, testCase := range testCases {
+		shape, err := walkShapeStats(volume, testCase.prefix, listDir, isLeaf)
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, shape) {
+			t.Errorf("Test %d: Expected %+v, got %+v", i+1, testCase.expected, shape)
+		}
+	}
+}
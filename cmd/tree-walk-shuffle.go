@@ -0,0 +1,49 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "math/rand"
+
+// Returns function "listDir" of the type listDirFunc which lists using
+// listDir but shuffles the entries of rootDir in an order determined by
+// workerID. Workers starting identical scans with different IDs visit
+// the top level directories in different orders and hence spread the
+// load across directories instead of all hitting the same ones first.
+//
+// NOTE: walks using the returned listDir are NOT sorted, only the entries
+// beneath each top level directory are. Every entry is still walked
+// exactly once. Since walk markers rely on sorted entries, walks with a
+// marker must not use it.
+func listDirShuffleFactory(rootDir string, workerID int64, listDir listDirFunc) listDirFunc {
+	shuffleListDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
+		entries, delayIsLeaf, err = listDir(bucket, prefixDir, prefixEntry)
+		if err != nil || prefixDir != rootDir {
+			return entries, delayIsLeaf, err
+		}
+		// Shuffle a copy, listDir may return entries it holds on to.
+		entries = append([]string(nil), entries...)
+		// Seeded afresh every time so that the order is the same for
+		// every walk of the same worker.
+		r := rand.New(rand.NewSource(workerID))
+		for i := len(entries) - 1; i > 0; i-- {
+			j := r.Intn(i + 1)
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+		return entries, delayIsLeaf, nil
+	}
+	return shuffleListDir
+}
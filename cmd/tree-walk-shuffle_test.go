@@ -0,0 +1,65 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Test shuffled walks cover every entry and are deterministic per worker.
+func TestListDirShuffle(t *testing.T) {
+	dirs := map[string][]string{"": nil}
+	for i := 0; i < 10; i++ {
+		dir := fmt.Sprintf("dir%d/", i)
+		dirs[""] = append(dirs[""], dir)
+		dirs[dir] = []string{"a", "b"}
+	}
+	disk := &listDirDisk{dirs: dirs}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	expected := walkEntries("", "", true, listDir, isLeaf)
+
+	firstEntries := make(map[string]struct{})
+	for workerID := int64(0); workerID < 8; workerID++ {
+		shuffleListDir := listDirShuffleFactory("", workerID, listDir)
+		got := walkEntries("", "", true, shuffleListDir, isLeaf)
+		if !reflect.DeepEqual(got, walkEntries("", "", true, shuffleListDir, isLeaf)) {
+			t.Errorf("Worker %d: Expected the same order for every walk", workerID)
+		}
+		firstEntries[got[0]] = struct{}{}
+
+		// Entries beneath each directory stay sorted.
+		for i := 0; i < len(got); i += 2 {
+			if !strings.HasSuffix(got[i], "/a") || got[i+1] != strings.TrimSuffix(got[i], "a")+"b" {
+				t.Fatalf("Worker %d: Expected directory contents in order, got %v", workerID, got)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Worker %d: Expected entries %v, got %v", workerID, expected, got)
+		}
+	}
+	if len(firstEntries) < 2 {
+		t.Errorf("Expected workers to start with different directories, all started with %v", firstEntries)
+	}
+}
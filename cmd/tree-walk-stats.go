@@ -0,0 +1,93 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// treeWalkStats - counters of a walk to diagnose slow listings, set
+// treeWalkOpts.stats to have a walk accumulate them. Setting
+// listDirOpts.stats to the same value also counts disks whose errors
+// were ignored while listing. Counters are updated atomically since
+// disks are listed concurrently, they are final once the result channel
+// of the walk is closed.
+type treeWalkStats struct {
+	dirs        int64 // Number of directories listed.
+	leaves      int64 // Number of objects sent.
+	filtered    int64 // Number of entries skipped by skip prefixes or glob patterns.
+	ignoredErrs int64 // Number of disk errors ignored while listing.
+	duration    int64 // Nanoseconds from start till the end of the walk.
+}
+
+// All the methods below are no-ops on a nil *treeWalkStats.
+
+func (s *treeWalkStats) addDir() {
+	if s != nil {
+		atomic.AddInt64(&s.dirs, 1)
+	}
+}
+
+func (s *treeWalkStats) addLeaf() {
+	if s != nil {
+		atomic.AddInt64(&s.leaves, 1)
+	}
+}
+
+func (s *treeWalkStats) addFiltered() {
+	if s != nil {
+		atomic.AddInt64(&s.filtered, 1)
+	}
+}
+
+func (s *treeWalkStats) addIgnoredErr() {
+	if s != nil {
+		atomic.AddInt64(&s.ignoredErrs, 1)
+	}
+}
+
+func (s *treeWalkStats) setDuration(duration time.Duration) {
+	if s != nil {
+		atomic.StoreInt64(&s.duration, int64(duration))
+	}
+}
+
+// Dirs - returns the number of directories listed.
+func (s *treeWalkStats) Dirs() int64 {
+	return atomic.LoadInt64(&s.dirs)
+}
+
+// Leaves - returns the number of objects sent.
+func (s *treeWalkStats) Leaves() int64 {
+	return atomic.LoadInt64(&s.leaves)
+}
+
+// Filtered - returns the number of entries skipped by skip prefixes or glob patterns.
+func (s *treeWalkStats) Filtered() int64 {
+	return atomic.LoadInt64(&s.filtered)
+}
+
+// IgnoredErrs - returns the number of disk errors ignored while listing.
+func (s *treeWalkStats) IgnoredErrs() int64 {
+	return atomic.LoadInt64(&s.ignoredErrs)
+}
+
+// Duration - returns the time from start till the end of the walk.
+func (s *treeWalkStats) Duration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.duration))
+}
@@ -0,0 +1,70 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Test walk statistics of a known tree.
+func TestTreeWalkStats(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":     {"a", "b.tmp", "d/"},
+		"d/":   {"c", "e/"},
+		"d/e/": {"f"},
+	}}
+	// Disk without any directory, ignored for every listing. The disk
+	// holding the entries replies later so that it is always waited for.
+	emptyDisk := &listDirDisk{}
+
+	stats := &treeWalkStats{}
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{stats: stats}, slowListDirDisk{disk, 50 * time.Millisecond}, emptyDisk)
+	opts := treeWalkOpts{exclude: []string{"**/*.tmp"}, stats: stats}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		count    int64
+		expected int64
+	}{
+		{"dirs", stats.Dirs(), 3},
+		{"leaves", stats.Leaves(), 3},
+		{"filtered", stats.Filtered(), 1},
+		{"ignoredErrs", stats.IgnoredErrs(), 3},
+	}
+	for _, testCase := range testCases {
+		if testCase.count != testCase.expected {
+			t.Errorf("Expected %d %s, got %d", testCase.expected, testCase.name, testCase.count)
+		}
+	}
+	if stats.Duration() < 150*time.Millisecond {
+		t.Errorf("Expected a duration of at least 150ms, got %v", stats.Duration())
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import "strings"
+
+// ListSubPrefixes - returns the directories right under prefix, i.e. the
+// common prefixes a non-recursive walk of prefix would send, without any
+// of the objects. Like the prefix of startTreeWalk() prefix need not end
+// in "/", "photos/20" returns "photos/2016/" and "photos/2017/" say.
+func ListSubPrefixes(bucket, prefix string, listDir listDirFunc, isLeaf isLeafFunc) ([]string, error) {
+	// Prefixes are cleaned like the prefixes of walks are.
+	prefix, err := normalizeWalkPrefix(bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	prefixDir, entryPrefixMatch := splitWalkPrefix(prefix)
+	entries, delayIsLeaf, err := listDir(bucket, prefixDir, entryPrefixMatch)
+	if err != nil {
+		// Nothing under prefix.
+		if errorCause(err) == errFileNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var subPrefixes []string
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry, slashSeparator) {
+			continue
+		}
+		// Decision to do isLeaf check was pushed from listDir() to here.
+		if delayIsLeaf && isLeaf(bucket, pathJoin(prefixDir, entry)) {
+			continue
+		}
+		subPrefixes = append(subPrefixes, pathJoin(prefixDir, entry))
+	}
+	return subPrefixes, nil
+}
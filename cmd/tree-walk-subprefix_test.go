@@ -0,0 +1,81 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test listing only the directories right under a prefix.
+func TestListSubPrefixes(t *testing.T) {
+	// Objects are directories with a ".txt" suffix, as with XL.
+	isLeaf := func(volume, prefix string) bool {
+		return strings.HasSuffix(prefix, ".txt/") || !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":             {"docs/", "photos/", "readme", "x.txt/"},
+		"photos/":      {"2016/", "2017/", "a.txt/", "b", "misc/"},
+		"photos/2016/": {"c.txt/"},
+		"docs/":        {"d", "e.txt/"},
+	}})
+	testCases := []struct {
+		prefix      string
+		subPrefixes []string
+	}{
+		// Mixed objects and directories.
+		{"", []string{"docs/", "photos/"}},
+		{"photos/", []string{"photos/2016/", "photos/2017/", "photos/misc/"}},
+		// Prefix not ending in "/".
+		{"photos/20", []string{"photos/2016/", "photos/2017/"}},
+		{"p", []string{"photos/"}},
+		// Only objects.
+		{"docs/", nil},
+		{"photos/2016/", nil},
+		// Nothing under prefix.
+		{"videos/", nil},
+		{"z", nil},
+		// Prefixes are cleaned like those of walks.
+		{"photos//20", []string{"photos/2016/", "photos/2017/"}},
+		{"./photos/", []string{"photos/2016/", "photos/2017/", "photos/misc/"}},
+	}
+	for i, testCase := range testCases {
+		subPrefixes, err := ListSubPrefixes(volume, testCase.prefix, listDir, isLeaf)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(subPrefixes, testCase.subPrefixes) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.subPrefixes, subPrefixes)
+		}
+	}
+
+	// Prefixes leading outside of the bucket are invalid.
+	if _, err := ListSubPrefixes(volume, "../photos/", listDir, isLeaf); errorCause(err) != (ObjectNameInvalid{Bucket: volume, Object: "../photos/"}) {
+		t.Errorf("Expected %v, got %v", ObjectNameInvalid{Bucket: volume, Object: "../photos/"}, err)
+	}
+
+	// Errors other than errFileNotFound are returned.
+	errFaultyListDir := errors.New("faulty listDir")
+	faultyListDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		return nil, false, traceError(errFaultyListDir)
+	}
+	if _, err := ListSubPrefixes(volume, "photos/", faultyListDir, isLeaf); errorCause(err) != errFaultyListDir {
+		t.Errorf("Expected %v, got %v", errFaultyListDir, err)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errInvalidListToken - continuation token is unknown or does not belong to the listing.
+var errInvalidListToken = errors.New("Invalid list continuation token")
+
+// TokenStore - persists the continuation state of listings under opaque
+// tokens. A store shared between server instances (e.g Redis) lets any
+// of them resume a listing started by another.
+type TokenStore interface {
+	Save(token string, state []byte) error
+	// Load returns errInvalidListToken for unknown tokens.
+	Load(token string) ([]byte, error)
+}
+
+// memTokenStore - in-memory TokenStore, used by default. Tokens are only
+// resolvable by the instance which saved them. Upto maxTokens tokens are
+// kept, each for at most ttl, the oldest token is dropped once a new one
+// does not fit.
+type memTokenStore struct {
+	maxTokens int
+	ttl       time.Duration // Zero means tokens never expire.
+
+	mutex  *sync.Mutex
+	order  *list.List // Oldest token at the front.
+	tokens map[string]*list.Element
+}
+
+// memToken - state saved under token.
+type memToken struct {
+	token   string
+	state   []byte
+	savedAt time.Time
+}
+
+// newMemTokenStore - initialize new in-memory store of maxTokens tokens.
+func newMemTokenStore(maxTokens int, ttl time.Duration) *memTokenStore {
+	return &memTokenStore{
+		maxTokens: maxTokens,
+		ttl:       ttl,
+		mutex:     &sync.Mutex{},
+		order:     list.New(),
+		tokens:    make(map[string]*list.Element),
+	}
+}
+
+// Save - saves state under token, dropping expired tokens and the oldest
+// token if the store is full.
+func (m *memTokenStore) Save(token string, state []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if elem, ok := m.tokens[token]; ok {
+		m.remove(elem)
+	}
+	m.tokens[token] = m.order.PushBack(&memToken{token, state, time.Now()})
+	for m.order.Len() > 0 && (m.order.Len() > m.maxTokens || m.expired(m.order.Front())) {
+		m.remove(m.order.Front())
+	}
+	return nil
+}
+
+// Load - returns state saved under token.
+func (m *memTokenStore) Load(token string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	elem, ok := m.tokens[token]
+	if !ok {
+		return nil, errInvalidListToken
+	}
+	if m.expired(elem) {
+		m.remove(elem)
+		return nil, errInvalidListToken
+	}
+	return elem.Value.(*memToken).state, nil
+}
+
+// expired - returns true if the token of elem has expired, caller should
+// hold the lock.
+func (m *memTokenStore) expired(elem *list.Element) bool {
+	return m.ttl > 0 && time.Since(elem.Value.(*memToken).savedAt) > m.ttl
+}
+
+// remove - drops elem from the store, caller should hold the lock.
+func (m *memTokenStore) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.tokens, elem.Value.(*memToken).token)
+}
+
+// listContinuation - state needed to resume a listing.
+type listContinuation struct {
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	Delimiter string `json:"delimiter"`
+	Marker    string `json:"marker"`
+}
+
+// listObjectsWithToken - lists upto maxKeys objects resuming from the
+// state saved under token in store, an empty token starts a new listing.
+// If the result is truncated the state to resume from is saved in store
+// and the returned nextToken refers to it.
+func listObjectsWithToken(obj ObjectLayer, store TokenStore, bucket, prefix, delimiter, token string, maxKeys int) (result ListObjectsInfo, nextToken string, err error) {
+	marker := ""
+	if token != "" {
+		stateBytes, err := store.Load(token)
+		if err != nil {
+			return ListObjectsInfo{}, "", traceError(err)
+		}
+		var state listContinuation
+		if err = json.Unmarshal(stateBytes, &state); err != nil {
+			return ListObjectsInfo{}, "", traceError(errInvalidListToken)
+		}
+		// Token can only resume the listing it was issued for.
+		if state.Bucket != bucket || state.Prefix != prefix || state.Delimiter != delimiter {
+			return ListObjectsInfo{}, "", traceError(errInvalidListToken)
+		}
+		marker = state.Marker
+	}
+
+	result, err = obj.ListObjects(bucket, prefix, marker, delimiter, maxKeys)
+	if err != nil || !result.IsTruncated {
+		return result, "", err
+	}
+
+	stateBytes, err := json.Marshal(listContinuation{
+		Bucket:    bucket,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		Marker:    result.NextMarker,
+	})
+	if err != nil {
+		return ListObjectsInfo{}, "", traceError(err)
+	}
+	nextToken = getUUID()
+	if err = store.Save(nextToken, stateBytes); err != nil {
+		return ListObjectsInfo{}, "", traceError(err)
+	}
+	return result, nextToken, nil
+}
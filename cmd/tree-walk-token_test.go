@@ -0,0 +1,165 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// externalTokenStore - stands in for a store shared between instances,
+// it only ever hands out copies of the saved state like a remote store.
+type externalTokenStore struct {
+	tokens map[string][]byte
+}
+
+func (e *externalTokenStore) Save(token string, state []byte) error {
+	e.tokens[token] = append([]byte(nil), state...)
+	return nil
+}
+
+func (e *externalTokenStore) Load(token string) ([]byte, error) {
+	state, ok := e.tokens[token]
+	if !ok {
+		return nil, errInvalidListToken
+	}
+	return append([]byte(nil), state...), nil
+}
+
+// Test continuation tokens saved by one instance are resumed by another.
+func TestListObjectsWithToken(t *testing.T) {
+	obj1, fsDir, err := prepareFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeAll(fsDir)
+	// Second instance serving the same backend.
+	obj2, err := newFSObjects(fsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := "bucket"
+	if err = obj1.MakeBucket(bucket); err != nil {
+		t.Fatal(err)
+	}
+	objects := []string{"a", "b/c", "b/d", "e", "f/g/h"}
+	for _, object := range objects {
+		_, err = obj1.PutObject(bucket, object, int64(len(object)), bytes.NewBufferString(object), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := &externalTokenStore{tokens: make(map[string][]byte)}
+	instances := []ObjectLayer{obj1, obj2}
+	var listed []string
+	var token string
+	for page := 0; ; page++ {
+		// Alternate between instances for every page.
+		result, nextToken, err := listObjectsWithToken(instances[page%2], store, bucket, "", "", token, 2)
+		if err != nil {
+			t.Fatalf("Page %d: Unexpected error %s", page+1, err)
+		}
+		for _, objInfo := range result.Objects {
+			listed = append(listed, objInfo.Name)
+		}
+		if !result.IsTruncated {
+			if nextToken != "" {
+				t.Errorf("Page %d: Expected no token for the last page, got %s", page+1, nextToken)
+			}
+			break
+		}
+		if nextToken == "" {
+			t.Fatalf("Page %d: Expected a token for a truncated page", page+1)
+		}
+		token = nextToken
+	}
+	if !reflect.DeepEqual(objects, listed) {
+		t.Errorf("Expected %v, got %v", objects, listed)
+	}
+
+	// Unknown tokens and tokens of another listing are rejected.
+	if _, _, err = listObjectsWithToken(obj1, store, bucket, "", "", "unknown", 2); errorCause(err) != errInvalidListToken {
+		t.Errorf("Expected %s, got %v", errInvalidListToken, err)
+	}
+	if _, _, err = listObjectsWithToken(obj1, store, bucket, "b/", "", token, 2); errorCause(err) != errInvalidListToken {
+		t.Errorf("Expected %s, got %v", errInvalidListToken, err)
+	}
+
+	// In-memory store round trip.
+	memStore := newMemTokenStore(10, time.Minute)
+	result, nextToken, err := listObjectsWithToken(obj1, memStore, bucket, "", "", "", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _, err = listObjectsWithToken(obj1, memStore, bucket, "", "", nextToken, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Objects) != 2 || result.IsTruncated {
+		t.Errorf("Expected the remaining 2 objects, got %v", result.Objects)
+	}
+}
+
+// Test tokens are dropped from the in-memory store once it is full or
+// they expire.
+func TestMemTokenStoreEviction(t *testing.T) {
+	store := newMemTokenStore(2, 0)
+	for _, token := range []string{"a", "b", "c"} {
+		if err := store.Save(token, []byte(token)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "a" is the oldest token, it is dropped.
+	if _, err := store.Load("a"); err != errInvalidListToken {
+		t.Errorf("Expected a to be dropped, got %v", err)
+	}
+	for _, token := range []string{"b", "c"} {
+		if state, err := store.Load(token); err != nil || string(state) != token {
+			t.Errorf("Expected %s to be saved, got %q, %v", token, state, err)
+		}
+	}
+	// Saving a token again makes it the newest.
+	store.Save("b", []byte("b2"))
+	store.Save("d", []byte("d"))
+	if _, err := store.Load("c"); err != errInvalidListToken {
+		t.Errorf("Expected c to be dropped, got %v", err)
+	}
+	if state, err := store.Load("b"); err != nil || string(state) != "b2" {
+		t.Errorf("Expected b2, got %q, %v", state, err)
+	}
+
+	store = newMemTokenStore(10, 10*time.Millisecond)
+	store.Save("a", []byte("a"))
+	if _, err := store.Load("a"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Load("a"); err != errInvalidListToken {
+		t.Errorf("Expected a to be expired, got %v", err)
+	}
+	// Expired tokens are dropped even if never loaded again.
+	store.Save("b", []byte("b"))
+	time.Sleep(20 * time.Millisecond)
+	store.Save("c", []byte("c"))
+	if store.order.Len() != 1 || len(store.tokens) != 1 {
+		t.Errorf("Expected only c to be kept, got %d tokens", len(store.tokens))
+	}
+}
@@ -17,10 +17,54 @@
 package cmd
 
 import (
+	"errors"
+	"io"
+	"path"
 	"sort"
 	"strings"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
+// errObjectPrefixCollision - an object has the same name as a sibling prefix.
+var errObjectPrefixCollision = errors.New("Object name collides with a prefix")
+
+// errDirCycle - a directory lists one of its ancestors (e.g through a
+// symlink), walking it would never end.
+var errDirCycle = errors.New("Directory cycle detected")
+
+// errWalkMaxKeys - returned by doTreeWalk() once maxKeys objects were sent,
+// it ends the walk without listing anything further.
+var errWalkMaxKeys = errors.New("treeWalk sent maxKeys entries")
+
+// DuplicatePolicy - how a walk treats an object whose name, after the
+// trailing "/" is trimmed, is also a prefix holding other objects, e.g.
+// an XL object "a" stored in "a/" alongside an object "a/b".
+type DuplicatePolicy int
+
+// Duplicate policies.
+const (
+	// DuplicatePreferObject - list "a" only, objects beneath it are hidden.
+	DuplicatePreferObject DuplicatePolicy = iota
+	// DuplicatePreferPrefix - list "a/" only, object "a" is hidden.
+	DuplicatePreferPrefix
+	// DuplicateEmitBoth - list both "a" and "a/".
+	DuplicateEmitBoth
+	// DuplicateError - fail the walk with errObjectPrefixCollision.
+	DuplicateError
+)
+
+// errListDirTimeout - disk.ListDir() did not return in time, the disk is
+// skipped like one which went offline.
+var errListDirTimeout = errors.New("Listing directory timed out")
+
+// list of transient errors disk.ListDir() is retried on by listDirFactoryWithOpts().
+var listDirRetriableErrs = []error{
+	errVolumeBusy,
+	io.ErrUnexpectedEOF,
+}
+
 // list of all errors that can be ignored in tree walk operation.
 var walkResultIgnoredErrs = []error{
 	errFileNotFound,
@@ -32,9 +76,314 @@ var walkResultIgnoredErrs = []error{
 
 // Tree walk result carries results of tree walking.
 type treeWalkResult struct {
-	entry string
-	err   error
-	end   bool
+	entry     string
+	err       error
+	end       bool
+	heartbeat bool // Set when the walk is alive but blocked on listDir() of "entry".
+	denied    bool // Set when "entry" is a directory skipped since canRead() denied it.
+	isDir     bool // Set when "entry" is a directory or common prefix rather than an object.
+	skipped   bool // Set along with err when directory "entry" failed to list and the walk went on.
+	truncated bool // Set on the last entry of a walk which stopped at maxKeys before listing everything.
+	// Set on entries of walks with statEntry set.
+	info *treeWalkEntryInfo
+	// Set on the final result of walks with emitSummary set.
+	summary *treeWalkSummary
+}
+
+// treeWalkEntryInfo - metadata of a walk entry.
+type treeWalkEntryInfo struct {
+	size    int64
+	modTime time.Time
+	etag    string
+	isDir   bool
+}
+
+// statEntryFunc - returns the metadata of object entry, like isLeafFunc it
+// is called with the full path of the entry in bucket.
+type statEntryFunc func(bucket, entry string) (treeWalkEntryInfo, error)
+
+// treeWalkSummary - totals of a walk.
+type treeWalkSummary struct {
+	objects  int64         // Number of entries sent.
+	dirs     int64         // Number of directories listed.
+	errs     int64         // Number of errors sent.
+	duration time.Duration // Time from start till the end of the walk.
+}
+
+// treeWalkOpts - optional tree walk behavior, the zero value walks
+// exactly like startTreeWalk() does.
+type treeWalkOpts struct {
+	// When non-zero, a heartbeat result is sent every heartbeatInterval
+	// while a listDir() call is pending, so that consumers can tell a
+	// walk blocked on a slow disk apart from a walk which is done.
+	heartbeatInterval time.Duration
+
+	// When set, consumers can send a prefix on skipCh to prune it from
+	// the walk. Skipping is best-effort, entries already sent on the
+	// result channel before the prefix is received are not recalled.
+	skipCh       <-chan string
+	skipPrefixes []string // Prefixes received on skipCh so far.
+
+	// When non-zero, caps the number of directories the walk has open,
+	// i.e. listDir() calls in progress, at any time so that a walk can
+	// not exhaust file descriptors on an FS backend. Once the cap is
+	// reached the walk waits for a slot before listing the next directory.
+	maxOpenDirs int
+	openDirsCh  chan struct{} // Holds a value for every open directory.
+
+	// When set, a final result carrying the walk's totals is sent just
+	// before the result channel is closed, unless the walk was aborted.
+	emitSummary bool
+	summary     treeWalkSummary // Totals accumulated so far.
+
+	// When set, the walk first verifies the bucket exists on these
+	// disks and fails with BucketNotFound if it does not, so that an
+	// empty bucket can be told apart from a missing one. Callers which
+	// have already verified the bucket leave it unset.
+	bucketDisks []StorageAPI
+
+	// When set, canRead is consulted before recursing into a directory
+	// and directories it denies are skipped instead of failing the whole
+	// walk. With emitDenied set a denied result is sent for each of them.
+	canRead    func(prefixDir string) bool
+	emitDenied bool
+
+	// When set, isPrefix is called with every object name ending in "/"
+	// and should return true if it also holds other objects, colliding
+	// objects are then handled according to duplicatePolicy. The zero
+	// value DuplicatePreferObject is how walks behave without isPrefix.
+	isPrefix        isLeafFunc
+	duplicatePolicy DuplicatePolicy
+
+	// When set, doneFn is called once the walk has ended and its result
+	// channel is closed.
+	doneFn func()
+
+	// When set to anything but "/", keys are grouped by delimiter like
+	// S3 does: the part of a key after the walk's prefix upto and
+	// including the first delimiter is sent once as a common prefix in
+	// place of all the keys sharing it. Directories on disk are always
+	// separated by "/", such walks are hence always recursive, listDir()
+	// and isLeaf() are unaffected.
+	delimiter  string
+	walkPrefix string // Prefix of the walk, keys are grouped after it.
+	lastPrefix string // Common prefix sent last.
+
+	// When set, recursive walks send a result with isDir set for every
+	// directory before walking it, non-recursive walks always send
+	// directories as they are not walked.
+	emitDirs bool
+
+	// When non-zero, the walk ends once maxKeys objects were sent rather
+	// than listing directories whose entries the consumer has no use for.
+	// Directories and common prefixes sent do not count. Unless it is the
+	// last entry of the walk anyway the last object is sent with truncated
+	// rather than end set, so that consumers can tell there is more.
+	maxKeys  int
+	keysSent int // Objects sent so far.
+
+	// When set, entries are sent in descending order. A marker then
+	// resumes the walk with the entries sorting before it.
+	reverse bool
+
+	// When set, only objects matching one of the include glob patterns
+	// and none of the exclude patterns are sent, see tree-walk-glob.go.
+	// Directories are only walked or sent if they may hold such objects.
+	include []string
+	exclude []string
+
+	// When set, only entries for which filter returns true are sent,
+	// directories it returns false for are neither sent nor walked.
+	filter func(path string, isDir bool) bool
+
+	// When set, objects are sent with info returned by statEntry, so that
+	// consumers need not stat every object themselves, and directories
+	// with info only having isDir set. Objects removed since they were
+	// listed are skipped, any other statEntry error fails the walk.
+	statEntry statEntryFunc
+
+	// When set, the walk accumulates its counters in stats.
+	stats *treeWalkStats
+
+	// Capacity of the result channel, i.e. how far the walk may run ahead
+	// of its consumer. Zero defaults to maxObjectList.
+	bufferSize int
+
+	// Set to the listDirOpts.foldCase and less listDir was created with,
+	// so that markers are searched for in the order entries are listed
+	// in. Keys are then ordered a directory at a time, e.g. everything
+	// under "A/" is sent before "a/" when ordered case-insensitively.
+	foldCase bool
+	less     func(a, b string) bool
+
+	walkMarker string // Marker the walk started from.
+
+	// When set, recursive walks resume after the directory holding the
+	// marker rather than walking the rest of it, e.g. marker "a/b/c"
+	// resumes with the entries after "a/", as if everything under "a/" was
+	// consumed already.
+	skipMarkerDir bool
+
+	// When set, only keys sorting after startAfter are sent, like with a
+	// marker, which it overrides when later in walk order. Unlike marker
+	// it need not be beneath the walk's prefix, e.g. with prefix "b/"
+	// startAfter "a" lists everything and startAfter "c" nothing.
+	startAfter string
+
+	// When set, objects are only counted in objectCount rather than sent,
+	// without any of the filtering above, see CountObjects().
+	countOnly   bool
+	objectCount int64
+
+	// When non-zero, recursive walks list at most maxDepth levels of
+	// directories, e.g. with 1 they list like non-recursive walks do.
+	// Directories on the last level are sent with isDir set instead.
+	maxDepth int
+
+	// When set, a directory beneath the walk's prefix which fails to list
+	// does not fail the walk, a result with skipped and err set is sent
+	// for it instead and the walk goes on with the other directories.
+	skipErrDirs bool
+
+	// When set, a walk which completes without an error always ends with
+	// exactly one result with end set, walks stopped at maxKeys have none. If no entry was sent as the end,
+	// e.g. since the marker sorts after every entry or the last entries
+	// were filtered out, an empty result with only end set is sent.
+	emitEnd bool
+	endSent bool
+
+	// When set, realDir returns the canonical path of a directory, e.g.
+	// with symlinks resolved, see realDirFunc(). parentDir is the canonical
+	// path of its parent directory, empty for the directory a walk starts
+	// in, so that only directories which are symlinks need resolving.
+	// Without it directories are only told apart by their cleaned paths,
+	// so that a symlink to an ancestor directory is walked over and over
+	// under ever longer names.
+	realDir func(bucket, prefixDir, parentDir string) (string, error)
+
+	// Canonical paths of the directories being walked, i.e. the current
+	// directory and its ancestors, to detect directory cycles.
+	walkingDirs map[string]struct{}
+}
+
+// abort - returns the error of a walk aborted since ctx is done.
+func (opts *treeWalkOpts) abort(ctx context.Context, bucket string) error {
+	return traceError(WalkAborted{
+		Bucket: bucket,
+		Prefix: opts.walkPrefix,
+		Marker: opts.walkMarker,
+		Reason: ctx.Err(),
+	})
+}
+
+// isDirInfo - info sent along with directories.
+var isDirInfo = treeWalkEntryInfo{isDir: true}
+
+// entryInfo - returns info to send along with an entry, nil unless
+// statEntry is set.
+func (opts *treeWalkOpts) entryInfo(info treeWalkEntryInfo) *treeWalkEntryInfo {
+	if opts.statEntry == nil {
+		return nil
+	}
+	return &info
+}
+
+// sendDir - sends a result for directory dir, end is set if it is the
+// last entry of the walk.
+func (opts *treeWalkOpts) sendDir(ctx context.Context, bucket, dir string, end bool, resultCh chan treeWalkResult) error {
+	select {
+	case <-ctx.Done():
+		return opts.abort(ctx, bucket)
+	case resultCh <- treeWalkResult{entry: dir, isDir: true, end: end, info: opts.entryInfo(isDirInfo)}:
+		opts.summary.objects++
+		opts.endSent = opts.endSent || end
+	}
+	return nil
+}
+
+// Returns true if keys are grouped by a delimiter other than "/".
+func (opts *treeWalkOpts) groupByDelimiter() bool {
+	return opts.delimiter != "" && opts.delimiter != slashSeparator
+}
+
+// commonPrefix - returns the common prefix key is grouped under, or an
+// empty string if key is not grouped.
+func (opts *treeWalkOpts) commonPrefix(key string) string {
+	rest := strings.TrimPrefix(key, opts.walkPrefix)
+	i := strings.Index(rest, opts.delimiter)
+	if i == -1 {
+		return ""
+	}
+	return opts.walkPrefix + rest[:i+len(opts.delimiter)]
+}
+
+// Applies duplicatePolicy to entries listed in prefixDir, returns the
+// sorted entries with the isLeaf() check done.
+func (opts *treeWalkOpts) resolveDuplicates(bucket, prefixDir string, entries []string, delayIsLeaf bool, isLeaf isLeafFunc) ([]string, error) {
+	resolved := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if delayIsLeaf && isLeaf(bucket, pathJoin(prefixDir, entry)) {
+			entry = strings.TrimSuffix(entry, slashSeparator)
+		}
+		if strings.HasSuffix(entry, slashSeparator) || !opts.isPrefix(bucket, pathJoin(prefixDir, entry)+slashSeparator) {
+			resolved = append(resolved, entry)
+			continue
+		}
+		switch opts.duplicatePolicy {
+		case DuplicatePreferObject:
+			resolved = append(resolved, entry)
+		case DuplicatePreferPrefix:
+			resolved = append(resolved, entry+slashSeparator)
+		case DuplicateEmitBoth:
+			resolved = append(resolved, entry, entry+slashSeparator)
+		default:
+			return nil, traceError(errObjectPrefixCollision)
+		}
+	}
+	// Prefixes added sort differently from the objects they replace.
+	sortEntries(resolved, entriesLess(opts.less, opts.foldCase))
+	return resolved, nil
+}
+
+// Returns nil if bucket exists on any of the disks.
+func statWalkBucket(bucket string, disks []StorageAPI) error {
+	var err error
+	for _, disk := range disks {
+		if disk == nil {
+			err = errDiskNotFound
+			continue
+		}
+		if _, err = disk.StatVol(bucket); err == nil {
+			return nil
+		}
+	}
+	if err == errVolumeNotFound {
+		return traceError(BucketNotFound{Bucket: bucket})
+	}
+	return traceError(err)
+}
+
+// skip - returns true if the consumer asked to skip entry or one of its
+// parent directories.
+func (opts *treeWalkOpts) skip(entry string) bool {
+	if opts.skipCh == nil {
+		return false
+	}
+	// Pick up all the prefixes sent since the last check.
+	for received := true; received; {
+		select {
+		case prefix := <-opts.skipCh:
+			opts.skipPrefixes = append(opts.skipPrefixes, prefix)
+		default:
+			received = false
+		}
+	}
+	for _, prefix := range opts.skipPrefixes {
+		if strings.HasPrefix(entry, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // posix.ListDir returns entries with trailing "/" for directories. At the object layer
@@ -47,6 +396,9 @@ type treeWalkResult struct {
 // can be delayed till the entry is pushed into the treeWalkResult channel.
 // delayIsLeafCheck() returns true if isLeaf can be delayed or false if
 // isLeaf should be done in listDir()
+// Entries are compared byte by byte, the same way strings sort, so names with
+// multi-byte UTF-8 characters need no special handling: all bytes of a multi-byte
+// character are >= 0x80 and never sort before or equal to '/'.
 func delayIsLeafCheck(entries []string) bool {
 	for i, entry := range entries {
 		if i == len(entries)-1 {
@@ -67,30 +419,107 @@ func delayIsLeafCheck(entries []string) bool {
 	return true
 }
 
+// Returns a copy of entries in reverse order.
+func reverseEntries(entries []string) []string {
+	reversed := make([]string, len(entries))
+	for i, entry := range entries {
+		reversed[len(entries)-1-i] = entry
+	}
+	return reversed
+}
+
+// lessFoldCase - orders entries case-insensitively, e.g. "Apple" <
+// "banana" < "Cherry". Only entries differing in case alone are ordered
+// byte-wise, so that the order is total.
+func lessFoldCase(a, b string) bool {
+	if lowerA, lowerB := strings.ToLower(a), strings.ToLower(b); lowerA != lowerB {
+		return lowerA < lowerB
+	}
+	return a < b
+}
+
+// entriesLess - returns the order of entries set by the less and foldCase
+// options of listDirOpts or treeWalkOpts, nil for byte-wise order.
+func entriesLess(less func(a, b string) bool, foldCase bool) func(a, b string) bool {
+	if less == nil && foldCase {
+		return lessFoldCase
+	}
+	return less
+}
+
+// lessEntry - returns true if entry a sorts before b by less, byte-wise
+// if less is nil.
+func lessEntry(less func(a, b string) bool, a, b string) bool {
+	if less == nil {
+		return a < b
+	}
+	return less(a, b)
+}
+
+// byEntryLess - sort entries by less.
+type byEntryLess struct {
+	entries []string
+	less    func(a, b string) bool
+}
+
+func (e byEntryLess) Len() int           { return len(e.entries) }
+func (e byEntryLess) Swap(i, j int)      { e.entries[i], e.entries[j] = e.entries[j], e.entries[i] }
+func (e byEntryLess) Less(i, j int) bool { return e.less(e.entries[i], e.entries[j]) }
+
+// sortEntries - sorts entries by less, byte-wise if less is nil.
+func sortEntries(entries []string, less func(a, b string) bool) {
+	if less == nil {
+		sort.Strings(entries)
+		return
+	}
+	sort.Sort(byEntryLess{entries, less})
+}
+
 // Return entries that have prefix prefixEntry.
 // Note: input entries are expected to be sorted.
 func filterMatchingPrefix(entries []string, prefixEntry string) []string {
-	start := 0
-	end := len(entries)
-	for {
-		if start == end {
-			break
-		}
-		if strings.HasPrefix(entries[start], prefixEntry) {
-			break
+	// Entries with prefixEntry are contiguous, starting with the first
+	// entry not sorting before prefixEntry.
+	start := sort.Search(len(entries), func(i int) bool {
+		return entries[i] >= prefixEntry
+	})
+	end := start + sort.Search(len(entries)-start, func(i int) bool {
+		return !strings.HasPrefix(entries[start+i], prefixEntry)
+	})
+	return entries[start:end]
+}
+
+// filterMatchingPrefixFoldCase - same as filterMatchingPrefix() for
+// entries sorted case-insensitively. Entries with prefixEntry are then
+// not contiguous, e.g. "ABc" sorts between "Abb" and "Abd", only the
+// entries with prefixEntry in any case are.
+func filterMatchingPrefixFoldCase(entries []string, prefixEntry string) []string {
+	lowerPrefix := strings.ToLower(prefixEntry)
+	start := sort.Search(len(entries), func(i int) bool {
+		return strings.ToLower(entries[i]) >= lowerPrefix
+	})
+	end := start + sort.Search(len(entries)-start, func(i int) bool {
+		return !strings.HasPrefix(strings.ToLower(entries[start+i]), lowerPrefix)
+	})
+	var matching []string
+	for _, entry := range entries[start:end] {
+		if strings.HasPrefix(entry, prefixEntry) {
+			matching = append(matching, entry)
 		}
-		start++
 	}
-	for {
-		if start == end {
-			break
-		}
-		if strings.HasPrefix(entries[end-1], prefixEntry) {
-			break
+	return matching
+}
+
+// filterMatchingPrefixAnyOrder - same as filterMatchingPrefix() for
+// entries in any order, e.g. sorted by a custom less function.
+func filterMatchingPrefixAnyOrder(entries []string, prefixEntry string) []string {
+	var matching []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, prefixEntry) {
+			matching = append(matching, entry)
 		}
-		end--
 	}
-	return entries[start:end]
+	return matching
 }
 
 // "listDir" function of type listDirFunc returned by listDirFactory() - explained below.
@@ -104,108 +533,531 @@ type listDirFunc func(bucket, prefixDir, prefixEntry string) (entries []string,
 // 4. XL backend multipart listing - isLeaf is true if the entry is a directory and contains uploads.json
 type isLeafFunc func(string, string) bool
 
+// Maximum number of disks listDirFactory() lists a directory from at once.
+const listDirConcurrency = 4
+
 // Returns function "listDir" of the type listDirFunc.
 // isLeaf - is used by listDir function to check if an entry is a leaf or non-leaf entry.
 // disks - used for doing disk.ListDir(). FS passes single disk argument, XL passes a list of disks.
+// Disks are listed concurrently, upto listDirConcurrency at a time, and the first
+// successful listing is used so that a slow or faulty disk does not hold up the walk.
 func listDirFactory(isLeaf isLeafFunc, disks ...StorageAPI) listDirFunc {
+	return listDirFactoryWithOpts(isLeaf, listDirOpts{prefetch: true}, disks...)
+}
+
+// listDirOpts - optional listDir behavior, the zero value lists like
+// listDirFactory() does without prefetching.
+type listDirOpts struct {
+	// disk.ListDir() failing with one of listDirRetriableErrs is retried
+	// upto retries times, waiting baseDelay before the first retry and
+	// twice as long before every next one. A disk which still fails is
+	// then skipped in favour of the others.
+	retries   int
+	baseDelay time.Duration
+
+	// When set, directory listings are served from cache if possible and
+	// cached once listed from the disks.
+	cache *listDirCache
+
+	// When set, disk errors ignored while listing are counted in stats.
+	stats *treeWalkStats
+
+	// When set, onIgnoredErr is called for every disk skipped since its
+	// error was ignored, so that degraded disks do not go unnoticed. It
+	// may be called concurrently by listings of different directories.
+	onIgnoredErr func(disk StorageAPI, bucket, prefixDir string, err error)
+
+	// disk.ListDir() not returning within timeout fails with
+	// errListDirTimeout and the disk is skipped in favour of the others,
+	// so that a hung disk can not stall listings. Zero waits forever.
+	timeout time.Duration
+
+	// When set, disks are listed least loaded first according to
+	// loadTracker, which tracks the disks by their index in the disks
+	// listed, rather than in the order they were passed in.
+	loadTracker *diskLoadTracker
+
+	// When set, entries are sorted case-insensitively, see lessFoldCase(),
+	// or by less, e.g. to order "img2" before "img10". Walks using such a
+	// listDir need the same treeWalkOpts.foldCase or less set, otherwise
+	// markers are searched for in the wrong order. less takes precedence.
+	foldCase bool
+	less     func(a, b string) bool
+
+	// When set, a directory walked into is listed along with the
+	// sub-directories after it in its parent in a single round trip, see
+	// listDirPrefetcher, so that walking them does not list each of them
+	// from the disks. Ignored with union or strict set since the listings
+	// come from a single disk.
+	prefetch bool
+
+	// When set, every disk is listed and the union of their entries is
+	// returned rather than the entries of the first disk listed, so that
+	// directories spread unevenly across disks, e.g. while healing, are
+	// listed completely. Disks whose errors are ignored are left out.
+	union bool
+
+	// Errors skipping a disk in addition to walkResultIgnoredErrs, e.g.
+	// errors specific to a backend. With strict set no error skips a disk
+	// instead, not even those of walkResultIgnoredErrs.
+	ignoredErrs []error
+	strict      bool
+}
+
+// Returns function "listDir" of the type listDirFunc like listDirFactory()
+// with optional behavior set in opts.
+func listDirFactoryWithOpts(isLeaf isLeafFunc, opts listDirOpts, disks ...StorageAPI) listDirFunc {
+	less := entriesLess(opts.less, opts.foldCase)
+	var prefetcher *listDirPrefetcher
+	if opts.prefetch && !opts.union && !opts.strict {
+		prefetcher = newListDirPrefetcher()
+	}
 	// listDir - lists all the entries at a given prefix and given entry in the prefix.
 	listDir := func(bucket, prefixDir, prefixEntry string) (entries []string, delayIsLeaf bool, err error) {
-		for _, disk := range disks {
-			if disk == nil {
+		var cached bool
+		if opts.cache != nil {
+			entries, cached = opts.cache.get(bucket, prefixDir)
+		}
+		var prefetched bool
+		if !cached && prefetcher != nil {
+			entries, prefetched = prefetcher.take(bucket, prefixDir, disks)
+		}
+		if !cached && !prefetched {
+			entries, err = listDirAnyDisk(bucket, prefixDir, disks, opts)
+			if err != nil {
+				return nil, false, traceError(err)
+			}
+		}
+		if !cached && opts.cache != nil {
+			opts.cache.set(bucket, prefixDir, entries)
+		}
+		// Listing needs to be sorted.
+		sortEntries(entries, less)
+
+		// Filter entries that have the prefix prefixEntry.
+		switch {
+		case less == nil:
+			entries = filterMatchingPrefix(entries, prefixEntry)
+		case opts.less == nil:
+			entries = filterMatchingPrefixFoldCase(entries, prefixEntry)
+		default:
+			entries = filterMatchingPrefixAnyOrder(entries, prefixEntry)
+		}
+		if prefetcher != nil {
+			prefetcher.listed(bucket, prefixDir, entries)
+		}
+
+		// Can isLeaf() check be delayed till when it has to be sent down the
+		// treeWalkResult channel? Removing the trailing "/" of "A/" moves it
+		// before "a" when sorted case-insensitively for example, hence never
+		// delayed unless sorted byte-wise.
+		delayIsLeaf = less == nil && delayIsLeafCheck(entries)
+		if delayIsLeaf {
+			return entries, delayIsLeaf, nil
+		}
+
+		// isLeaf() check has to happen here so that trailing "/" for objects can be removed.
+		for i, entry := range entries {
+			if isLeaf(bucket, pathJoin(prefixDir, entry)) {
+				entries[i] = strings.TrimSuffix(entry, slashSeparator)
+			}
+		}
+		// Sort again after removing trailing "/" for objects as the previous sort
+		// does not hold good anymore.
+		sortEntries(entries, less)
+		return entries, delayIsLeaf, nil
+	}
+	return listDir
+}
+
+// ignoresErr - returns true if a disk failing to list with err is skipped.
+func (opts listDirOpts) ignoresErr(err error) bool {
+	if opts.strict {
+		return false
+	}
+	return err == errListDirTimeout || isErrIgnored(err, walkResultIgnoredErrs) || isErrIgnored(err, opts.ignoredErrs)
+}
+
+// listDirAnyDisk - lists prefixDir on all disks concurrently and returns the
+// first successful listing, the disks still listing are not waited for.
+// With opts.union set all disks are waited for and their entries merged.
+// Disks failing with one of walkResultIgnoredErrs or opts.ignoredErrs,
+// e.g. a disk which was deleted or went offline, are skipped, any other
+// error is returned right away. If every disk is skipped the first of their
+// errors is returned, errDiskNotFound if there is no disk at all. With
+// opts.retries set, disks failing with one of listDirRetriableErrs are
+// retried and skipped if they keep failing. Disks not replying within
+// opts.timeout are skipped as well. With opts.strict set no disk is
+// skipped, every error is returned and all disks are waited for so that
+// a disk failing after another one listed still fails the listing. With
+// opts.loadTracker set the least loaded disks are listed first.
+func listDirAnyDisk(bucket, prefixDir string, disks []StorageAPI, opts listDirOpts) ([]string, error) {
+	type listDirReply struct {
+		disk    StorageAPI
+		entries []string
+		err     error
+	}
+	// Buffered so that listings finishing after a reply was picked never block.
+	replyCh := make(chan listDirReply, len(disks))
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	listingCh := make(chan struct{}, listDirConcurrency) // Holds a value for every disk being listed.
+
+	// Disks are handed listing slots in order, least loaded first if
+	// their load is tracked.
+	var order []int
+	if opts.loadTracker != nil {
+		order = opts.loadTracker.leastLoaded()
+	} else {
+		for index := range disks {
+			order = append(order, index)
+		}
+	}
+	pending := 0
+	for _, disk := range disks {
+		if disk != nil {
+			pending++
+		}
+	}
+	listDisk := func(index int) {
+		defer func() { <-listingCh }()
+		disk := disks[index]
+		if opts.loadTracker != nil {
+			opts.loadTracker.start(index)
+			defer opts.loadTracker.done(index)
+		}
+		entries, err := listDirWithTimeout(disk, bucket, prefixDir, opts.timeout)
+		delay := opts.baseDelay
+		for retry := 0; retry < opts.retries && isErrIgnored(err, listDirRetriableErrs); retry++ {
+			select {
+			case <-doneCh:
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			entries, err = listDirWithTimeout(disk, bucket, prefixDir, opts.timeout)
+		}
+		replyCh <- listDirReply{disk, entries, err}
+	}
+	go func() {
+		for _, index := range order {
+			if disks[index] == nil {
 				continue
 			}
-			entries, err = disk.ListDir(bucket, prefixDir)
-			if err == nil {
-				// Listing needs to be sorted.
-				sort.Strings(entries)
-
-				// Filter entries that have the prefix prefixEntry.
-				entries = filterMatchingPrefix(entries, prefixEntry)
-
-				// Can isLeaf() check be delayed till when it has to be sent down the
-				// treeWalkResult channel?
-				delayIsLeaf = delayIsLeafCheck(entries)
-				if delayIsLeaf {
-					return entries, delayIsLeaf, nil
-				}
+			select {
+			case <-doneCh:
+				return
+			case listingCh <- struct{}{}:
+			}
+			// Do not list once a reply was picked while waiting for a slot.
+			select {
+			case <-doneCh:
+				<-listingCh
+				return
+			default:
+			}
+			go listDisk(index)
+		}
+	}()
 
-				// isLeaf() check has to happen here so that trailing "/" for objects can be removed.
-				for i, entry := range entries {
-					if isLeaf(bucket, pathJoin(prefixDir, entry)) {
-						entries[i] = strings.TrimSuffix(entry, slashSeparator)
-					}
+	// No disk could be listed at all.
+	if pending == 0 {
+		return nil, errDiskNotFound
+	}
+	var firstErr error
+	var unionEntries, strictEntries []string
+	listed := false
+	seen := make(map[string]struct{})
+	for ; pending > 0; pending-- {
+		reply := <-replyCh
+		if reply.err == nil {
+			if !opts.union {
+				if !opts.strict {
+					return reply.entries, nil
+				}
+				// In strict mode any disk failing fails the listing,
+				// hence the first listing is kept until every disk replied.
+				if !listed {
+					listed = true
+					strictEntries = reply.entries
 				}
-				// Sort again after removing trailing "/" for objects as the previous sort
-				// does not hold good anymore.
-				sort.Strings(entries)
-				return entries, delayIsLeaf, nil
-			}
-			// For any reason disk was deleted or goes offline, continue
-			// and list from other disks if possible.
-			if isErrIgnored(err, walkResultIgnoredErrs) {
 				continue
 			}
-			break
+			// Entries listed by more than one disk are kept once.
+			listed = true
+			for _, entry := range reply.entries {
+				if _, ok := seen[entry]; !ok {
+					seen[entry] = struct{}{}
+					unionEntries = append(unionEntries, entry)
+				}
+			}
+			continue
 		}
-		// Return error at the end.
-		return nil, false, traceError(err)
+		err := reply.err
+		// Disk kept failing with a transient error even after the retries.
+		retriesFailed := opts.retries > 0 && isErrIgnored(err, listDirRetriableErrs)
+		if opts.ignoresErr(err) || retriesFailed && !opts.strict {
+			opts.stats.addIgnoredErr()
+			if opts.onIgnoredErr != nil {
+				opts.onIgnoredErr(reply.disk, bucket, prefixDir, err)
+			}
+			// The first error is usually the root cause, e.g. the one
+			// disk with the directory failed before the others which
+			// do not have it.
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return nil, err
+	}
+	if listed && opts.strict && !opts.union {
+		return strictEntries, nil
+	}
+	if listed {
+		return unionEntries, nil
+	}
+	return nil, firstErr
+}
+
+// Returns disk.ListDir() of prefixDir or errListDirTimeout if it does not
+// return within timeout, a zero timeout waits forever. The call is left
+// running in the background once timed out.
+func listDirWithTimeout(disk StorageAPI, bucket, prefixDir string, timeout time.Duration) ([]string, error) {
+	if timeout <= 0 {
+		return disk.ListDir(bucket, prefixDir)
+	}
+	type listDirReply struct {
+		entries []string
+		err     error
+	}
+	// Buffered so that a listing finishing after the timeout never blocks.
+	replyCh := make(chan listDirReply, 1)
+	go func() {
+		entries, err := disk.ListDir(bucket, prefixDir)
+		replyCh <- listDirReply{entries, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-replyCh:
+		return reply.entries, reply.err
+	case <-timer.C:
+		return nil, errListDirTimeout
 	}
-	return listDir
 }
 
-// treeWalk walks directory tree recursively pushing treeWalkResult into the channel as and when it encounters files.
-func doTreeWalk(bucket, prefixDir, entryPrefixMatch, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, resultCh chan treeWalkResult, endWalkCh chan struct{}, isEnd bool) error {
+// listDirHeartbeat - calls listDir() and while it is pending sends a
+// heartbeat result for prefixDir every interval. Heartbeats are sent
+// without blocking, a consumer which has not yet drained the results
+// already knows the walk is alive.
+func listDirHeartbeat(bucket, prefixDir, entryPrefixMatch string, listDir listDirFunc, interval time.Duration, resultCh chan treeWalkResult, doneCh <-chan struct{}) (entries []string, delayIsLeaf bool, err error) {
+	if interval <= 0 {
+		return listDir(bucket, prefixDir, entryPrefixMatch)
+	}
+
+	type listDirReply struct {
+		entries     []string
+		delayIsLeaf bool
+		err         error
+	}
+	// Buffered so that the listDir() go-routine never blocks if the walk was aborted.
+	replyCh := make(chan listDirReply, 1)
+	go func() {
+		entries, delayIsLeaf, err := listDir(bucket, prefixDir, entryPrefixMatch)
+		replyCh <- listDirReply{entries, delayIsLeaf, err}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case reply := <-replyCh:
+			return reply.entries, reply.delayIsLeaf, reply.err
+		case <-doneCh:
+			return nil, false, errWalkAbort
+		case <-ticker.C:
+			select {
+			case resultCh <- treeWalkResult{entry: prefixDir, heartbeat: true}:
+			default:
+			}
+		}
+	}
+}
+
+// Returns listDir releasing a slot of openDirsCh once it is done. The
+// slot is released by listDir() itself rather than the walk, since with
+// heartbeats the walk may give up on a listDir() still in progress.
+func releaseOpenDir(listDir listDirFunc, openDirsCh chan struct{}) listDirFunc {
+	return func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		defer func() { <-openDirsCh }()
+		return listDir(bucket, prefixDir, prefixEntry)
+	}
+}
+
+// treeWalkDir - a directory being walked by doTreeWalk().
+type treeWalkDir struct {
+	prefixDir   string
+	dir         string // Canonical path of prefixDir.
+	entries     []string
+	delayIsLeaf bool
+	next        int // Index of the next entry to be walked.
+	depth       int // Directory levels from the walk's prefix, 1 for its own directory.
+
 	// Example:
-	// if prefixDir="one/two/three/" and marker="four/five.txt" treeWalk is recursively
-	// called with prefixDir="one/two/three/four/" and marker="five.txt"
+	// if prefixDir="one/two/three/" and marker="four/five.txt" the walk
+	// continues in prefixDir="one/two/three/four/" with marker="five.txt"
+	markerDir  string
+	markerBase string
+
+	isEnd bool // Set if the last entry of the directory ends the walk.
+
+	// Set when the directory itself is sent once all entries beneath it
+	// were, i.e. in reverse with emitDirs set, dirIsEnd is then its end.
+	sendDirLast bool
+	dirIsEnd    bool
+}
+
+// openTreeWalkDir - lists prefixDir and returns its entries from marker on.
+// parentDir is the canonical path of its parent, empty if not walked.
+// Errors are left to the caller to send, unless the walk was aborted.
+func openTreeWalkDir(ctx context.Context, bucket, prefixDir, parentDir, entryPrefixMatch, marker string, listDir listDirFunc, isLeaf isLeafFunc, resultCh chan treeWalkResult, isEnd bool, opts *treeWalkOpts) (*treeWalkDir, error) {
+	if ctx.Err() != nil {
+		return nil, opts.abort(ctx, bucket)
+	}
+	d := &treeWalkDir{prefixDir: prefixDir, isEnd: isEnd}
+	// Walking a directory beneath itself again would never end.
+	d.dir = path.Clean(slashSeparator + prefixDir)
+	if opts.realDir != nil {
+		dir, err := opts.realDir(bucket, prefixDir, parentDir)
+		if err != nil {
+			return nil, traceError(err)
+		}
+		d.dir = dir
+	}
+	if _, ok := opts.walkingDirs[d.dir]; ok {
+		return nil, traceError(errDirCycle)
+	}
 
-	var markerBase, markerDir string
 	if marker != "" {
 		// Ex: if marker="four/five.txt", markerDir="four/" markerBase="five.txt"
 		markerSplit := strings.SplitN(marker, slashSeparator, 2)
-		markerDir = markerSplit[0]
+		d.markerDir = markerSplit[0]
 		if len(markerSplit) == 2 {
-			markerDir += slashSeparator
-			markerBase = markerSplit[1]
+			d.markerDir += slashSeparator
+			d.markerBase = markerSplit[1]
 		}
 	}
-	entries, delayIsLeaf, err := listDir(bucket, prefixDir, entryPrefixMatch)
-	if err != nil {
+	openDir := listDir
+	if opts.openDirsCh != nil {
+		// Wait for a slot, openDir() releases it when done.
 		select {
-		case <-endWalkCh:
-			return traceError(errWalkAbort)
-		case resultCh <- treeWalkResult{err: err}:
-			return err
+		case <-ctx.Done():
+			return nil, opts.abort(ctx, bucket)
+		case opts.openDirsCh <- struct{}{}:
 		}
+		openDir = releaseOpenDir(listDir, opts.openDirsCh)
 	}
-	// For an empty list return right here.
-	if len(entries) == 0 {
-		return nil
+	entries, delayIsLeaf, err := listDirHeartbeat(bucket, prefixDir, entryPrefixMatch, openDir, opts.heartbeatInterval, resultCh, ctx.Done())
+	if err == errWalkAbort {
+		return nil, opts.abort(ctx, bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+	opts.summary.dirs++
+	opts.stats.addDir()
+	if opts.isPrefix != nil {
+		if entries, err = opts.resolveDuplicates(bucket, prefixDir, entries, delayIsLeaf, isLeaf); err != nil {
+			return nil, err
+		}
+		delayIsLeaf = false
 	}
 
-	// example:
-	// If markerDir="four/" Search() returns the index of "four/" in the sorted
-	// entries list so we skip all the entries till "four/"
-	idx := sort.Search(len(entries), func(i int) bool {
-		return entries[i] >= markerDir
-	})
-	entries = entries[idx:]
-	// For an empty list after search through the entries, return right here.
-	if len(entries) == 0 {
-		return nil
+	less := entriesLess(opts.less, opts.foldCase)
+	if opts.reverse {
+		// Entries sorting after markerDir were listed by the previous listing,
+		// the rest is walked in descending order so that "four/" comes first.
+		idx := len(entries)
+		if marker != "" {
+			idx = sort.Search(len(entries), func(i int) bool {
+				return lessEntry(less, d.markerDir, entries[i])
+			})
+		}
+		entries = reverseEntries(entries[:idx])
+	} else {
+		// example:
+		// If markerDir="four/" Search() returns the index of "four/" in the sorted
+		// entries list so we skip all the entries till "four/"
+		idx := sort.Search(len(entries), func(i int) bool {
+			return !lessEntry(less, entries[i], d.markerDir)
+		})
+		entries = entries[idx:]
 	}
-	for i, entry := range entries {
+	d.entries = entries
+	d.delayIsLeaf = delayIsLeaf
+	return d, nil
+}
+
+// sendErr - sends err as a result and returns it.
+func (opts *treeWalkOpts) sendErr(ctx context.Context, bucket string, err error, resultCh chan treeWalkResult) error {
+	select {
+	case <-ctx.Done():
+		return opts.abort(ctx, bucket)
+	case resultCh <- treeWalkResult{err: err}:
+		opts.summary.errs++
+		return err
+	}
+}
+
+// treeWalk walks directory tree pushing treeWalkResult into the channel as and when it encounters files.
+// Directories are walked depth first from an explicit stack rather than by recursion, so that
+// arbitrarily deep trees need no deep go-routine stacks. The walk fails with WalkAborted carrying
+// ctx.Err() as soon as ctx is done, which is checked before listing every directory.
+func doTreeWalk(ctx context.Context, bucket, prefixDir, entryPrefixMatch, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, resultCh chan treeWalkResult, isEnd bool, opts *treeWalkOpts) error {
+	if opts.walkingDirs == nil {
+		opts.walkingDirs = make(map[string]struct{})
+	}
+	d, err := openTreeWalkDir(ctx, bucket, prefixDir, "", entryPrefixMatch, marker, listDir, isLeaf, resultCh, isEnd, opts)
+	if err != nil {
+		if isWalkAbort(err) {
+			return err
+		}
+		return opts.sendErr(ctx, bucket, err, resultCh)
+	}
+	d.depth = 1
+	opts.walkingDirs[d.dir] = struct{}{}
+	// Directories may be left on the stack by an error or abort.
+	defer func() { opts.walkingDirs = nil }()
+	stack := []*treeWalkDir{d}
+	for len(stack) > 0 {
+		d = stack[len(stack)-1]
+		if d.next == len(d.entries) {
+			// Everything in the directory is listed.
+			stack = stack[:len(stack)-1]
+			delete(opts.walkingDirs, d.dir)
+			if d.sendDirLast {
+				if err = opts.sendDir(ctx, bucket, d.prefixDir, d.dirIsEnd, resultCh); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		i, entry := d.next, d.entries[d.next]
+		d.next++
+		// Directories at maxDepth are sent rather than walked.
+		descend := recursive && (opts.maxDepth <= 0 || d.depth < opts.maxDepth)
 		// Decision to do isLeaf check was pushed from listDir() to here.
-		if delayIsLeaf && isLeaf(bucket, pathJoin(prefixDir, entry)) {
+		if d.delayIsLeaf && isLeaf(bucket, pathJoin(d.prefixDir, entry)) {
 			entry = strings.TrimSuffix(entry, slashSeparator)
 		}
 
-		if i == 0 && markerDir == entry {
-			if !recursive {
+		if i == 0 && d.markerDir == entry {
+			if !descend {
 				// Skip as the marker would already be listed in the previous listing.
 				continue
 			}
-			if recursive && !strings.HasSuffix(entry, slashSeparator) {
+			if descend && !strings.HasSuffix(entry, slashSeparator) {
 				// We should not skip for recursive listing and if markerDir is a directory
 				// for ex. if marker is "four/five.txt" markerDir will be "four/" which
 				// should not be skipped, instead it will need to be treeWalk()'ed into.
@@ -213,30 +1065,146 @@ func doTreeWalk(bucket, prefixDir, entryPrefixMatch, marker string, recursive bo
 				// Skip if it is a file though as it would be listed in previous listing.
 				continue
 			}
+			if opts.reverse && d.markerBase == "" {
+				// Everything under "four/" sorts after marker "four/" itself.
+				continue
+			}
+			if opts.skipMarkerDir {
+				// "four/" is taken to be listed completely already.
+				continue
+			}
 		}
-		if recursive && strings.HasSuffix(entry, slashSeparator) {
-			// If the entry is a directory, we will need recurse into it.
+		if opts.countOnly {
+			if !strings.HasSuffix(entry, slashSeparator) {
+				opts.objectCount++
+				continue
+			}
+			// Directories are only walked, never sent.
+			if !descend {
+				continue
+			}
+		}
+		if opts.skip(pathJoin(d.prefixDir, entry)) {
+			opts.stats.addFiltered()
+			continue
+		}
+		// Everything under the common prefix sent last, including whole
+		// directories, is already accounted for.
+		if opts.lastPrefix != "" && strings.HasPrefix(pathJoin(d.prefixDir, entry), opts.lastPrefix) {
+			continue
+		}
+		if strings.HasSuffix(entry, slashSeparator) {
+			if !opts.includeDir(pathJoin(d.prefixDir, entry)) {
+				opts.stats.addFiltered()
+				continue
+			}
+		} else if !opts.includeObject(pathJoin(d.prefixDir, entry)) {
+			opts.stats.addFiltered()
+			continue
+		}
+		if opts.filter != nil && !opts.filter(pathJoin(d.prefixDir, entry), strings.HasSuffix(entry, slashSeparator)) {
+			opts.stats.addFiltered()
+			continue
+		}
+		if descend && strings.HasSuffix(entry, slashSeparator) && opts.canRead != nil && !opts.canRead(pathJoin(d.prefixDir, entry)) {
+			if opts.emitDenied {
+				select {
+				case <-ctx.Done():
+					return opts.abort(ctx, bucket)
+				case resultCh <- treeWalkResult{entry: pathJoin(d.prefixDir, entry), denied: true}:
+				}
+			}
+			continue
+		}
+		if descend && strings.HasSuffix(entry, slashSeparator) {
+			// Directory matching the marker was sent by the previous listing.
+			if opts.emitDirs && !opts.reverse && entry != d.markerDir {
+				if err = opts.sendDir(ctx, bucket, pathJoin(d.prefixDir, entry), false, resultCh); err != nil {
+					return err
+				}
+			}
+			// If the entry is a directory, we will need to walk it next.
 			markerArg := ""
-			if entry == markerDir {
+			if entry == d.markerDir {
 				// We need to pass "five.txt" as marker only if we are
-				// recursing into "four/"
-				markerArg = markerBase
+				// walking "four/"
+				markerArg = d.markerBase
 			}
 			prefixMatch := "" // Valid only for first level treeWalk and empty for subdirectories.
-			// markIsEnd is passed to this entry's treeWalk() so that treeWalker.end can be marked
+			// markIsEnd is passed to this entry's walk so that treeWalker.end can be marked
 			// true at the end of the treeWalk stream.
-			markIsEnd := i == len(entries)-1 && isEnd
-			if tErr := doTreeWalk(bucket, pathJoin(prefixDir, entry), prefixMatch, markerArg, recursive, listDir, isLeaf, resultCh, endWalkCh, markIsEnd); tErr != nil {
-				return tErr
+			markIsEnd := i == len(d.entries)-1 && d.isEnd
+			// In reverse directories are sent after the entries beneath them,
+			// the directory is then the end instead.
+			sendDirLast := opts.emitDirs && opts.reverse
+			subDir, tErr := openTreeWalkDir(ctx, bucket, pathJoin(d.prefixDir, entry), d.dir, prefixMatch, markerArg, listDir, isLeaf, resultCh, markIsEnd && !sendDirLast, opts)
+			if tErr != nil {
+				if isWalkAbort(tErr) {
+					return tErr
+				}
+				if !opts.skipErrDirs {
+					return opts.sendErr(ctx, bucket, tErr, resultCh)
+				}
+				select {
+				case <-ctx.Done():
+					return opts.abort(ctx, bucket)
+				case resultCh <- treeWalkResult{entry: pathJoin(d.prefixDir, entry), err: tErr, skipped: true, end: markIsEnd && !sendDirLast}:
+					opts.summary.errs++
+					opts.endSent = opts.endSent || markIsEnd && !sendDirLast
+				}
+				if sendDirLast {
+					if err = opts.sendDir(ctx, bucket, pathJoin(d.prefixDir, entry), markIsEnd, resultCh); err != nil {
+						return err
+					}
+				}
+				continue
 			}
+			subDir.depth = d.depth + 1
+			subDir.sendDirLast = sendDirLast
+			subDir.dirIsEnd = markIsEnd
+			opts.walkingDirs[subDir.dir] = struct{}{}
+			stack = append(stack, subDir)
 			continue
 		}
 		// EOF is set if we are at last entry and the caller indicated we at the end.
-		isEOF := ((i == len(entries)-1) && isEnd)
+		isEOF := ((i == len(d.entries)-1) && d.isEnd)
+		key := pathJoin(d.prefixDir, entry)
+		isDir := strings.HasSuffix(key, slashSeparator)
+		if opts.groupByDelimiter() {
+			if commonPrefix := opts.commonPrefix(key); commonPrefix != "" {
+				key = commonPrefix
+				isDir = true
+				opts.lastPrefix = commonPrefix
+			}
+		}
+		info := isDirInfo
+		if opts.statEntry != nil && !isDir {
+			if info, err = opts.statEntry(bucket, key); err != nil {
+				if errorCause(err) == errFileNotFound {
+					// Removed since it was listed.
+					continue
+				}
+				return opts.sendErr(ctx, bucket, err, resultCh)
+			}
+		}
+		// Last object the walk sends before stopping at maxKeys.
+		truncated := !isDir && opts.keysSent+1 == opts.maxKeys && !isEOF
 		select {
-		case <-endWalkCh:
-			return traceError(errWalkAbort)
-		case resultCh <- treeWalkResult{entry: pathJoin(prefixDir, entry), end: isEOF}:
+		case <-ctx.Done():
+			return opts.abort(ctx, bucket)
+		case resultCh <- treeWalkResult{entry: key, end: isEOF, truncated: truncated, isDir: isDir, info: opts.entryInfo(info)}:
+			opts.summary.objects++
+			opts.endSent = opts.endSent || isEOF
+			if !isDir {
+				opts.stats.addLeaf()
+			}
+		}
+		if isDir {
+			continue
+		}
+		opts.keysSent++
+		if opts.keysSent == opts.maxKeys {
+			return errWalkMaxKeys
 		}
 	}
 
@@ -244,8 +1212,61 @@ func doTreeWalk(bucket, prefixDir, entryPrefixMatch, marker string, recursive bo
 	return nil
 }
 
-// Initiate a new treeWalk in a goroutine.
-func startTreeWalk(bucket, prefix, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, endWalkCh chan struct{}) chan treeWalkResult {
+// splitWalkPrefix - returns the directory to start walking prefix from and
+// the prefix its entries are to match, e.g. "one/two/" and "th" for
+// "one/two/th".
+func splitWalkPrefix(prefix string) (prefixDir, entryPrefixMatch string) {
+	if lastIndex := strings.LastIndex(prefix, slashSeparator); lastIndex != -1 {
+		return prefix[:lastIndex+1], prefix[lastIndex+1:]
+	}
+	return "", prefix
+}
+
+// resolveStartAfter - returns the marker to walk from given startAfter,
+// listNothing is set if every key beneath prefixDir sorts before it in walk
+// order. Keys are compared byte-wise as markers are.
+func resolveStartAfter(marker, startAfter, prefixDir string, reverse bool) (string, bool) {
+	if !strings.HasPrefix(startAfter, prefixDir) {
+		// Keys beneath prefixDir all sort either after or before startAfter.
+		return marker, (startAfter < prefixDir) == reverse
+	}
+	// The later of marker and startAfter in walk order wins.
+	if marker == "" || (marker < startAfter) != reverse {
+		return startAfter, false
+	}
+	return marker, false
+}
+
+// normalizeWalkPrefix - cleans the directories of prefix, e.g. "//a/./b"
+// becomes "a/b", leaving the entry name prefix after the last "/" as is.
+// Prefixes whose directories lead outside of the bucket are invalid.
+func normalizeWalkPrefix(bucket, prefix string) (string, error) {
+	prefixDir, entryPrefixMatch := splitWalkPrefix(prefix)
+	if prefixDir == "" {
+		return prefix, nil
+	}
+	cleanDir := path.Clean(strings.TrimLeft(prefixDir, slashSeparator))
+	switch {
+	case cleanDir == ".." || strings.HasPrefix(cleanDir, "../"):
+		return "", traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	case cleanDir == ".":
+		return entryPrefixMatch, nil
+	}
+	return cleanDir + slashSeparator + entryPrefixMatch, nil
+}
+
+// Initiate a new treeWalk in a goroutine. The walk ends once ctx is done
+// or endWalkCh is closed, whichever happens first. When ctx is done the
+// final result carries ctx.Err() before the result channel is closed,
+// closing endWalkCh means the consumer is gone and nothing more is sent.
+// endWalkCh may be nil for walks which are only cancelled through ctx, the
+// consumer then needs to read the results until resultCh is closed.
+func startTreeWalk(ctx context.Context, bucket, prefix, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, endWalkCh chan struct{}) chan treeWalkResult {
+	return startTreeWalkWithOpts(ctx, bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh, treeWalkOpts{})
+}
+
+// Initiate a new treeWalk in a goroutine with optional behavior set in opts.
+func startTreeWalkWithOpts(ctx context.Context, bucket, prefix, marker string, recursive bool, listDir listDirFunc, isLeaf isLeafFunc, endWalkCh chan struct{}, opts treeWalkOpts) chan treeWalkResult {
 	// Example 1
 	// If prefix is "one/two/three/" and marker is "one/two/three/four/five.txt"
 	// treeWalk is called with prefixDir="one/two/three/" and marker="four/five.txt"
@@ -256,19 +1277,105 @@ func startTreeWalk(bucket, prefix, marker string, recursive bool, listDir listDi
 	// treeWalk is called with prefixDir="one/two/" and marker="three/four/five.txt"
 	// and entryPrefixMatch="th"
 
-	resultCh := make(chan treeWalkResult, maxObjectList)
-	entryPrefixMatch := prefix
-	prefixDir := ""
-	lastIndex := strings.LastIndex(prefix, slashSeparator)
-	if lastIndex != -1 {
-		entryPrefixMatch = prefix[lastIndex+1:]
-		prefixDir = prefix[:lastIndex+1]
+	bufferSize := opts.bufferSize
+	if bufferSize <= 0 {
+		bufferSize = maxObjectList
+	}
+	resultCh := make(chan treeWalkResult, bufferSize)
+	prefix, prefixErr := normalizeWalkPrefix(bucket, prefix)
+	if prefixErr == nil {
+		marker, prefixErr = normalizeWalkPrefix(bucket, marker)
+	}
+	prefixDir, entryPrefixMatch := splitWalkPrefix(prefix)
+	opts.walkPrefix = prefix
+	opts.walkMarker = marker
+	listNothing := false
+	if opts.startAfter != "" {
+		marker, listNothing = resolveStartAfter(marker, opts.startAfter, prefixDir, opts.reverse)
+	}
+	if opts.groupByDelimiter() {
+		recursive = true
+		// Keys under a common prefix used as marker were listed with it.
+		if strings.HasSuffix(marker, opts.delimiter) && strings.HasPrefix(marker, prefix) {
+			opts.lastPrefix = marker
+		}
 	}
 	marker = strings.TrimPrefix(marker, prefixDir)
+	if opts.maxOpenDirs > 0 {
+		opts.openDirsCh = make(chan struct{}, opts.maxOpenDirs)
+	}
+	// Closing endWalkCh cancels walkCtx, which is all the walk looks at.
+	walkCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-endWalkCh:
+			cancel()
+		case <-walkCtx.Done():
+		}
+	}()
 	go func() {
-		isEnd := true // Indication to start walking the tree with end as true.
-		doTreeWalk(bucket, prefixDir, entryPrefixMatch, marker, recursive, listDir, isLeaf, resultCh, endWalkCh, isEnd)
+		defer cancel()
+		if opts.doneFn != nil {
+			defer opts.doneFn()
+		}
+		startTime := time.Now()
+		walkErr := prefixErr
+		if walkErr == nil && len(opts.bucketDisks) > 0 {
+			walkErr = statWalkBucket(bucket, opts.bucketDisks)
+		}
+		if walkErr != nil {
+			select {
+			case <-walkCtx.Done():
+			case resultCh <- treeWalkResult{err: walkErr, end: true}:
+			}
+			opts.stats.setDuration(time.Since(startTime))
+			close(resultCh)
+			return
+		}
+		var err error
+		if !listNothing {
+			isEnd := true // Indication to start walking the tree with end as true.
+			err = doTreeWalk(walkCtx, bucket, prefixDir, entryPrefixMatch, marker, recursive, listDir, isLeaf, resultCh, isEnd, &opts)
+		}
+		// A walk stopped at maxKeys has not listed everything, hence no end.
+		truncated := err == errWalkMaxKeys
+		if truncated {
+			err = nil
+		}
+		if err == nil && !truncated && opts.emitEnd && !opts.endSent {
+			select {
+			case <-walkCtx.Done():
+				err = opts.abort(walkCtx, bucket)
+			case resultCh <- treeWalkResult{end: true}:
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil && isWalkAbort(err) {
+			// Cancelled by the caller, tell the consumer why unless it has
+			// stopped reading results altogether. Waits for room in a full
+			// resultCh, otherwise the walk would look complete.
+			select {
+			case <-endWalkCh:
+			case resultCh <- treeWalkResult{err: traceError(ctxErr), end: true}:
+			}
+		} else if opts.emitSummary && walkCtx.Err() == nil {
+			opts.summary.duration = time.Since(startTime)
+			select {
+			case <-walkCtx.Done():
+			case resultCh <- treeWalkResult{summary: &opts.summary}:
+			}
+		}
+		opts.stats.setDuration(time.Since(startTime))
 		close(resultCh)
 	}()
 	return resultCh
 }
+
+// DrainTreeWalk - ends a walk its consumer stops reading early by closing
+// endWalkCh, which must not be closed already, and discards its results
+// until resultCh is closed, so that the walk go-routine is known to have
+// returned once DrainTreeWalk does.
+func DrainTreeWalk(resultCh chan treeWalkResult, endWalkCh chan struct{}) {
+	close(endWalkCh)
+	for range resultCh {
+	}
+}
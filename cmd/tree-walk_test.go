@@ -17,13 +17,22 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/net/context"
 )
 
 // Fixed volume name that could be used across tests
@@ -48,6 +57,15 @@ func TestDelayIsLeafCheck(t *testing.T) {
 			[]string{"a-b-c", "a-b/"},
 			false,
 		},
+		// Multi-byte UTF-8 names next to a "/" boundary.
+		{
+			[]string{"a\u00e9-b", "a\u00e9/"},
+			false,
+		},
+		{
+			[]string{"\u00e9 x/", "\u00e9/"},
+			false,
+		},
 
 		// Test cases where isLeaf check can be delayed.
 		{
@@ -62,6 +80,14 @@ func TestDelayIsLeafCheck(t *testing.T) {
 			[]string{"aaa", "bbb"},
 			true,
 		},
+		{
+			[]string{"a\u00e9/", "a\u00e9b"},
+			true,
+		},
+		{
+			[]string{"a\u00e9/", "a\u00ff/"},
+			true,
+		},
 	}
 	for i, testCase := range testCases {
 		expected := testCase.delay
@@ -72,6 +98,37 @@ func TestDelayIsLeafCheck(t *testing.T) {
 	}
 }
 
+// Test whenever isLeaf check is delayed for random UTF-8 names, removing
+// trailing "/" of objects does not change the order of the entries.
+func TestDelayIsLeafCheckUTF8(t *testing.T) {
+	chars := []string{"a", "z", "-", "!", ".", "\u00e9", "\u00ff", "\u0100", "\u4e16"}
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 10000; n++ {
+		var entries []string
+		for i := 0; i < 2+r.Intn(4); i++ {
+			var entry string
+			for j := 0; j < 1+r.Intn(3); j++ {
+				entry += chars[r.Intn(len(chars))]
+			}
+			if r.Intn(2) == 0 {
+				entry += slashSeparator
+			}
+			entries = append(entries, entry)
+		}
+		sort.Strings(entries)
+		if !delayIsLeafCheck(entries) {
+			continue
+		}
+		objects := make([]string, len(entries))
+		for i, entry := range entries {
+			objects[i] = strings.TrimSuffix(entry, slashSeparator)
+		}
+		if !sort.StringsAreSorted(objects) {
+			t.Fatalf("Expected isLeaf check not to be delayed for %q", entries)
+		}
+	}
+}
+
 // Test for filterMatchingPrefix.
 func TestFilterMatchingPrefix(t *testing.T) {
 	entries := []string{"a", "aab", "ab", "abbbb", "zzz"}
@@ -107,6 +164,114 @@ func TestFilterMatchingPrefix(t *testing.T) {
 	}
 }
 
+// filterMatchingPrefixLinear - filterMatchingPrefix() scanning the entries
+// from both ends, kept to benchmark against.
+func filterMatchingPrefixLinear(entries []string, prefixEntry string) []string {
+	start := 0
+	end := len(entries)
+	for start != end && !strings.HasPrefix(entries[start], prefixEntry) {
+		start++
+	}
+	for start != end && !strings.HasPrefix(entries[end-1], prefixEntry) {
+		end--
+	}
+	return entries[start:end]
+}
+
+// Test filterMatchingPrefix matches a brute-force filter for random sorted entries.
+func TestFilterMatchingPrefixRandom(t *testing.T) {
+	chars := []string{"a", "b", "c", "/", "-"}
+	randString := func(r *rand.Rand, maxLen int) string {
+		var s string
+		for i := r.Intn(maxLen + 1); i > 0; i-- {
+			s += chars[r.Intn(len(chars))]
+		}
+		return s
+	}
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 10000; n++ {
+		entries := make([]string, r.Intn(20))
+		for i := range entries {
+			entries[i] = randString(r, 4)
+		}
+		sort.Strings(entries)
+		prefixEntry := randString(r, 2)
+
+		var expected []string
+		for _, entry := range entries {
+			if strings.HasPrefix(entry, prefixEntry) {
+				expected = append(expected, entry)
+			}
+		}
+		got := filterMatchingPrefix(entries, prefixEntry)
+		if len(got) != len(expected) || (len(got) > 0 && !reflect.DeepEqual(got, expected)) {
+			t.Fatalf("Entries %q, prefix %q: Expected %q, got %q", entries, prefixEntry, expected, got)
+		}
+	}
+}
+
+// Returns 100k sorted entries, a thousand of them with prefix "x05".
+func filterMatchingPrefixEntries() []string {
+	entries := make([]string, 0, 100000)
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 1000; j++ {
+			entries = append(entries, fmt.Sprintf("x%02d-%04d", i, j))
+		}
+	}
+	return entries
+}
+
+func BenchmarkFilterMatchingPrefix(b *testing.B) {
+	entries := filterMatchingPrefixEntries()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filterMatchingPrefix(entries, "x05")
+	}
+}
+
+func BenchmarkFilterMatchingPrefixLinear(b *testing.B) {
+	entries := filterMatchingPrefixEntries()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filterMatchingPrefixLinear(entries, "x05")
+	}
+}
+
+// listDirDisk - StorageAPI serving ListDir() from a fixed set of
+// directory entries, all other operations are not implemented.
+type listDirDisk struct {
+	StorageAPI
+	dirs map[string][]string // Directory path => entries.
+}
+
+func (d *listDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	entries, ok := d.dirs[dirPath]
+	if !ok {
+		return nil, errFileNotFound
+	}
+	// Return a copy, callers are free to modify the entries like a real disk.
+	return append([]string(nil), entries...), nil
+}
+
+func (d *listDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
+// slowListDirDisk - StorageAPI whose ListDir() takes delay longer.
+type slowListDirDisk struct {
+	StorageAPI
+	delay time.Duration
+}
+
+func (d slowListDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	time.Sleep(d.delay)
+	return d.StorageAPI.ListDir(volume, dirPath)
+}
+
+func (d slowListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
 // Helper function that creates a volume and files in it.
 func createNamespace(disk StorageAPI, volume string, files []string) error {
 	// Make a volume.
@@ -131,7 +296,7 @@ func testTreeWalkPrefix(t *testing.T, listDir listDirFunc, isLeaf isLeafFunc) {
 	// Start the tree walk go-routine.
 	prefix := "d/"
 	endWalkCh := make(chan struct{})
-	twResultCh := startTreeWalk(volume, prefix, "", true, listDir, isLeaf, endWalkCh)
+	twResultCh := startTreeWalk(context.Background(), volume, prefix, "", true, listDir, isLeaf, endWalkCh)
 
 	// Check if all entries received on the channel match the prefix.
 	for res := range twResultCh {
@@ -146,7 +311,7 @@ func testTreeWalkMarker(t *testing.T, listDir listDirFunc, isLeaf isLeafFunc) {
 	// Start the tree walk go-routine.
 	prefix := ""
 	endWalkCh := make(chan struct{})
-	twResultCh := startTreeWalk(volume, prefix, "d/g", true, listDir, isLeaf, endWalkCh)
+	twResultCh := startTreeWalk(context.Background(), volume, prefix, "d/g", true, listDir, isLeaf, endWalkCh)
 
 	// Check if only 3 entries, namely d/g/h, i/j/k, lmn are received on the channel.
 	expectedCount := 3
@@ -228,7 +393,7 @@ func TestTreeWalkTimeout(t *testing.T) {
 	prefix := ""
 	marker := ""
 	recursive := true
-	resultCh := startTreeWalk(volume, prefix, marker, recursive, listDir, isLeaf, endWalkCh)
+	resultCh := startTreeWalk(context.Background(), volume, prefix, marker, recursive, listDir, isLeaf, endWalkCh)
 
 	params := listParams{
 		bucket:    volume,
@@ -288,10 +453,11 @@ func TestListDir(t *testing.T) {
 		t.Errorf("Unable to create StorageAPI: %s", err)
 	}
 
-	// create listDir function.
+	// create listDir function, disks are listed concurrently so disk2 is
+	// slowed down for disk1 to be listed first.
 	listDir := listDirFactory(func(volume, prefix string) bool {
 		return !strings.HasSuffix(prefix, slashSeparator)
-	}, disk1, disk2)
+	}, disk1, slowListDirDisk{disk2, 100 * time.Millisecond})
 
 	// Create file1 in fsDir1 and file2 in fsDir2.
 	disks := []StorageAPI{disk1, disk2}
@@ -342,6 +508,122 @@ func TestListDir(t *testing.T) {
 	}
 }
 
+// Test listDirFactory uses the first disk to list a directory, skipping
+// slow disks and disks with ignored errors.
+func TestListDirConcurrent(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	slowDisk := slowListDirDisk{&listDirDisk{dirs: map[string][]string{"": {"slow"}}}, time.Second}
+	fastDisk := &listDirDisk{dirs: map[string][]string{"": {"fast"}}}
+	faultyDisk := func() StorageAPI {
+		return &naughtyDisk{defaultErr: errFaultyDisk}
+	}
+	deniedDisk := &naughtyDisk{defaultErr: errVolumeAccessDenied}
+
+	testCases := []struct {
+		disks       []StorageAPI
+		entries     []string
+		expectedErr error
+		maxDuration time.Duration
+	}{
+		// Fast disk is used without waiting for the slow disk.
+		{[]StorageAPI{slowDisk, fastDisk}, []string{"fast"}, nil, 500 * time.Millisecond},
+		{[]StorageAPI{nil, slowDisk, fastDisk}, []string{"fast"}, nil, 500 * time.Millisecond},
+		// Disks with ignored errors are skipped.
+		{[]StorageAPI{faultyDisk(), slowDisk}, []string{"slow"}, nil, 2 * time.Second},
+		{[]StorageAPI{faultyDisk(), faultyDisk()}, nil, errFaultyDisk, 500 * time.Millisecond},
+		// More disks than are listed at once.
+		{[]StorageAPI{faultyDisk(), faultyDisk(), faultyDisk(), faultyDisk(), faultyDisk(), fastDisk}, []string{"fast"}, nil, 500 * time.Millisecond},
+		// Other errors fail the listing right away.
+		{[]StorageAPI{slowDisk, deniedDisk}, nil, errVolumeAccessDenied, 500 * time.Millisecond},
+	}
+	for i, testCase := range testCases {
+		listDir := listDirFactory(isLeaf, testCase.disks...)
+		startTime := time.Now()
+		entries, _, err := listDir(volume, "", "")
+		if duration := time.Since(startTime); duration > testCase.maxDuration {
+			t.Errorf("Test %d: Expected listing to take less than %s, took %s", i+1, testCase.maxDuration, duration)
+		}
+		if errorCause(err) != testCase.expectedErr {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if !reflect.DeepEqual(entries, testCase.entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.entries, entries)
+		}
+	}
+}
+
+// flakyListDirDisk - StorageAPI whose ListDir() fails with err the first
+// failures times it is called.
+type flakyListDirDisk struct {
+	StorageAPI
+	failures int
+	err      error
+	calls    int
+}
+
+func (d *flakyListDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	d.calls++
+	if d.calls <= d.failures {
+		return nil, d.err
+	}
+	return d.StorageAPI.ListDir(volume, dirPath)
+}
+
+func (d *flakyListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
+// Test listDirFactoryWithOpts retries transient errors before skipping a disk.
+func TestListDirRetry(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	newFlakyDisk := func(failures int, err error) *flakyListDirDisk {
+		return &flakyListDirDisk{
+			StorageAPI: &listDirDisk{dirs: map[string][]string{"": {"flaky"}}},
+			failures:   failures,
+			err:        err,
+		}
+	}
+	otherDisk := slowListDirDisk{&listDirDisk{dirs: map[string][]string{"": {"other"}}}, 100 * time.Millisecond}
+
+	testCases := []struct {
+		disk          *flakyListDirDisk
+		otherDisks    []StorageAPI
+		retries       int
+		entries       []string
+		expectedErr   error
+		expectedCalls int
+	}{
+		// Fails twice then succeeds.
+		{newFlakyDisk(2, errVolumeBusy), nil, 2, []string{"flaky"}, nil, 3},
+		{newFlakyDisk(2, io.ErrUnexpectedEOF), nil, 3, []string{"flaky"}, nil, 3},
+		// Retries are exhausted, the disk is skipped.
+		{newFlakyDisk(2, errVolumeBusy), nil, 1, nil, errVolumeBusy, 2},
+		{newFlakyDisk(2, errVolumeBusy), []StorageAPI{otherDisk}, 1, []string{"other"}, nil, 2},
+		// Without retries transient errors fail the listing as before.
+		{newFlakyDisk(2, errVolumeBusy), []StorageAPI{otherDisk}, 0, nil, errVolumeBusy, 1},
+		// Other errors are not retried.
+		{newFlakyDisk(2, errVolumeAccessDenied), nil, 2, nil, errVolumeAccessDenied, 1},
+	}
+	for i, testCase := range testCases {
+		disks := append([]StorageAPI{testCase.disk}, testCase.otherDisks...)
+		listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{retries: testCase.retries, baseDelay: time.Millisecond}, disks...)
+		entries, _, err := listDir(volume, "", "")
+		if errorCause(err) != testCase.expectedErr {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if !reflect.DeepEqual(entries, testCase.entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.entries, entries)
+		}
+		if testCase.disk.calls != testCase.expectedCalls {
+			t.Errorf("Test %d: Expected %d calls, got %d", i+1, testCase.expectedCalls, testCase.disk.calls)
+		}
+	}
+}
+
 // TestRecursiveWalk - tests if treeWalk returns entries correctly with and
 // without recursively traversing prefixes.
 func TestRecursiveTreeWalk(t *testing.T) {
@@ -437,7 +719,7 @@ func TestRecursiveTreeWalk(t *testing.T) {
 		}},
 	}
 	for i, testCase := range testCases {
-		for entry := range startTreeWalk(volume,
+		for entry := range startTreeWalk(context.Background(), volume,
 			testCase.prefix, testCase.marker, testCase.recursive,
 			listDir, isLeaf, endWalkCh) {
 			if _, found := testCase.expected[entry.entry]; !found {
@@ -509,7 +791,7 @@ func TestSortedness(t *testing.T) {
 	}
 	for i, test := range testCases {
 		var actualEntries []string
-		for entry := range startTreeWalk(volume,
+		for entry := range startTreeWalk(context.Background(), volume,
 			test.prefix, test.marker, test.recursive,
 			listDir, isLeaf, endWalkCh) {
 			actualEntries = append(actualEntries, entry.entry)
@@ -584,7 +866,7 @@ func TestTreeWalkIsEnd(t *testing.T) {
 	}
 	for i, test := range testCases {
 		var entry treeWalkResult
-		for entry = range startTreeWalk(volume, test.prefix, test.marker, test.recursive, listDir, isLeaf, endWalkCh) {
+		for entry = range startTreeWalk(context.Background(), volume, test.prefix, test.marker, test.recursive, listDir, isLeaf, endWalkCh) {
 		}
 		if entry.entry != test.expectedEntry {
 			t.Errorf("Test %d: Expected entry %s, but received %s with the EOF marker", i, test.expectedEntry, entry.entry)
@@ -600,3 +882,2030 @@ func TestTreeWalkIsEnd(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// Test if heartbeats are sent while listDir() is blocked on a slow disk.
+func TestTreeWalkHeartbeat(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	// Slow listDir which takes a while before returning a single entry.
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		time.Sleep(200 * time.Millisecond)
+		return []string{"file"}, true, nil
+	}
+
+	endWalkCh := make(chan struct{})
+	opts := treeWalkOpts{heartbeatInterval: 20 * time.Millisecond}
+	var heartbeats int
+	var entries []string
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+		if res.heartbeat {
+			heartbeats++
+			continue
+		}
+		entries = append(entries, res.entry)
+	}
+	if heartbeats == 0 {
+		t.Error("Expected heartbeats while listDir was blocked, but received none")
+	}
+	if !reflect.DeepEqual(entries, []string{"file"}) {
+		t.Errorf("Expected entries %v, got %v", []string{"file"}, entries)
+	}
+
+	// No heartbeats should be sent by default.
+	for res := range startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh) {
+		if res.heartbeat {
+			t.Fatal("Expected no heartbeats when heartbeatInterval is not set")
+		}
+	}
+}
+
+// Test a subtree skipped mid-walk is pruned from the walk.
+func TestTreeWalkSkip(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":       {"a/", "e"},
+		"a/":     {"0", "b/"},
+		"a/b/":   {"c", "d/"},
+		"a/b/d/": {"f"},
+	}
+	// listDir of "a/b/" blocks until the consumer has seen "a/0".
+	releaseCh := make(chan struct{})
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		if prefixDir == "a/b/" {
+			<-releaseCh
+		}
+		return dirs[prefixDir], true, nil
+	}
+
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	skipCh := make(chan string, 1)
+	opts := treeWalkOpts{skipCh: skipCh}
+	var entries []string
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		entries = append(entries, res.entry)
+		if res.entry == "a/0" {
+			skipCh <- "a/b/"
+			close(releaseCh)
+		}
+	}
+	expected := []string{"a/0", "e"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected entries %v, got %v", expected, entries)
+	}
+}
+
+// Test the number of directories open at once never exceeds maxOpenDirs.
+func TestTreeWalkMaxOpenDirs(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":     {"a/", "b/", "c"},
+		"a/":   {"d/", "e"},
+		"a/d/": {"f"},
+		"b/":   {"g"},
+	}
+	var mu sync.Mutex
+	var open, maxOpen int
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		mu.Lock()
+		open++
+		if open > maxOpen {
+			maxOpen = open
+		}
+		mu.Unlock()
+		// Keep the directory open for a while, longer than heartbeats.
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		open--
+		mu.Unlock()
+		return dirs[prefixDir], true, nil
+	}
+
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	opts := treeWalkOpts{maxOpenDirs: 1, heartbeatInterval: 5 * time.Millisecond}
+	var entries []string
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+		if res.heartbeat {
+			continue
+		}
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		entries = append(entries, res.entry)
+	}
+	expected := []string{"a/d/f", "a/e", "b/g", "c"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected entries %v, got %v", expected, entries)
+	}
+	if maxOpen > opts.maxOpenDirs {
+		t.Errorf("Expected at most %d open directories, got %d", opts.maxOpenDirs, maxOpen)
+	}
+}
+
+// Test the summary sent at the end of the walk matches the walk.
+func TestTreeWalkSummary(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":     {"a/", "b/", "c"},
+		"a/":   {"d/", "e"},
+		"a/d/": {"f"},
+		"b/":   {"g", "h/"},
+		// "b/h/" is missing, its listing fails.
+	}
+	var listed int64
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		entries, ok := dirs[prefixDir]
+		if !ok {
+			return nil, false, traceError(errFileNotFound)
+		}
+		listed++
+		return entries, true, nil
+	}
+
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	opts := treeWalkOpts{emitSummary: true}
+	var objects, errs int64
+	var summary *treeWalkSummary
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+		if summary != nil {
+			t.Fatal("Expected the summary to be the final result")
+		}
+		switch {
+		case res.summary != nil:
+			summary = res.summary
+		case res.err != nil:
+			errs++
+		default:
+			objects++
+		}
+	}
+	if summary == nil {
+		t.Fatal("Expected a summary at the end of the walk")
+	}
+	if summary.objects != objects || summary.errs != errs || summary.dirs != listed {
+		t.Errorf("Expected %d objects, %d errors and %d directories, got %+v", objects, errs, listed, *summary)
+	}
+	if summary.duration <= 0 {
+		t.Errorf("Expected a positive walk duration, got %s", summary.duration)
+	}
+
+	// No summary is sent by default.
+	for res := range startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh) {
+		if res.summary != nil {
+			t.Fatal("Expected no summary when emitSummary is not set")
+		}
+	}
+}
+
+// Test walks verifying the bucket tell empty and missing buckets apart.
+func TestTreeWalkBucketCheck(t *testing.T) {
+	fsDir, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatalf("Unable to create tmp directory: %s", err)
+	}
+	defer removeAll(fsDir)
+	disk, err := newStorageAPI(fsDir)
+	if err != nil {
+		t.Fatalf("Unable to create StorageAPI: %s", err)
+	}
+	if err = disk.MakeVol("empty"); err != nil {
+		t.Fatal(err)
+	}
+	if err = createNamespace(disk, volume, []string{"a", "b/c"}); err != nil {
+		t.Fatal(err)
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+
+	testCases := []struct {
+		bucket      string
+		prefix      string
+		expected    []string
+		expectedErr error
+	}{
+		{volume, "", []string{"a", "b/c"}, nil},
+		{"empty", "", nil, nil},
+		{"empty", "b/", nil, errFileNotFound},
+		{"missing", "", nil, BucketNotFound{Bucket: "missing"}},
+		{"missing", "b/", nil, BucketNotFound{Bucket: "missing"}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		opts := treeWalkOpts{bucketDisks: []StorageAPI{disk}}
+		var entries []string
+		var err error
+		for res := range startTreeWalkWithOpts(context.Background(), testCase.bucket, testCase.prefix, "", true, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				err = res.err
+				break
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if errorCause(err) != testCase.expectedErr {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
+
+// Test directories denied by canRead are skipped.
+func TestTreeWalkCanRead(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":         {"a/", "private/", "z"},
+		"a/":       {"b", "private/"},
+		"private/": {"d"},
+	}
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		if strings.HasSuffix(prefixDir, "private/") {
+			return nil, false, traceError(errDiskAccessDenied)
+		}
+		return dirs[prefixDir], true, nil
+	}
+	canRead := func(prefixDir string) bool {
+		return !strings.HasSuffix(prefixDir, "private/")
+	}
+
+	testCases := []struct {
+		emitDenied     bool
+		expected       []string
+		expectedDenied []string
+	}{
+		{false, []string{"a/b", "z"}, nil},
+		{true, []string{"a/b", "z"}, []string{"a/private/", "private/"}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		opts := treeWalkOpts{canRead: canRead, emitDenied: testCase.emitDenied}
+		var entries, denied []string
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+			switch {
+			case res.err != nil:
+				t.Fatalf("Test %d: Unexpected error %s", i+1, res.err)
+			case res.denied:
+				denied = append(denied, res.entry)
+			default:
+				entries = append(entries, res.entry)
+			}
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(testCase.expected, entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if !reflect.DeepEqual(testCase.expectedDenied, denied) {
+			t.Errorf("Test %d: Expected denied %v, got %v", i+1, testCase.expectedDenied, denied)
+		}
+	}
+}
+
+// Test each duplicate policy against an object colliding with a prefix.
+func TestTreeWalkDuplicatePolicy(t *testing.T) {
+	// "a/" is an object which also holds object "a/x".
+	dirs := map[string][]string{
+		"":   {"a-b", "a/", "c/"},
+		"a/": {"x"},
+		"c/": {"d"},
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return prefix == "a/" || !strings.HasSuffix(prefix, slashSeparator)
+	}
+	isPrefix := func(volume, prefix string) bool {
+		return len(dirs[prefix]) > 0
+	}
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		return filterMatchingPrefix(dirs[prefixDir], prefixEntry), true, nil
+	}
+
+	testCases := []struct {
+		policy      DuplicatePolicy
+		recursive   bool
+		expected    []string
+		expectedErr error
+	}{
+		{DuplicatePreferObject, true, []string{"a", "a-b", "c/d"}, nil},
+		{DuplicatePreferPrefix, true, []string{"a-b", "a/x", "c/d"}, nil},
+		{DuplicateEmitBoth, true, []string{"a", "a-b", "a/x", "c/d"}, nil},
+		{DuplicateEmitBoth, false, []string{"a", "a-b", "a/", "c/"}, nil},
+		{DuplicateError, true, nil, errObjectPrefixCollision},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		opts := treeWalkOpts{isPrefix: isPrefix, duplicatePolicy: testCase.policy}
+		var entries []string
+		var err error
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				err = res.err
+				break
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if errorCause(err) != testCase.expectedErr {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.expectedErr, err)
+		}
+		if !reflect.DeepEqual(testCase.expected, entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
+
+// Test cancelling the context stops the walk at every level of the recursion.
+func TestTreeWalkContextCancel(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":       {"a/", "z"},
+		"a/":     {"0", "b/"},
+		"a/b/":   {"1", "c/"},
+		"a/b/c/": {"2"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var listed []string
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		mu.Lock()
+		listed = append(listed, prefixDir)
+		mu.Unlock()
+		// Cancel while the walk is two levels deep.
+		if prefixDir == "a/b/" {
+			cancel()
+		}
+		return dirs[prefixDir], true, nil
+	}
+
+	var results []treeWalkResult
+	for res := range startTreeWalk(ctx, volume, "", "", true, listDir, isLeaf, nil) {
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		t.Fatal("Expected results before the walk was cancelled")
+	}
+	last := results[len(results)-1]
+	if errorCause(last.err) != context.Canceled {
+		t.Errorf("Expected the final result to carry %s, got %v", context.Canceled, last.err)
+	}
+	for _, res := range results[:len(results)-1] {
+		if res.err != nil || res.entry == "z" {
+			t.Errorf("Unexpected result %#v after cancelling", res)
+		}
+	}
+	// The walk must not descend any further once cancelled.
+	expected := []string{"", "a/", "a/b/"}
+	if !reflect.DeepEqual(listed, expected) {
+		t.Errorf("Expected directories %v to be listed, got %v", expected, listed)
+	}
+
+	// Closing endWalkCh still ends the walk, without sending an error.
+	endWalkCh := make(chan struct{})
+	close(endWalkCh)
+	for res := range startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh) {
+		if res.err != nil {
+			t.Errorf("Expected no error once endWalkCh is closed, got %v", res.err)
+		}
+	}
+}
+
+// Test cancelling the context while the result channel is full still
+// ends the walk with the context's error.
+func TestTreeWalkCancelBufferFull(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"": {"a", "b", "c", "d", "e", "f", "g", "h"},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	resultCh := startTreeWalkWithOpts(ctx, volume, "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{bufferSize: 5})
+	// Wait for the walk to block on the full channel.
+	for len(resultCh) < cap(resultCh) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	// Give the walk time to end before reading anything.
+	time.Sleep(50 * time.Millisecond)
+	var results []treeWalkResult
+	for res := range resultCh {
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		t.Fatal("Expected results before the walk was cancelled")
+	}
+	if last := results[len(results)-1]; errorCause(last.err) != context.Canceled || !last.end {
+		t.Errorf("Expected the final result to carry %s, got %#v", context.Canceled, last)
+	}
+}
+
+// Test keys with "/" in their names are grouped by other delimiters.
+func TestTreeWalkDelimiter(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":       {"a/", "f:g", "f:h/", "j", "k|l"},
+		"a/":     {"b:c", "b:d/", "x"},
+		"a/b:d/": {"e"},
+		"f:h/":   {"i"},
+	}
+	var mu sync.Mutex
+	var listed []string
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		mu.Lock()
+		listed = append(listed, prefixDir)
+		mu.Unlock()
+		return filterMatchingPrefix(dirs[prefixDir], prefixEntry), true, nil
+	}
+
+	testCases := []struct {
+		prefix    string
+		marker    string
+		delimiter string
+		recursive bool
+		expected  []string
+	}{
+		// Delimiter other than "/" groups across directories.
+		{"", "", ":", false, []string{"a/b:", "a/x", "f:", "j", "k|l"}},
+		{"", "", "|", false, []string{"a/b:c", "a/b:d/e", "a/x", "f:g", "f:h/i", "j", "k|"}},
+		{"a/", "", ":", false, []string{"a/b:", "a/x"}},
+		{"f", "", ":", false, []string{"f:"}},
+		// Keys under a common prefix marker are not listed again.
+		{"", "a/b:", ":", false, []string{"a/x", "f:", "j", "k|l"}},
+		{"", "f:", ":", false, []string{"j", "k|l"}},
+		// Empty and "/" delimiters walk as before.
+		{"", "", "", true, []string{"a/b:c", "a/b:d/e", "a/x", "f:g", "f:h/i", "j", "k|l"}},
+		{"", "", slashSeparator, false, []string{"a/", "f:g", "f:h/", "j", "k|l"}},
+	}
+	for i, testCase := range testCases {
+		listed = nil
+		endWalkCh := make(chan struct{})
+		opts := treeWalkOpts{delimiter: testCase.delimiter}
+		var entries []string
+		for res := range startTreeWalkWithOpts(context.Background(), volume, testCase.prefix, testCase.marker, testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: Unexpected error %s", i+1, res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		// Directories under a common prefix already sent are not walked.
+		for _, dir := range listed {
+			if testCase.delimiter == ":" && (dir == "a/b:d/" || dir == "f:h/") {
+				t.Errorf("Test %d: Expected %q not to be listed", i+1, dir)
+			}
+		}
+	}
+}
+
+// Test directories are sent with isDir set.
+func TestTreeWalkIsDir(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":     {"a/", "b:c", "d"},
+		"a/":   {"b/", "e"},
+		"a/b/": {"f"},
+	}
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		return filterMatchingPrefix(dirs[prefixDir], prefixEntry), true, nil
+	}
+
+	testCases := []struct {
+		marker    string
+		recursive bool
+		opts      treeWalkOpts
+		expected  []string // Directories are listed with a "+" prefix.
+	}{
+		// Non-recursive walks always flag directories.
+		{"", false, treeWalkOpts{}, []string{"+a/", "b:c", "d"}},
+		// Recursive walks send directories only if asked to.
+		{"", true, treeWalkOpts{}, []string{"a/b/f", "a/e", "b:c", "d"}},
+		{"", true, treeWalkOpts{emitDirs: true}, []string{"+a/", "+a/b/", "a/b/f", "a/e", "b:c", "d"}},
+		// Directories already sent are not sent again when resuming.
+		{"a/", true, treeWalkOpts{emitDirs: true}, []string{"+a/b/", "a/b/f", "a/e", "b:c", "d"}},
+		{"a/b/", true, treeWalkOpts{emitDirs: true}, []string{"a/b/f", "a/e", "b:c", "d"}},
+		// Common prefixes are directories too.
+		{"", true, treeWalkOpts{delimiter: ":"}, []string{"a/b/f", "a/e", "+b:", "d"}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", testCase.marker, testCase.recursive, listDir, isLeaf, endWalkCh, testCase.opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: Unexpected error %s", i+1, res.err)
+			}
+			if res.isDir {
+				res.entry = "+" + res.entry
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
+
+// Test the walk ends once maxKeys entries were sent without listing any
+// further directories.
+func TestTreeWalkMaxKeys(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":     {"a/", "b/", "c"},
+		"a/":   {"1", "2"},
+		"b/":   {"3", "d/"},
+		"b/d/": {"4"},
+	}
+	var mu sync.Mutex
+	var listed []string
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		mu.Lock()
+		listed = append(listed, prefixDir)
+		mu.Unlock()
+		return dirs[prefixDir], true, nil
+	}
+
+	testCases := []struct {
+		maxKeys   int
+		recursive bool
+		emitDirs  bool
+		entries   []string
+		listed    []string
+		truncated bool
+	}{
+		// maxKeys falls exactly on a directory boundary.
+		{2, true, false, []string{"a/1", "a/2"}, []string{"", "a/"}, true},
+		{3, true, false, []string{"a/1", "a/2", "b/3"}, []string{"", "a/", "b/"}, true},
+		{4, true, false, []string{"a/1", "a/2", "b/3", "b/d/4"}, []string{"", "a/", "b/", "b/d/"}, true},
+		// maxKeys matches or exceeds the number of entries.
+		{5, true, false, []string{"a/1", "a/2", "b/3", "b/d/4", "c"}, []string{"", "a/", "b/", "b/d/"}, false},
+		{6, true, false, []string{"a/1", "a/2", "b/3", "b/d/4", "c"}, []string{"", "a/", "b/", "b/d/"}, false},
+		// Zero means no limit.
+		{0, true, false, []string{"a/1", "a/2", "b/3", "b/d/4", "c"}, []string{"", "a/", "b/", "b/d/"}, false},
+		// Directories sent do not count.
+		{1, false, false, []string{"a/", "b/", "c"}, []string{""}, false},
+		// maxKeys falls exactly on a directory boundary with directories sent,
+		// the next directory is neither sent nor listed.
+		{2, true, true, []string{"a/", "a/1", "a/2"}, []string{"", "a/"}, true},
+		{3, true, true, []string{"a/", "a/1", "a/2", "b/", "b/3"}, []string{"", "a/", "b/"}, true},
+		{4, true, true, []string{"a/", "a/1", "a/2", "b/", "b/3", "b/d/", "b/d/4"}, []string{"", "a/", "b/", "b/d/"}, true},
+		{5, true, true, []string{"a/", "a/1", "a/2", "b/", "b/3", "b/d/", "b/d/4", "c"}, []string{"", "a/", "b/", "b/d/"}, false},
+	}
+	for i, testCase := range testCases {
+		listed = nil
+		endWalkCh := make(chan struct{})
+		opts := treeWalkOpts{maxKeys: testCase.maxKeys, emitDirs: testCase.emitDirs}
+		var entries []string
+		var last treeWalkResult
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: Unexpected error %s", i+1, res.err)
+			}
+			if last.end || last.truncated {
+				t.Errorf("Test %d: Unexpected result %#v after the last one", i+1, res)
+			}
+			entries = append(entries, res.entry)
+			last = res
+		}
+		close(endWalkCh)
+		// A truncated walk is not mistaken for a complete one.
+		if last.truncated != testCase.truncated || last.end == testCase.truncated {
+			t.Errorf("Test %d: Expected the last entry truncated %v, got truncated %v and end %v", i+1, testCase.truncated, last.truncated, last.end)
+		}
+		if !reflect.DeepEqual(entries, testCase.entries) {
+			t.Errorf("Test %d: Expected entries %v, got %v", i+1, testCase.entries, entries)
+		}
+		if !reflect.DeepEqual(listed, testCase.listed) {
+			t.Errorf("Test %d: Expected directories %v to be listed, got %v", i+1, testCase.listed, listed)
+		}
+	}
+
+	// Walks stopped at maxKeys do not send an end with emitEnd set.
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{maxKeys: 2, emitEnd: true}) {
+		if res.end {
+			t.Errorf("Unexpected end %#v of a truncated walk", res)
+		}
+	}
+}
+
+// Test aborted walks fail with the bucket, prefix and marker of the walk.
+func TestTreeWalkAborted(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"a/":   {"b/"},
+		"a/b/": {"c", "d"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	listDir := func(volume, prefixDir, prefixEntry string) ([]string, bool, error) {
+		if prefixDir == "a/b/" {
+			cancel()
+		}
+		return dirs[prefixDir], true, nil
+	}
+	opts := treeWalkOpts{walkPrefix: "a/", walkMarker: "a/0"}
+	// Unbuffered and never read from, so that the walk can only be aborted.
+	resultCh := make(chan treeWalkResult)
+	err := doTreeWalk(ctx, volume, "a/", "", "0", true, listDir, isLeaf, resultCh, true, &opts)
+	if !isWalkAbort(err) {
+		t.Fatalf("Expected %s, got %v", errWalkAbort, err)
+	}
+	aborted, ok := errorCause(err).(WalkAborted)
+	if !ok {
+		t.Fatalf("Expected WalkAborted, got %T", errorCause(err))
+	}
+	expected := WalkAborted{Bucket: volume, Prefix: "a/", Marker: "a/0", Reason: context.Canceled}
+	if aborted != expected {
+		t.Errorf("Expected %#v, got %#v", expected, aborted)
+	}
+	if !strings.Contains(aborted.Error(), "prefix a/") {
+		t.Errorf("Expected the error to name the prefix, got %q", aborted.Error())
+	}
+
+	// Plain errWalkAbort is still recognized.
+	if !isWalkAbort(traceError(errWalkAbort)) || isWalkAbort(traceError(errFileNotFound)) {
+		t.Error("Expected only aborts to be recognized")
+	}
+}
+
+// Test reverse walks send the entries of forward walks in descending order,
+// resuming from markers with the entries sorting before them.
+func TestTreeWalkReverse(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":     {"a-b", "a/", "a0", "b/", "c"},
+		"a/":   {"x", "y/", "z"},
+		"a/y/": {"1", "2"},
+		"b/":   {"k"},
+	}}
+	listDir := listDirFactory(isLeaf, disk)
+	walk := func(marker string, recursive bool, opts treeWalkOpts) (entries []string) {
+		endWalkCh := make(chan struct{})
+		defer close(endWalkCh)
+		var end bool
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", marker, recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			if end {
+				t.Errorf("Marker %q: Unexpected result %#v after the end", marker, res)
+			}
+			end = res.end
+			entries = append(entries, res.entry)
+		}
+		if len(entries) > 0 && !end {
+			t.Errorf("Marker %q: Expected the last entry to be marked as the end", marker)
+		}
+		return entries
+	}
+
+	testCases := []struct {
+		recursive bool
+		emitDirs  bool
+	}{
+		{true, false},
+		{false, false},
+		{true, true},
+	}
+	for i, testCase := range testCases {
+		forward := walk("", testCase.recursive, treeWalkOpts{emitDirs: testCase.emitDirs})
+		if !sort.StringsAreSorted(forward) {
+			t.Fatalf("Test %d: Expected a sorted forward walk, got %v", i+1, forward)
+		}
+		reverse := walk("", testCase.recursive, treeWalkOpts{emitDirs: testCase.emitDirs, reverse: true})
+		if expected := reverseEntries(forward); !reflect.DeepEqual(reverse, expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, expected, reverse)
+		}
+		// Every entry of the walk used as marker, as well as keys which do
+		// not exist for recursive walks.
+		markers := append([]string(nil), forward...)
+		if testCase.recursive {
+			markers = append(markers, "0", "a", "a/y/15", "a/yy", "bb", "d")
+		}
+		for _, marker := range markers {
+			var expected []string
+			for _, entry := range forward {
+				if entry < marker {
+					expected = append(expected, entry)
+				}
+			}
+			expected = reverseEntries(expected)
+			reverse = walk(marker, testCase.recursive, treeWalkOpts{emitDirs: testCase.emitDirs, reverse: true})
+			if len(expected) == 0 && len(reverse) == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(reverse, expected) {
+				t.Errorf("Test %d: Marker %q: Expected %v, got %v", i+1, marker, expected, reverse)
+			}
+		}
+	}
+}
+
+// Test walks fail instead of never ending if a directory lists an ancestor.
+func TestTreeWalkDirCycle(t *testing.T) {
+	dirs := map[string][]string{
+		"":     {"a/"},
+		"a/":   {"b/", "x"},
+		"a/b/": {"../", "y"}, // Points back to "a/".
+	}
+	listDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		entries, ok := dirs[prefixDir]
+		if !ok {
+			return nil, false, errFileNotFound
+		}
+		return filterMatchingPrefix(entries, prefixEntry), false, nil
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	resultCh := startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh)
+	var walkErr error
+	timeout := time.After(5 * time.Second)
+	for walkErr == nil {
+		select {
+		case res, ok := <-resultCh:
+			if !ok {
+				t.Fatal("Expected the walk to fail with a cycle error")
+			}
+			walkErr = res.err
+		case <-timeout:
+			t.Fatal("Walk of a directory cycle did not end")
+		}
+	}
+	if errorCause(walkErr) != errDirCycle {
+		t.Fatalf("Expected %v, got %v", errDirCycle, walkErr)
+	}
+	if _, ok := <-resultCh; ok {
+		t.Fatal("Expected no results after the cycle error")
+	}
+}
+
+// hungListDirDisk - StorageAPI whose ListDir() blocks until unblockCh is closed.
+type hungListDirDisk struct {
+	StorageAPI
+	unblockCh chan struct{}
+}
+
+func (d hungListDirDisk) ListDir(volume, dirPath string) ([]string, error) {
+	<-d.unblockCh
+	return nil, errFaultyDisk
+}
+
+func (d hungListDirDisk) ListDirBatch(volume string, dirPaths []string) ([][]string, []error) {
+	return listDirEach(d, volume, dirPaths)
+}
+
+// Test walks resolving directories detect a child directory which is its
+// ancestor under a new name, e.g. a symlink to it.
+func TestTreeWalkRealDir(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	dirs := map[string][]string{
+		"":     {"a/", "c/"},
+		"a/":   {"b/", "x"},
+		"a/b/": {"same/", "up/", "y"}, // "same/" is "c/", "up/" is "a/".
+		"c/":   {"z"},
+	}
+	links := map[string]string{"a/b/same/": "c/", "a/b/up/": "a/"}
+	// resolve - returns the directory prefixDir is under its own name.
+	resolve := func(prefixDir string) string {
+		for resolved := false; !resolved; {
+			resolved = true
+			for link, target := range links {
+				if strings.HasPrefix(prefixDir, link) {
+					prefixDir = target + strings.TrimPrefix(prefixDir, link)
+					resolved = false
+				}
+			}
+		}
+		return prefixDir
+	}
+	listDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+		entries, ok := dirs[resolve(prefixDir)]
+		if !ok {
+			return nil, false, traceError(errFileNotFound)
+		}
+		return filterMatchingPrefix(entries, prefixEntry), false, nil
+	}
+	realDir := func(bucket, prefixDir, parentDir string) (string, error) {
+		return resolve(prefixDir), nil
+	}
+	testCases := []struct {
+		prefix   string
+		expected []string // "!" stands for errDirCycle.
+	}{
+		{"", []string{"a/b/same/z", "!"}},
+		{"a/b/", []string{"a/b/same/z", "!"}},
+		{"a/b/same/", []string{"a/b/same/z"}},
+		{"c/", []string{"c/z"}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		opts := treeWalkOpts{realDir: realDir}
+		for res := range startTreeWalkWithOpts(context.Background(), volume, testCase.prefix, "", true, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				if errorCause(res.err) != errDirCycle {
+					t.Fatalf("Test %d: %v", i+1, res.err)
+				}
+				entries = append(entries, "!")
+				break
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
+
+// Test walks of a disk with a symlink to an ancestor directory end.
+func TestTreeWalkSymlinkCycle(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need privileges on windows")
+	}
+	diskPath, err := ioutil.TempDir("", "minio-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeAll(diskPath)
+	disk, err := newPosix(diskPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = disk.MakeVol(volume); err != nil {
+		t.Fatal(err)
+	}
+	if err = disk.AppendFile(volume, "a/b/y", []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	// "a/b/loop" is "a" under a new name.
+	if err = os.Symlink(filepath.Join(diskPath, volume, "a"), filepath.Join(diskPath, volume, "a", "b", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, disk)
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	opts := treeWalkOpts{realDir: realDirFunc(disk)}
+	var walkErr error
+	timeout := time.After(5 * time.Second)
+	for resultCh := startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts); walkErr == nil; {
+		select {
+		case res, ok := <-resultCh:
+			if !ok {
+				t.Fatal("Expected the walk to fail with a cycle error")
+			}
+			walkErr = res.err
+		case <-timeout:
+			t.Fatal("Walk of a symlink cycle did not end")
+		}
+	}
+	if errorCause(walkErr) != errDirCycle {
+		t.Fatalf("Expected %v, got %v", errDirCycle, walkErr)
+	}
+}
+
+// Test hung disks are skipped once they time out.
+func TestListDirTimeout(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	unblockCh := make(chan struct{})
+	defer close(unblockCh)
+	hungDisk := hungListDirDisk{unblockCh: unblockCh}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"": {"a", "b"},
+	}}
+
+	testCases := []struct {
+		disks    []StorageAPI
+		expected []string
+		err      error
+	}{
+		// Hung disks take every listing slot, the last disk is only
+		// listed once they timed out.
+		{[]StorageAPI{hungDisk, hungDisk, hungDisk, hungDisk, disk}, []string{"a", "b"}, nil},
+		{[]StorageAPI{hungDisk, hungDisk}, nil, errListDirTimeout},
+	}
+	for i, testCase := range testCases {
+		stats := &treeWalkStats{}
+		listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{timeout: 50 * time.Millisecond, stats: stats}, testCase.disks...)
+		doneCh := make(chan struct{})
+		var entries []string
+		var err error
+		go func() {
+			entries, _, err = listDir(volume, "", "")
+			close(doneCh)
+		}()
+		select {
+		case <-doneCh:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Test %d: Listing did not time out", i+1)
+		}
+		if errorCause(err) != testCase.err {
+			t.Errorf("Test %d: Expected error %v, got %v", i+1, testCase.err, err)
+		}
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		// Every disk timed out if none of them could be listed.
+		if testCase.err != nil && stats.IgnoredErrs() != int64(len(testCase.disks)) {
+			t.Errorf("Test %d: Expected %d timed out disks, got %d", i+1, len(testCase.disks), stats.IgnoredErrs())
+		}
+	}
+}
+
+// Test disks are waited for unless a timeout is set, however many there are.
+func TestListDirNoTimeout(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	unblockCh := make(chan struct{})
+	hungDisk := hungListDirDisk{unblockCh: unblockCh}
+	listDir := listDirFactory(isLeaf, hungDisk, hungDisk)
+	doneCh := make(chan struct{})
+	var err error
+	go func() {
+		_, _, err = listDir(volume, "", "")
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+		t.Fatal("Expected the listing to wait for the disks")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(unblockCh)
+	<-doneCh
+	if errorCause(err) != errFaultyDisk {
+		t.Errorf("Expected error %v, got %v", errFaultyDisk, err)
+	}
+}
+
+// Test the result channel capacity of walks.
+func TestTreeWalkBufferSize(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"": {"a", "b"},
+	}})
+	testCases := []struct {
+		bufferSize int
+		expected   int
+	}{
+		{0, maxObjectList},
+		{-1, maxObjectList},
+		{1, 1},
+		{5000, 5000},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		resultCh := startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{bufferSize: testCase.bufferSize})
+		if cap(resultCh) != testCase.expected {
+			t.Errorf("Test %d: Expected capacity %d, got %d", i+1, testCase.expected, cap(resultCh))
+		}
+		close(endWalkCh)
+	}
+}
+
+// Test case-insensitive walks resume from every marker.
+func TestTreeWalkFoldCase(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":     {"Apple", "Cherry", "Dir/", "apple", "banana", "dir/"},
+		"Dir/": {"a"},
+		"dir/": {"X", "y"},
+	}}
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{foldCase: true}, disk)
+	walk := func(prefix, marker string) (entries []string) {
+		endWalkCh := make(chan struct{})
+		defer close(endWalkCh)
+		for res := range startTreeWalkWithOpts(context.Background(), volume, prefix, marker, true, listDir, isLeaf, endWalkCh, treeWalkOpts{foldCase: true}) {
+			if res.err != nil {
+				if errorCause(res.err) == errFileNotFound {
+					break
+				}
+				t.Fatal(res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		return entries
+	}
+
+	expected := []string{"Apple", "apple", "banana", "Cherry", "Dir/a", "dir/X", "dir/y"}
+	if entries := walk("", ""); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Expected %v, got %v", expected, entries)
+	}
+	for i, marker := range expected {
+		entries := walk("", marker)
+		if len(entries) == 0 && i == len(expected)-1 {
+			continue
+		}
+		if !reflect.DeepEqual(entries, expected[i+1:]) {
+			t.Errorf("Marker %q: Expected %v, got %v", marker, expected[i+1:], entries)
+		}
+	}
+
+	testCases := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"Ap", []string{"Apple"}},
+		{"ap", []string{"apple"}},
+		{"D", []string{"Dir/a"}},
+		{"dir/", []string{"dir/X", "dir/y"}},
+	}
+	for i, testCase := range testCases {
+		if entries := walk(testCase.prefix, ""); !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
+
+// Test onIgnoredErr is called with the disk whose error was ignored.
+func TestListDirOnIgnoredErr(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"a/": {"x"},
+	}}
+	faultyDisk := newNaughtyDisk(nil, nil, errFaultyDisk)
+
+	type ignoredErr struct {
+		disk      StorageAPI
+		bucket    string
+		prefixDir string
+		err       error
+	}
+	var ignoredErrs []ignoredErr
+	onIgnoredErr := func(disk StorageAPI, bucket, prefixDir string, err error) {
+		ignoredErrs = append(ignoredErrs, ignoredErr{disk, bucket, prefixDir, err})
+	}
+	// The faulty disk fails before the other disk replies.
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{onIgnoredErr: onIgnoredErr}, faultyDisk, slowListDirDisk{disk, 50 * time.Millisecond})
+	entries, _, err := listDir(volume, "a/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, []string{"x"}) {
+		t.Errorf("Expected [x], got %v", entries)
+	}
+	expected := []ignoredErr{{faultyDisk, volume, "a/", errFaultyDisk}}
+	if !reflect.DeepEqual(ignoredErrs, expected) {
+		t.Errorf("Expected %v, got %v", expected, ignoredErrs)
+	}
+
+	// Without a hook ignored errors are only skipped.
+	listDir = listDirFactory(isLeaf, faultyDisk, disk)
+	if _, _, err = listDir(volume, "a/", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns the objects beneath prefixDir of dirs in the order of a
+// recursive walk, the reference walks are compared to.
+func recursiveWalkEntries(dirs map[string][]string, prefixDir string) (objects []string) {
+	entries := append([]string(nil), dirs[prefixDir]...)
+	sort.Strings(entries)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, slashSeparator) {
+			objects = append(objects, recursiveWalkEntries(dirs, prefixDir+entry)...)
+			continue
+		}
+		objects = append(objects, prefixDir+entry)
+	}
+	return objects
+}
+
+// Test walks of deep and random trees match recursive walks from every marker.
+func TestTreeWalkDeep(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	walk := func(dirs map[string][]string, marker string) (entries []string) {
+		listDir := listDirFactory(isLeaf, &listDirDisk{dirs: dirs})
+		endWalkCh := make(chan struct{})
+		defer close(endWalkCh)
+		var end bool
+		for res := range startTreeWalk(context.Background(), volume, "", marker, true, listDir, isLeaf, endWalkCh) {
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			if end {
+				t.Fatalf("Marker %q: Unexpected result %#v after the end", marker, res)
+			}
+			end = res.end
+			entries = append(entries, res.entry)
+		}
+		return entries
+	}
+
+	// Random shallow trees.
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		dirs := map[string][]string{}
+		var addDir func(prefixDir string, depth int)
+		addDir = func(prefixDir string, depth int) {
+			var entries []string
+			for j := rng.Intn(4) + 1; j > 0; j-- {
+				name := string([]byte{"ab-"[rng.Intn(3)], "ab-"[rng.Intn(3)]})
+				if depth < 4 && rng.Intn(2) == 0 {
+					name += slashSeparator
+					if _, ok := dirs[prefixDir+name]; !ok {
+						addDir(prefixDir+name, depth+1)
+					}
+				}
+				entries = append(entries, name)
+			}
+			dirs[prefixDir] = entries
+		}
+		addDir("", 0)
+		// Names may repeat, as objects and as directories.
+		for prefixDir, entries := range dirs {
+			seen := map[string]bool{}
+			var unique []string
+			for _, entry := range entries {
+				if !seen[strings.TrimSuffix(entry, slashSeparator)] {
+					seen[strings.TrimSuffix(entry, slashSeparator)] = true
+					unique = append(unique, entry)
+				}
+			}
+			dirs[prefixDir] = unique
+		}
+		expected := recursiveWalkEntries(dirs, "")
+		for j := -1; j < len(expected); j++ {
+			marker := ""
+			if j >= 0 {
+				marker = expected[j]
+			}
+			entries := walk(dirs, marker)
+			if len(entries) == 0 && j == len(expected)-1 {
+				continue
+			}
+			if !reflect.DeepEqual(entries, expected[j+1:]) {
+				t.Fatalf("Tree %d %v: Marker %q: Expected %v, got %v", i+1, dirs, marker, expected[j+1:], entries)
+			}
+		}
+	}
+
+	// 5000 levels deep tree with an object on every level.
+	dirs := map[string][]string{}
+	prefixDir := ""
+	for i := 0; i < 5000; i++ {
+		dirs[prefixDir] = []string{"d/", "o"}
+		prefixDir += "d/"
+	}
+	dirs[prefixDir] = []string{"o"}
+	expected := recursiveWalkEntries(dirs, "")
+	if entries := walk(dirs, ""); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(entries))
+	}
+	marker := expected[2500]
+	if entries := walk(dirs, marker); !reflect.DeepEqual(entries, expected[2501:]) {
+		t.Fatalf("Marker at level %d: Expected %d entries, got %d", strings.Count(marker, slashSeparator), len(expected)-2501, len(entries))
+	}
+}
+
+// Returns the number of go-routines of tree walks, waiting up to a second
+// for it to drop to expected.
+func treeWalkGoroutines(expected int) (count int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		buf := make([]byte, 2<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		count = 0
+		for _, stack := range strings.Split(string(buf), "\n\n") {
+			if strings.Contains(stack, "cmd.startTreeWalkWithOpts") {
+				count++
+			}
+		}
+		if count <= expected || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Test draining walks ended early leaves no go-routine behind.
+func TestDrainTreeWalk(t *testing.T) {
+	before := treeWalkGoroutines(0)
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":   {"a", "b/", "c"},
+		"b/": {"x", "y", "z"},
+	}})
+	for _, read := range []int{0, 1, 3} {
+		endWalkCh := make(chan struct{})
+		opts := treeWalkOpts{bufferSize: 1, heartbeatInterval: time.Millisecond}
+		resultCh := startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts)
+		for i := 0; i < read; i++ {
+			if res := <-resultCh; res.err != nil {
+				t.Fatal(res.err)
+			}
+		}
+		DrainTreeWalk(resultCh, endWalkCh)
+		if _, ok := <-resultCh; ok {
+			t.Fatalf("Read %d: Expected the result channel to be closed", read)
+		}
+		if count := treeWalkGoroutines(before); count > before {
+			t.Fatalf("Read %d: Expected at most %d walk go-routines, got %d", read, before, count)
+		}
+	}
+}
+
+// Test listing directories fails with the first error of the disks.
+func TestListDirFirstErr(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	errMeaningful := errors.New("meaningful error")
+	testCases := []struct {
+		disks []StorageAPI
+		err   error
+	}{
+		{[]StorageAPI{newNaughtyDisk(nil, nil, errMeaningful), nil}, errMeaningful},
+		{[]StorageAPI{nil, newNaughtyDisk(nil, nil, errMeaningful)}, errMeaningful},
+		// Errors which are ignored, the first one is returned.
+		{[]StorageAPI{newNaughtyDisk(nil, nil, errFaultyDisk), slowListDirDisk{newNaughtyDisk(nil, nil, errDiskNotFound), 50 * time.Millisecond}}, errFaultyDisk},
+		{[]StorageAPI{slowListDirDisk{newNaughtyDisk(nil, nil, errDiskNotFound), 50 * time.Millisecond}, newNaughtyDisk(nil, nil, errFaultyDisk)}, errFaultyDisk},
+		// Not a single disk.
+		{[]StorageAPI{nil, nil}, errDiskNotFound},
+	}
+	for i, testCase := range testCases {
+		listDir := listDirFactory(isLeaf, testCase.disks...)
+		if _, _, err := listDir(volume, "", ""); errorCause(err) != testCase.err {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.err, err)
+		}
+	}
+}
+
+// lessNatural - orders names with numbers in them numerically, e.g.
+// "img2" before "img10", and byte-wise otherwise.
+func lessNatural(a, b string) bool {
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	// Returns the leading run of digits or non-digits of s.
+	run := func(s string) string {
+		i := 1
+		for i < len(s) && isDigit(s[i]) == isDigit(s[0]) {
+			i++
+		}
+		return s[:i]
+	}
+	for x, y := a, b; x != "" && y != ""; {
+		runX, runY := run(x), run(y)
+		if isDigit(runX[0]) && isDigit(runY[0]) {
+			numX, numY := strings.TrimLeft(runX, "0"), strings.TrimLeft(runY, "0")
+			if len(numX) != len(numY) {
+				return len(numX) < len(numY)
+			}
+			if numX != numY {
+				return numX < numY
+			}
+		} else if runX != runY {
+			return runX < runY
+		}
+		x, y = x[len(runX):], y[len(runY):]
+	}
+	return a < b
+}
+
+// Test walks ordered by a custom less function resume from every marker.
+func TestTreeWalkLess(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk := &listDirDisk{dirs: map[string][]string{
+		"":       {"img1", "img10", "img2", "img20/", "img3"},
+		"img20/": {"a10", "a9"},
+	}}
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{less: lessNatural}, disk)
+	walk := func(prefix, marker string, reverse bool) (entries []string) {
+		endWalkCh := make(chan struct{})
+		defer close(endWalkCh)
+		opts := treeWalkOpts{less: lessNatural, reverse: reverse}
+		for res := range startTreeWalkWithOpts(context.Background(), volume, prefix, marker, true, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		return entries
+	}
+
+	expected := []string{"img1", "img2", "img3", "img10", "img20/a9", "img20/a10"}
+	if entries := walk("", "", false); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Expected %v, got %v", expected, entries)
+	}
+	if entries := walk("", "", true); !reflect.DeepEqual(entries, reverseEntries(expected)) {
+		t.Fatalf("Expected %v, got %v", reverseEntries(expected), entries)
+	}
+	for i, marker := range expected {
+		if entries := walk("", marker, false); len(entries) > 0 || i < len(expected)-1 {
+			if !reflect.DeepEqual(entries, expected[i+1:]) {
+				t.Errorf("Marker %q: Expected %v, got %v", marker, expected[i+1:], entries)
+			}
+		}
+		if entries := walk("", marker, true); len(entries) > 0 || i > 0 {
+			if !reflect.DeepEqual(entries, reverseEntries(expected[:i])) {
+				t.Errorf("Marker %q: Expected %v in reverse, got %v", marker, reverseEntries(expected[:i]), entries)
+			}
+		}
+	}
+	// Entries with the prefix are not contiguous in natural order.
+	if entries := walk("img1", "", false); !reflect.DeepEqual(entries, []string{"img1", "img10"}) {
+		t.Errorf("Expected [img1 img10], got %v", entries)
+	}
+}
+
+// Test walks go on past directories failing to list if asked to.
+func TestTreeWalkSkipErrDirs(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	diskListDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":     {"a/", "b/", "c", "d/"},
+		"a/":   {"x"},
+		"b/":   {"y"},
+		"d/":   {"e/"},
+		"d/e/": {"z"},
+	}})
+	errFaultyListDir := errors.New("faulty listDir")
+	testCases := []struct {
+		failDirs    []string
+		skipErrDirs bool
+		expected    []string // Failed directories are prefixed with "!".
+		end         string
+	}{
+		{[]string{"b/"}, true, []string{"a/x", "!b/", "c", "d/e/z"}, "d/e/z"},
+		// Errors failing the walk carry no entry.
+		{[]string{"b/"}, false, []string{"a/x", "!"}, ""},
+		// Last directory of the walk fails, its result is the end.
+		{[]string{"b/", "d/e/"}, true, []string{"a/x", "!b/", "c", "!d/e/"}, "d/e/"},
+		// The prefix itself failing to list always fails the walk.
+		{[]string{""}, true, []string{"!"}, ""},
+	}
+	for i, testCase := range testCases {
+		failDirs := testCase.failDirs
+		listDir := func(bucket, prefixDir, prefixEntry string) ([]string, bool, error) {
+			for _, failDir := range failDirs {
+				if prefixDir == failDir {
+					return nil, false, traceError(errFaultyListDir)
+				}
+			}
+			return diskListDir(bucket, prefixDir, prefixEntry)
+		}
+		endWalkCh := make(chan struct{})
+		var entries []string
+		var end string
+		opts := treeWalkOpts{skipErrDirs: testCase.skipErrDirs}
+		for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, opts) {
+			if res.end {
+				end = res.entry
+			}
+			if res.err == nil {
+				entries = append(entries, res.entry)
+				continue
+			}
+			if errorCause(res.err) != errFaultyListDir {
+				t.Fatalf("Test %d: Unexpected error %v", i+1, res.err)
+			}
+			entries = append(entries, "!"+res.entry)
+			if !res.skipped {
+				break
+			}
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if end != testCase.end {
+			t.Errorf("Test %d: Expected end at %q, got %q", i+1, testCase.end, end)
+		}
+	}
+}
+
+// Test recursive walks limited to a number of directory levels.
+func TestTreeWalkMaxDepth(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":       {"a/", "x"},
+		"a/":     {"b/", "y"},
+		"a/b/":   {"c/", "z"},
+		"a/b/c/": {"w"},
+	}})
+	testCases := []struct {
+		prefix   string
+		marker   string
+		maxDepth int
+		expected []string // Directories are sent with isDir set.
+	}{
+		{"", "", 0, []string{"a/b/c/w", "a/b/z", "a/y", "x"}},
+		{"", "", 1, []string{"a/", "x"}},
+		{"", "", 2, []string{"a/b/", "a/y", "x"}},
+		{"", "", 3, []string{"a/b/c/", "a/b/z", "a/y", "x"}},
+		{"", "", 4, []string{"a/b/c/w", "a/b/z", "a/y", "x"}},
+		// Depth counts from the prefix.
+		{"a/", "", 1, []string{"a/b/", "a/y"}},
+		{"a/", "", 2, []string{"a/b/c/", "a/b/z", "a/y"}},
+		// Markers at and beyond the last level.
+		{"", "a/b/", 2, []string{"a/y", "x"}},
+		{"", "a/b/z", 2, []string{"a/y", "x"}},
+		{"", "a/b/c/", 3, []string{"a/b/z", "a/y", "x"}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		var end bool
+		opts := treeWalkOpts{maxDepth: testCase.maxDepth}
+		for res := range startTreeWalkWithOpts(context.Background(), volume, testCase.prefix, testCase.marker, true, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			if res.isDir != strings.HasSuffix(res.entry, slashSeparator) {
+				t.Errorf("Test %d: Unexpected isDir %v for %q", i+1, res.isDir, res.entry)
+			}
+			end = res.end
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if !end {
+			t.Errorf("Test %d: Expected the last entry to be the end", i+1)
+		}
+	}
+}
+
+// Test walks with startAfter against walks with the same key as marker.
+func TestTreeWalkStartAfter(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "b/", "x"},
+		"a/": {"k", "m"},
+		"b/": {"k", "m"},
+	}})
+	walk := func(prefix, marker string, recursive bool, opts treeWalkOpts) (entries []string) {
+		endWalkCh := make(chan struct{})
+		defer close(endWalkCh)
+		for res := range startTreeWalkWithOpts(context.Background(), volume, prefix, marker, recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		return entries
+	}
+	testCases := []struct {
+		prefix     string
+		key        string
+		recursive  bool
+		reverse    bool
+		withMarker []string
+		expected   []string // With key as startAfter.
+	}{
+		// Existing keys.
+		{"", "a/k", true, false, []string{"a/m", "b/k", "b/m", "x"}, []string{"a/m", "b/k", "b/m", "x"}},
+		{"", "a/k", false, false, []string{"b/", "x"}, []string{"b/", "x"}},
+		{"", "a/", false, false, []string{"b/", "x"}, []string{"b/", "x"}},
+		{"", "x", true, false, nil, nil},
+		{"b/", "b/k", true, false, []string{"b/m"}, []string{"b/m"}},
+		{"", "b/m", true, true, []string{"b/k", "a/m", "a/k"}, []string{"b/k", "a/m", "a/k"}},
+		// Missing keys.
+		{"", "a/l", true, false, []string{"a/m", "b/k", "b/m", "x"}, []string{"a/m", "b/k", "b/m", "x"}},
+		{"", "a/z", false, false, []string{"b/", "x"}, []string{"b/", "x"}},
+		{"", "w", true, false, []string{"x"}, []string{"x"}},
+		// Keys outside of the prefix are only meaningful as startAfter.
+		{"b/", "a/z", true, false, []string{"b/k", "b/m"}, []string{"b/k", "b/m"}},
+		{"b/", "c", true, false, []string{"b/k", "b/m"}, nil},
+		{"b/", "a", true, true, nil, nil},
+		{"b/", "c", true, true, nil, []string{"b/m", "b/k"}},
+	}
+	for i, testCase := range testCases {
+		opts := treeWalkOpts{reverse: testCase.reverse}
+		if entries := walk(testCase.prefix, testCase.key, testCase.recursive, opts); !reflect.DeepEqual(entries, testCase.withMarker) {
+			t.Errorf("Test %d: Expected %v with marker, got %v", i+1, testCase.withMarker, entries)
+		}
+		opts.startAfter = testCase.key
+		if entries := walk(testCase.prefix, "", testCase.recursive, opts); !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v with startAfter, got %v", i+1, testCase.expected, entries)
+		}
+	}
+
+	// The later of marker and startAfter wins.
+	expected := []string{"b/m", "x"}
+	if entries := walk("", "a/k", true, treeWalkOpts{startAfter: "b/k"}); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+	if entries := walk("", "b/k", true, treeWalkOpts{startAfter: "a/k"}); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+// Test that walks with emitEnd end with exactly one result with end set.
+func TestTreeWalkEmitEnd(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "b", "c/"},
+		"a/": {"x", "y"},
+		"c/": {},
+	}})
+	testCases := []struct {
+		prefix    string
+		marker    string
+		recursive bool
+		opts      treeWalkOpts
+		expected  []string // "" is the end sentinel.
+	}{
+		// The last entry is the end.
+		{"a/", "", true, treeWalkOpts{}, []string{"a/x", "a/y"}},
+		{"", "", false, treeWalkOpts{}, []string{"a/", "b", "c/"}},
+		{"", "", true, treeWalkOpts{maxKeys: 4}, []string{"a/x", "a/y", "b", ""}},
+		// Marker sorting after every entry.
+		{"", "d", true, treeWalkOpts{}, []string{""}},
+		{"a/", "a/z", true, treeWalkOpts{}, []string{""}},
+		{"", "c/", false, treeWalkOpts{}, []string{""}},
+		// Empty last directory.
+		{"", "", true, treeWalkOpts{}, []string{"a/x", "a/y", "b", ""}},
+		// Last entry filtered out.
+		{"a/", "", true, treeWalkOpts{exclude: []string{"a/y"}}, []string{"a/x", ""}},
+		// Nothing after startAfter.
+		{"a/", "", true, treeWalkOpts{startAfter: "b"}, []string{""}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		ends := 0
+		testCase.opts.emitEnd = true
+		for res := range startTreeWalkWithOpts(context.Background(), volume, testCase.prefix, testCase.marker, testCase.recursive, listDir, isLeaf, endWalkCh, testCase.opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			if ends > 0 {
+				t.Errorf("Test %d: Unexpected result %q after the end", i+1, res.entry)
+			}
+			if res.end {
+				ends++
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %q, got %q", i+1, testCase.expected, entries)
+		}
+		if ends != 1 {
+			t.Errorf("Test %d: Expected exactly one end, got %d", i+1, ends)
+		}
+	}
+
+	// Without emitEnd nothing is sent past the last entry.
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	for res := range startTreeWalk(context.Background(), volume, "", "d", true, listDir, isLeaf, endWalkCh) {
+		t.Errorf("Unexpected result %+v", res)
+	}
+}
+
+// Test that walk prefixes are normalized before walking.
+func TestTreeWalkNormalizePrefix(t *testing.T) {
+	testCases := []struct {
+		prefix     string
+		normalized string
+		shouldPass bool
+	}{
+		{"", "", true},
+		{"a", "a", true},
+		{"a/", "a/", true},
+		{"/", "", true},
+		{"//a/b", "a/b", true},
+		{"a//b", "a/b", true},
+		{"a/../b", "b", true},
+		{"a/./b/", "a/b/", true},
+		{"./a", "a", true},
+		// Entry name prefixes are not cleaned.
+		{"a/.", "a/.", true},
+		{"a/..", "a/..", true},
+		// Directories outside of the bucket.
+		{"../a", "", false},
+		{"a/../../b", "", false},
+		{"/../", "", false},
+	}
+	for i, testCase := range testCases {
+		normalized, err := normalizeWalkPrefix(volume, testCase.prefix)
+		if testCase.shouldPass && err != nil {
+			t.Errorf("Test %d: Unexpected error %v", i+1, err)
+		}
+		if !testCase.shouldPass {
+			if _, ok := errorCause(err).(ObjectNameInvalid); !ok {
+				t.Errorf("Test %d: Expected ObjectNameInvalid, got %v", i+1, err)
+			}
+		}
+		if normalized != testCase.normalized {
+			t.Errorf("Test %d: Expected %q, got %q", i+1, testCase.normalized, normalized)
+		}
+	}
+
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "b/"},
+		"a/": {"bar", "baz", "foo"},
+		"b/": {"bar", "foo"},
+	}})
+	walkTestCases := []struct {
+		prefix   string
+		marker   string
+		expected []string // "!" stands for an error.
+	}{
+		{"//a/b", "", []string{"a/bar", "a/baz"}},
+		{"a//b", "a//bar", []string{"a/baz"}},
+		{"a/../b", "", []string{"b/bar", "b/foo"}},
+		{"a/../b/f", "", []string{"b/foo"}},
+		{"a/../b/", "", []string{"b/bar", "b/foo"}},
+		{"../a", "", []string{"!"}},
+	}
+	for i, testCase := range walkTestCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		for res := range startTreeWalk(context.Background(), volume, testCase.prefix, testCase.marker, true, listDir, isLeaf, endWalkCh) {
+			if res.err != nil {
+				if !res.end {
+					t.Errorf("Test %d: Expected the error to end the walk", i+1)
+				}
+				entries = append(entries, "!")
+				continue
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+}
+
+// Test walks with a filter deciding which entries are sent and walked.
+func TestTreeWalkFilter(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":     {"a/", "b/", "x.tmp", "y"},
+		"a/":   {"c/", "z", "z.tmp"},
+		"a/c/": {"w"},
+		"b/":   {"v"},
+	}})
+	pruneDir := func(dir string) func(string, bool) bool {
+		return func(path string, isDir bool) bool {
+			return !isDir || path != dir
+		}
+	}
+	dropTmp := func(path string, isDir bool) bool {
+		return isDir || !strings.HasSuffix(path, ".tmp")
+	}
+	testCases := []struct {
+		prefix    string
+		recursive bool
+		filter    func(path string, isDir bool) bool
+		expected  []string
+		filtered  int64
+	}{
+		// Whole subtrees pruned.
+		{"", true, pruneDir("a/"), []string{"b/v", "x.tmp", "y"}, 1},
+		{"", true, pruneDir("a/c/"), []string{"a/z", "a/z.tmp", "b/v", "x.tmp", "y"}, 1},
+		{"", false, pruneDir("b/"), []string{"a/", "x.tmp", "y"}, 1},
+		// Individual objects dropped.
+		{"", true, dropTmp, []string{"a/c/w", "a/z", "b/v", "y"}, 2},
+		{"a/", false, dropTmp, []string{"a/c/", "a/z"}, 1},
+		// Everything dropped.
+		{"", true, func(string, bool) bool { return false }, nil, 4},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		var entries []string
+		opts := treeWalkOpts{filter: testCase.filter, stats: &treeWalkStats{}}
+		for res := range startTreeWalkWithOpts(context.Background(), volume, testCase.prefix, "", testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			entries = append(entries, res.entry)
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+		if filtered := opts.stats.Filtered(); filtered != testCase.filtered {
+			t.Errorf("Test %d: Expected %d entries filtered, got %d", i+1, testCase.filtered, filtered)
+		}
+	}
+}
+
+// Test listing the union of the entries of all disks.
+func TestListDirUnion(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	disk1 := &listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "c", "e"},
+		"a/": {"x"},
+	}}
+	disk2 := &listDirDisk{dirs: map[string][]string{
+		"":   {"b", "d/"},
+		"d/": {"y"},
+	}}
+	disk3 := &listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "b", "f"},
+		"a/": {"z"},
+	}}
+	errMeaningful := errors.New("meaningful error")
+	testCases := []struct {
+		disks     []StorageAPI
+		prefixDir string
+		expected  []string
+		err       error
+	}{
+		// Disjoint entries.
+		{[]StorageAPI{disk1, disk2}, "", []string{"a/", "b", "c", "d/", "e"}, nil},
+		{[]StorageAPI{disk2, disk1}, "", []string{"a/", "b", "c", "d/", "e"}, nil},
+		// Overlapping entries are listed once.
+		{[]StorageAPI{disk1, disk2, disk3}, "", []string{"a/", "b", "c", "d/", "e", "f"}, nil},
+		{[]StorageAPI{disk1, disk3}, "a/", []string{"x", "z"}, nil},
+		// Disks without the directory are left out.
+		{[]StorageAPI{disk1, disk2, nil}, "d/", []string{"y"}, nil},
+		{[]StorageAPI{newNaughtyDisk(nil, nil, errFaultyDisk), disk1}, "a/", []string{"x"}, nil},
+		// Errors which are not ignored fail the listing.
+		{[]StorageAPI{disk1, newNaughtyDisk(nil, nil, errMeaningful)}, "", nil, errMeaningful},
+		{[]StorageAPI{disk1, disk2}, "g/", nil, errFileNotFound},
+	}
+	for i, testCase := range testCases {
+		listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{union: true}, testCase.disks...)
+		entries, _, err := listDir(volume, testCase.prefixDir, "")
+		if errorCause(err) != testCase.err {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.err, err)
+		}
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+
+	// Walks list every object.
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{union: true}, disk1, disk2, disk3)
+	expected := []string{"a/x", "a/z", "b", "c", "d/y", "e", "f"}
+	endWalkCh := make(chan struct{})
+	defer close(endWalkCh)
+	var entries []string
+	for res := range startTreeWalk(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh) {
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		entries = append(entries, res.entry)
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+// Test walks sending entries along with the info returned by statEntry.
+func TestTreeWalkStatEntry(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":   {"a/", "b", "c"},
+		"a/": {"x", "y"},
+	}})
+	modTime := time.Now().UTC()
+	errMeaningful := errors.New("meaningful error")
+	// Sizes of the objects, "c" was removed since it was listed.
+	sizes := map[string]int64{"a/x": 1, "a/y": 2, "b": 3}
+	statEntry := func(bucket, entry string) (treeWalkEntryInfo, error) {
+		size, ok := sizes[entry]
+		if !ok {
+			return treeWalkEntryInfo{}, traceError(errFileNotFound)
+		}
+		if size < 0 {
+			return treeWalkEntryInfo{}, traceError(errMeaningful)
+		}
+		return treeWalkEntryInfo{size: size, modTime: modTime}, nil
+	}
+	testCases := []struct {
+		prefix    string
+		recursive bool
+		expected  map[string]treeWalkEntryInfo
+	}{
+		{"", true, map[string]treeWalkEntryInfo{
+			"a/x": {size: 1, modTime: modTime},
+			"a/y": {size: 2, modTime: modTime},
+			"b":   {size: 3, modTime: modTime},
+		}},
+		{"", false, map[string]treeWalkEntryInfo{
+			"a/": {isDir: true},
+			"b":  {size: 3, modTime: modTime},
+		}},
+	}
+	for i, testCase := range testCases {
+		endWalkCh := make(chan struct{})
+		entries := make(map[string]treeWalkEntryInfo)
+		opts := treeWalkOpts{statEntry: statEntry}
+		for res := range startTreeWalkWithOpts(context.Background(), volume, testCase.prefix, "", testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+			if res.err != nil {
+				t.Fatalf("Test %d: %v", i+1, res.err)
+			}
+			if res.info == nil {
+				t.Fatalf("Test %d: Expected info for %q", i+1, res.entry)
+			}
+			entries[res.entry] = *res.info
+		}
+		close(endWalkCh)
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+
+	// Without statEntry no info is sent.
+	endWalkCh := make(chan struct{})
+	for res := range startTreeWalk(context.Background(), volume, "", "", false, listDir, isLeaf, endWalkCh) {
+		if res.info != nil {
+			t.Errorf("Unexpected info %v for %q", *res.info, res.entry)
+		}
+	}
+	close(endWalkCh)
+
+	// Any other error fails the walk.
+	sizes["a/y"] = -1
+	endWalkCh = make(chan struct{})
+	defer close(endWalkCh)
+	var entries []string
+	var err error
+	for res := range startTreeWalkWithOpts(context.Background(), volume, "", "", true, listDir, isLeaf, endWalkCh, treeWalkOpts{statEntry: statEntry}) {
+		if res.err != nil {
+			err = res.err
+			continue
+		}
+		entries = append(entries, res.entry)
+	}
+	if errorCause(err) != errMeaningful {
+		t.Errorf("Expected %v, got %v", errMeaningful, err)
+	}
+	if expected := []string{"a/x"}; !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+// Test listDir with errors ignored in addition to the default ones and
+// with none ignored at all.
+func TestListDirIgnoredErrs(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	errBackend := errors.New("backend specific error")
+	// The disk with the entries replies last.
+	slowDisk := slowListDirDisk{&listDirDisk{dirs: map[string][]string{"": {"a", "b"}}}, 50 * time.Millisecond}
+	testCases := []struct {
+		diskErr  error
+		opts     listDirOpts
+		expected []string
+		err      error
+	}{
+		// Not ignored by default.
+		{errBackend, listDirOpts{}, nil, errBackend},
+		{errBackend, listDirOpts{ignoredErrs: []error{errBackend}}, []string{"a", "b"}, nil},
+		{errBackend, listDirOpts{ignoredErrs: []error{errVolumeBusy}}, nil, errBackend},
+		// Default errors stay ignored.
+		{errFaultyDisk, listDirOpts{ignoredErrs: []error{errBackend}}, []string{"a", "b"}, nil},
+		// Nothing ignored in strict mode.
+		{errFaultyDisk, listDirOpts{strict: true}, nil, errFaultyDisk},
+		{errBackend, listDirOpts{strict: true, ignoredErrs: []error{errBackend}}, nil, errBackend},
+		{errVolumeBusy, listDirOpts{strict: true, retries: 1}, nil, errVolumeBusy},
+	}
+	for i, testCase := range testCases {
+		listDir := listDirFactoryWithOpts(isLeaf, testCase.opts, newNaughtyDisk(nil, nil, testCase.diskErr), slowDisk)
+		entries, _, err := listDir(volume, "", "")
+		if errorCause(err) != testCase.err {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.err, err)
+		}
+		if !reflect.DeepEqual(entries, testCase.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, testCase.expected, entries)
+		}
+	}
+
+	// In strict mode a disk failing after another one listed fails the listing.
+	fastDisk := &listDirDisk{dirs: map[string][]string{"": {"a", "b"}}}
+	for i, diskErr := range []error{errFaultyDisk, errBackend} {
+		slowFaultyDisk := slowListDirDisk{newNaughtyDisk(nil, nil, diskErr), 50 * time.Millisecond}
+		listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{strict: true}, fastDisk, slowFaultyDisk)
+		if entries, _, err := listDir(volume, "", ""); errorCause(err) != diskErr {
+			t.Errorf("Strict test %d: Expected %v, got %v with %v", i+1, diskErr, err, entries)
+		}
+	}
+	// With every disk listing the first listing is returned.
+	listDir := listDirFactoryWithOpts(isLeaf, listDirOpts{strict: true}, fastDisk, slowDisk)
+	if entries, _, err := listDir(volume, "", ""); err != nil || !reflect.DeepEqual(entries, []string{"a", "b"}) {
+		t.Errorf("Expected %v, got %v, %v", []string{"a", "b"}, entries, err)
+	}
+}
+
+// Test resuming walks in or after the directory holding the marker.
+func TestTreeWalkSkipMarkerDir(t *testing.T) {
+	isLeaf := func(volume, prefix string) bool {
+		return !strings.HasSuffix(prefix, slashSeparator)
+	}
+	listDir := listDirFactory(isLeaf, &listDirDisk{dirs: map[string][]string{
+		"":     {"a/", "b/", "c"},
+		"a/":   {"x", "y/"},
+		"a/y/": {"z"},
+		"b/":   {"v", "w"},
+	}})
+	testCases := []struct {
+		marker    string
+		recursive bool
+		reverse   bool
+		descend   []string // Resuming in the marker's directory.
+		skip      []string // Resuming after the marker's directory.
+	}{
+		{"", true, false, []string{"a/x", "a/y/z", "b/v", "b/w", "c"}, []string{"a/x", "a/y/z", "b/v", "b/w", "c"}},
+		// Markers at directory boundaries.
+		{"a/", true, false, []string{"a/x", "a/y/z", "b/v", "b/w", "c"}, []string{"b/v", "b/w", "c"}},
+		{"a/y/", true, false, []string{"a/y/z", "b/v", "b/w", "c"}, []string{"b/v", "b/w", "c"}},
+		{"a/y/z", true, false, []string{"b/v", "b/w", "c"}, []string{"b/v", "b/w", "c"}},
+		// Markers within directories.
+		{"a/x", true, false, []string{"a/y/z", "b/v", "b/w", "c"}, []string{"b/v", "b/w", "c"}},
+		{"b/v", true, false, []string{"b/w", "c"}, []string{"c"}},
+		{"c", true, false, nil, nil},
+		{"b/w", true, true, []string{"b/v", "a/y/z", "a/x"}, []string{"a/y/z", "a/x"}},
+		// Non-recursive walks never descend.
+		{"a/x", false, false, []string{"b/", "c"}, []string{"b/", "c"}},
+	}
+	for i, testCase := range testCases {
+		for _, skipMarkerDir := range []bool{false, true} {
+			expected := testCase.descend
+			if skipMarkerDir {
+				expected = testCase.skip
+			}
+			endWalkCh := make(chan struct{})
+			var entries []string
+			opts := treeWalkOpts{reverse: testCase.reverse, skipMarkerDir: skipMarkerDir}
+			for res := range startTreeWalkWithOpts(context.Background(), volume, "", testCase.marker, testCase.recursive, listDir, isLeaf, endWalkCh, opts) {
+				if res.err != nil {
+					t.Fatalf("Test %d: %v", i+1, res.err)
+				}
+				entries = append(entries, res.entry)
+			}
+			close(endWalkCh)
+			if !reflect.DeepEqual(entries, expected) {
+				t.Errorf("Test %d: Expected %v with skipMarkerDir %v, got %v", i+1, expected, skipMarkerDir, entries)
+			}
+		}
+	}
+}
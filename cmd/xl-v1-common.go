@@ -30,6 +30,12 @@ func (xl xlObjects) getLoadBalancedDisks() (disks []StorageAPI) {
 	return disks
 }
 
+// listDirLoadBalanced - returns a listDir listing the disks least loaded
+// first, see listDirOpts.loadTracker.
+func (xl xlObjects) listDirLoadBalanced(isLeaf isLeafFunc) listDirFunc {
+	return listDirFactoryWithOpts(isLeaf, listDirOpts{loadTracker: xl.listLoad, prefetch: true}, xl.storageDisks...)
+}
+
 // This function does the following check, suppose
 // object is "a/b/c/d", stat makes sure that objects ""a/b/c""
 // "a/b" and "a" do not exist.
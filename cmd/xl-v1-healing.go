@@ -105,7 +105,7 @@ func outDatedDisks(disks []StorageAPI, partsMetadata []xlMetaV1, errs []error) (
 	outDatedDisks = make([]StorageAPI, len(disks))
 	latestDisks, _ := listOnlineDisks(disks, partsMetadata, errs)
 	for index, disk := range latestDisks {
-		if errs[index] == errFileNotFound {
+		if errorCause(errs[index]) == errFileNotFound {
 			outDatedDisks[index] = disks[index]
 			continue
 		}
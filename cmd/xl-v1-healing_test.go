@@ -81,3 +81,51 @@ func TestCommonTime(t *testing.T) {
 		}
 	}
 }
+
+// Validates outDatedDisks picks the disks missing the object or holding
+// an outdated version, also when their errors are traced.
+func TestOutDatedDisks(t *testing.T) {
+	var disks []StorageAPI
+	for i := 0; i < 4; i++ {
+		disks = append(disks, &listDirDisk{})
+	}
+	modTime := time.Unix(1000000, 0).UTC()
+	partsMetadata := func(modTimes ...time.Time) []xlMetaV1 {
+		metadata := make([]xlMetaV1, len(modTimes))
+		for i := range modTimes {
+			metadata[i].Stat.ModTime = modTimes[i]
+		}
+		return metadata
+	}
+	testCases := []struct {
+		partsMetadata []xlMetaV1
+		errs          []error
+		outDated      []bool
+	}{
+		// Object missing on the last disk.
+		{
+			partsMetadata(modTime, modTime, modTime, time.Time{}),
+			[]error{nil, nil, nil, traceError(errFileNotFound)},
+			[]bool{false, false, false, true},
+		},
+		{
+			partsMetadata(modTime, modTime, modTime, time.Time{}),
+			[]error{nil, nil, nil, errFileNotFound},
+			[]bool{false, false, false, true},
+		},
+		// Outdated version on the third disk, the second disk is offline.
+		{
+			partsMetadata(modTime, time.Time{}, modTime.Add(-time.Hour), modTime),
+			[]error{nil, traceError(errDiskNotFound), nil, nil},
+			[]bool{false, false, true, false},
+		},
+	}
+	for i, testCase := range testCases {
+		got := outDatedDisks(disks, testCase.partsMetadata, testCase.errs)
+		for index, outDated := range testCase.outDated {
+			if (got[index] != nil) != outDated {
+				t.Errorf("Test %d: Expected disk %d outdated %v, got %v", i+1, index, outDated, got[index] != nil)
+			}
+		}
+	}
+}
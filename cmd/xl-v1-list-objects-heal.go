@@ -20,16 +20,50 @@ import (
 	"path"
 	"sort"
 	"strings"
+
+	"golang.org/x/net/context"
 )
 
 func listDirHealFactory(disks ...StorageAPI) listDirFunc {
+	return listDirHealFactoryWithOpts(listDirHealOpts{}, disks...)
+}
+
+// listDirHealOpts - optional behavior of the heal listDir, the zero value
+// lists exactly like listDirHealFactory() does.
+type listDirHealOpts struct {
+	// Expected number of entries in a directory (e.g from a previous
+	// listing) used to pre-allocate the merge buffers, avoiding repeated
+	// re-allocations while merging very large directories.
+	entriesHint int
+
+	// Repair during list - when set, every object listed on some disks
+	// but missing on others which were listed successfully is repaired
+	// by calling repairObject (e.g xl.HealObject) before the merged
+	// entries are returned, so that the directory converges and later
+	// listings are consistent. This writes to the disks, hence off by
+	// default, listObjectsHeal() sets it with globalRepairDuringList.
+	repairObject func(bucket, object string) error
+}
+
+// listDirHealFactoryWithOpts - same as listDirHealFactory() with optional
+// behavior set in opts.
+func listDirHealFactoryWithOpts(opts listDirHealOpts, disks ...StorageAPI) listDirFunc {
 	// Returns sorted merged entries from all the disks.
 	listDir := func(bucket, prefixDir, prefixEntry string) (mergedentries []string, delayIsLeaf bool, err error) {
+		var newEntries []string
+		if opts.entriesHint > 0 {
+			newEntries = make([]string, 0, opts.entriesHint)
+		}
+		// Entries of each disk listed successfully, needed only to repair.
+		var disksEntries [][]string
 		for _, disk := range disks {
 			var entries []string
-			var newEntries []string
 			entries, err = disk.ListDir(bucket, prefixDir)
 			if err != nil {
+				// A disk without prefixDir at all differs from the others as well.
+				if opts.repairObject != nil && errorCause(err) == errFileNotFound {
+					disksEntries = append(disksEntries, nil)
+				}
 				// Skip the disk of listDir returns error.
 				continue
 			}
@@ -42,18 +76,26 @@ func listDirHealFactory(disks ...StorageAPI) listDirFunc {
 					}
 				}
 			}
+			if opts.repairObject != nil {
+				disksEntries = append(disksEntries, append([]string(nil), entries...))
+			}
 
 			if len(mergedentries) == 0 {
 				// For the first successful disk.ListDir()
 				mergedentries = entries
+				if opts.entriesHint > len(entries) {
+					mergedentries = make([]string, len(entries), opts.entriesHint)
+					copy(mergedentries, entries)
+				}
 				sort.Strings(mergedentries)
 				continue
 			}
 
 			// find elements in entries which are not in mergedentries
+			newEntries = newEntries[:0]
 			for _, entry := range entries {
 				idx := sort.SearchStrings(mergedentries, entry)
-				if mergedentries[idx] == entry {
+				if idx < len(mergedentries) && mergedentries[idx] == entry {
 					continue
 				}
 				newEntries = append(newEntries, entry)
@@ -65,11 +107,37 @@ func listDirHealFactory(disks ...StorageAPI) listDirFunc {
 				sort.Strings(mergedentries)
 			}
 		}
+		if opts.repairObject != nil {
+			repairDivergentObjects(bucket, prefixDir, mergedentries, disksEntries, opts.repairObject)
+		}
 		return mergedentries, false, nil
 	}
 	return listDir
 }
 
+// repairDivergentObjects - calls repairObject for every object in
+// mergedEntries which is missing in any of disksEntries.
+func repairDivergentObjects(bucket, prefixDir string, mergedEntries []string, disksEntries [][]string, repairObject func(bucket, object string) error) {
+	for _, entries := range disksEntries {
+		sort.Strings(entries)
+	}
+	for _, entry := range mergedEntries {
+		if strings.HasSuffix(entry, slashSeparator) {
+			// Prefixes are re-created by repairing the objects under them.
+			continue
+		}
+		for _, entries := range disksEntries {
+			idx := sort.SearchStrings(entries, entry)
+			if idx < len(entries) && entries[idx] == entry {
+				continue
+			}
+			object := pathJoin(prefixDir, entry)
+			errorIf(repairObject(bucket, object), "Unable to repair %s/%s during listing", bucket, object)
+			break
+		}
+	}
+}
+
 // listObjectsHeal - wrapper function implemented over file tree walk.
 func (xl xlObjects) listObjectsHeal(bucket, prefix, marker, delimiter string, maxKeys int) (ListObjectsInfo, error) {
 	// Default is recursive, if delimiter is set then list non recursive.
@@ -83,8 +151,12 @@ func (xl xlObjects) listObjectsHeal(bucket, prefix, marker, delimiter string, ma
 	walkResultCh, endWalkCh := xl.listPool.Release(listParams{bucket, recursive, marker, prefix, heal})
 	if walkResultCh == nil {
 		endWalkCh = make(chan struct{})
-		listDir := listDirHealFactory(xl.storageDisks...)
-		walkResultCh = startTreeWalk(bucket, prefix, marker, recursive, listDir, nil, endWalkCh)
+		var opts listDirHealOpts
+		if globalRepairDuringList {
+			opts.repairObject = xl.HealObject
+		}
+		listDir := listDirHealFactoryWithOpts(opts, xl.storageDisks...)
+		walkResultCh = startTreeWalk(context.Background(), bucket, prefix, marker, recursive, listDir, nil, endWalkCh)
 	}
 
 	var objInfos []ObjectInfo
@@ -107,7 +179,7 @@ func (xl xlObjects) listObjectsHeal(bucket, prefix, marker, delimiter string, ma
 		}
 		entry := walkResult.entry
 		var objInfo ObjectInfo
-		if strings.HasSuffix(entry, slashSeparator) {
+		if walkResult.isDir {
 			// Object name needs to be full path.
 			objInfo.Bucket = bucket
 			objInfo.Name = entry
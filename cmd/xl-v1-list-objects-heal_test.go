@@ -0,0 +1,213 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// Returns disks which together hold numEntries entries at the
+// top level, spread round robin so that each disk has a disjoint share.
+func getListDirDisks(numDisks, numEntries int) []StorageAPI {
+	dirs := make([]map[string][]string, numDisks)
+	for i := range dirs {
+		dirs[i] = map[string][]string{"": nil}
+	}
+	for i := 0; i < numEntries; i++ {
+		dirs[i%numDisks][""] = append(dirs[i%numDisks][""], fmt.Sprintf("object-%08d", i))
+	}
+	disks := make([]StorageAPI, numDisks)
+	for i := range disks {
+		disks[i] = &listDirDisk{dirs: dirs[i]}
+	}
+	return disks
+}
+
+// Test listDirHealFactory merges entries from all disks with or without a hint.
+func TestListDirHealMerge(t *testing.T) {
+	disks := getListDirDisks(4, 100)
+	var expected []string
+	for i := 0; i < 100; i++ {
+		expected = append(expected, fmt.Sprintf("object-%08d", i))
+	}
+	for i, entriesHint := range []int{0, 10, 100, 1000} {
+		listDir := listDirHealFactoryWithOpts(listDirHealOpts{entriesHint: entriesHint}, disks...)
+		entries, _, err := listDir(volume, "", "")
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error %s", i+1, err)
+		}
+		if !reflect.DeepEqual(expected, entries) {
+			t.Errorf("Test %d: Expected %v, got %v", i+1, expected, entries)
+		}
+	}
+}
+
+// Test divergent disks converge after a walk with repair during list.
+func TestListDirHealRepair(t *testing.T) {
+	obj, fsDirs, err := prepareXL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeRoots(fsDirs)
+	xl := obj.(xlObjects)
+
+	bucket := "bucket"
+	if err = obj.MakeBucket(bucket); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("hello")
+	for _, object := range []string{"c", "d/a", "d/b"} {
+		_, err = obj.PutObject(bucket, object, int64(len(data)), bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Remove "d/b" from the first disk.
+	if err = os.RemoveAll(filepath.Join(fsDirs[0], bucket, "d", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(listDir listDirFunc) []string {
+		var entries []string
+		endWalkCh := make(chan struct{})
+		defer close(endWalkCh)
+		for walkResult := range startTreeWalk(context.Background(), bucket, "", "", true, listDir, nil, endWalkCh) {
+			if walkResult.err != nil {
+				t.Fatal(walkResult.err)
+			}
+			entries = append(entries, walkResult.entry)
+		}
+		return entries
+	}
+	expected := []string{"c", "d/a", "d/b"}
+
+	// Without repair the listing is complete but the disk stays divergent.
+	if got := walk(listDirHealFactory(xl.storageDisks...)); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if _, err = xl.storageDisks[0].StatFile(bucket, pathJoin("d/b", xlMetaJSONFile)); errorCause(err) != errFileNotFound {
+		t.Fatalf("Expected %s, got %v", errFileNotFound, err)
+	}
+
+	// With repair the missing object is healed on the first disk.
+	var repaired []string
+	repairObject := func(bucket, object string) error {
+		repaired = append(repaired, object)
+		return xl.HealObject(bucket, object)
+	}
+	listDir := listDirHealFactoryWithOpts(listDirHealOpts{repairObject: repairObject}, xl.storageDisks...)
+	if got := walk(listDir); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if !reflect.DeepEqual(repaired, []string{"d/b"}) {
+		t.Errorf("Expected only d/b to be repaired, got %v", repaired)
+	}
+	if _, err = xl.storageDisks[0].StatFile(bucket, pathJoin("d/b", xlMetaJSONFile)); err != nil {
+		t.Errorf("Expected d/b to be repaired on the first disk, got %s", err)
+	}
+
+	// Converged disks need no further repair.
+	repaired = nil
+	walk(listDir)
+	if len(repaired) != 0 {
+		t.Errorf("Expected no repairs after convergence, got %v", repaired)
+	}
+}
+
+// Test heal listing repairs divergent objects with globalRepairDuringList set.
+func TestListObjectsHealRepair(t *testing.T) {
+	obj, fsDirs, err := prepareXL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeRoots(fsDirs)
+	xl := obj.(xlObjects)
+
+	bucket := "bucket"
+	if err = obj.MakeBucket(bucket); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("hello")
+	for _, object := range []string{"a", "b"} {
+		_, err = obj.PutObject(bucket, object, int64(len(data)), bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Remove "b" from the first disk.
+	if err = os.RemoveAll(filepath.Join(fsDirs[0], bucket, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the flag the object needing heal is only listed.
+	result, err := obj.ListObjectsHeal(bucket, "", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Name != "b" {
+		t.Fatalf("Expected b to need healing, got %v", result.Objects)
+	}
+	if _, err = xl.storageDisks[0].StatFile(bucket, pathJoin("b", xlMetaJSONFile)); errorCause(err) != errFileNotFound {
+		t.Fatalf("Expected %s, got %v", errFileNotFound, err)
+	}
+
+	// With the flag it is repaired while listing.
+	globalRepairDuringList = true
+	defer func() { globalRepairDuringList = false }()
+	if result, err = obj.ListObjectsHeal(bucket, "", "", "", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Objects) != 0 {
+		t.Errorf("Expected no objects needing healing, got %v", result.Objects)
+	}
+	if _, err = xl.storageDisks[0].StatFile(bucket, pathJoin("b", xlMetaJSONFile)); err != nil {
+		t.Errorf("Expected b to be repaired on the first disk, got %s", err)
+	}
+}
+
+// Benchmark merging a million-entry directory from 4 disks.
+func benchmarkListDirHeal(b *testing.B, entriesHint int) {
+	numEntries := 1000 * 1000
+	disks := getListDirDisks(4, numEntries)
+	listDir := listDirHealFactoryWithOpts(listDirHealOpts{entriesHint: entriesHint}, disks...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entries, _, err := listDir(volume, "", "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(entries) != numEntries {
+			b.Fatalf("Expected %d entries, got %d", numEntries, len(entries))
+		}
+	}
+}
+
+func BenchmarkListDirHealNoHint(b *testing.B) {
+	benchmarkListDirHeal(b, 0)
+}
+
+func BenchmarkListDirHealWithHint(b *testing.B) {
+	benchmarkListDirHeal(b, 1000*1000)
+}
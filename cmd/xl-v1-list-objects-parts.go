@@ -0,0 +1,74 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+// objectPartRange - byte range of a part within an object.
+type objectPartRange struct {
+	Number int
+	Offset int64
+	Size   int64
+}
+
+// listObjectsPartsInfo - list objects result along with the part layout
+// of the listed objects.
+type listObjectsPartsInfo struct {
+	ListObjectsInfo
+	// Part layout by object name, only present for objects at least as
+	// large as the requested threshold.
+	Parts map[string][]objectPartRange
+}
+
+// Returns the byte range of every part from the parts in xl.json.
+func getObjectPartRanges(parts []objectPartInfo) []objectPartRange {
+	ranges := make([]objectPartRange, len(parts))
+	var offset int64
+	for i, part := range parts {
+		ranges[i] = objectPartRange{Number: part.Number, Offset: offset, Size: part.Size}
+		offset += part.Size
+	}
+	return ranges
+}
+
+// listObjectsParts - lists objects like ListObjects and additionally
+// returns the part layout of every listed object of size minSize or
+// more. Reading the layout costs an extra xl.json read per object, hence
+// minSize should leave out objects too small to need ranged reads.
+func (xl xlObjects) listObjectsParts(bucket, prefix, marker, delimiter string, maxKeys int, minSize int64) (listObjectsPartsInfo, error) {
+	result, err := xl.ListObjects(bucket, prefix, marker, delimiter, maxKeys)
+	if err != nil {
+		return listObjectsPartsInfo{}, err
+	}
+	partsResult := listObjectsPartsInfo{
+		ListObjectsInfo: result,
+		Parts:           make(map[string][]objectPartRange),
+	}
+	for _, objInfo := range result.Objects {
+		if objInfo.IsDir || objInfo.Size < minSize {
+			continue
+		}
+		parts, err := xl.readXLMetaParts(bucket, objInfo.Name)
+		if err != nil {
+			// Ignore objects removed after they were listed.
+			if errorCause(err) == errFileNotFound {
+				continue
+			}
+			return listObjectsPartsInfo{}, toObjectErr(err, bucket, objInfo.Name)
+		}
+		partsResult.Parts[objInfo.Name] = getObjectPartRanges(parts)
+	}
+	return partsResult, nil
+}
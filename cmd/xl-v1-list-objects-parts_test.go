@@ -0,0 +1,80 @@
+/*
+ * Minio Cloud Storage, (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Test part layout is listed for single part and multipart objects.
+func TestListObjectsParts(t *testing.T) {
+	obj, fsDirs, err := prepareXL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeRoots(fsDirs)
+	xl := obj.(xlObjects)
+
+	bucket := "bucket"
+	if err = obj.MakeBucket(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = obj.PutObject(bucket, "single", 10, bytes.NewReader(make([]byte, 10)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = obj.PutObject(bucket, "tiny", 1, bytes.NewReader(make([]byte, 1)), nil); err != nil {
+		t.Fatal(err)
+	}
+	uploadID, err := obj.NewMultipartUpload(bucket, "multi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partSizes := []int64{minPartSize, 3}
+	var parts []completePart
+	for i, size := range partSizes {
+		md5Hex, pErr := obj.PutObjectPart(bucket, "multi", uploadID, i+1, size, bytes.NewReader(make([]byte, size)), "")
+		if pErr != nil {
+			t.Fatal(pErr)
+		}
+		parts = append(parts, completePart{PartNumber: i + 1, ETag: md5Hex})
+	}
+	if _, err = obj.CompleteMultipartUpload(bucket, "multi", uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := xl.listObjectsParts(bucket, "", "", "", 1000, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Objects) != 3 {
+		t.Fatalf("Expected 3 objects, got %d", len(result.Objects))
+	}
+	expected := map[string][]objectPartRange{
+		"multi": {
+			{Number: 1, Offset: 0, Size: minPartSize},
+			{Number: 2, Offset: minPartSize, Size: 3},
+		},
+		"single": {
+			{Number: 1, Offset: 0, Size: 10},
+		},
+	}
+	if !reflect.DeepEqual(expected, result.Parts) {
+		t.Errorf("Expected %v, got %v", expected, result.Parts)
+	}
+}
@@ -16,10 +16,18 @@
 
 package cmd
 
-import "strings"
+import (
+	"strings"
+	"time"
 
-// listObjects - wrapper function implemented over file tree walk.
-func (xl xlObjects) listObjects(bucket, prefix, marker, delimiter string, maxKeys int) (ListObjectsInfo, error) {
+	"golang.org/x/net/context"
+)
+
+// listObjects - wrapper function implemented over file tree walk. A non-zero
+// softDeadline bounds the time spent listing, once it expires the entries
+// listed so far are returned as a truncated result whose NextMarker resumes
+// the listing.
+func (xl xlObjects) listObjects(bucket, prefix, marker, delimiter string, maxKeys int, softDeadline time.Duration) (ListObjectsInfo, error) {
 	// Default is recursive, if delimiter is set then list non recursive.
 	recursive := true
 	if delimiter == slashSeparator {
@@ -31,15 +39,34 @@ func (xl xlObjects) listObjects(bucket, prefix, marker, delimiter string, maxKey
 	if walkResultCh == nil {
 		endWalkCh = make(chan struct{})
 		isLeaf := xl.isObject
-		listDir := listDirFactory(isLeaf, xl.getLoadBalancedDisks()...)
-		walkResultCh = startTreeWalk(bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh)
+		listDir := xl.listDirLoadBalanced(isLeaf)
+		walkResultCh = startTreeWalk(context.Background(), bucket, prefix, marker, recursive, listDir, isLeaf, endWalkCh)
+	}
+
+	// A nil deadlineCh never fires, the listing is then bounded by maxKeys alone.
+	var deadlineCh <-chan time.Time
+	if softDeadline > 0 {
+		deadlineTimer := time.NewTimer(softDeadline)
+		defer deadlineTimer.Stop()
+		deadlineCh = deadlineTimer.C
 	}
 
 	var objInfos []ObjectInfo
 	var eof bool
-	var nextMarker string
+	// Resume from marker itself if the soft deadline expires before anything is listed.
+	nextMarker := marker
 	for i := 0; i < maxKeys; {
-		walkResult, ok := <-walkResultCh
+		var walkResult treeWalkResult
+		var ok, expired bool
+		select {
+		case walkResult, ok = <-walkResultCh:
+		case <-deadlineCh:
+			expired = true
+		}
+		if expired {
+			// Soft deadline has expired, return what is listed so far.
+			break
+		}
 		if !ok {
 			// Closed channel.
 			eof = true
@@ -55,7 +82,7 @@ func (xl xlObjects) listObjects(bucket, prefix, marker, delimiter string, maxKey
 		}
 		entry := walkResult.entry
 		var objInfo ObjectInfo
-		if strings.HasSuffix(entry, slashSeparator) {
+		if walkResult.isDir {
 			// Object name needs to be full path.
 			objInfo.Bucket = bucket
 			objInfo.Name = entry
@@ -87,6 +114,9 @@ func (xl xlObjects) listObjects(bucket, prefix, marker, delimiter string, maxKey
 	}
 
 	result := ListObjectsInfo{IsTruncated: !eof}
+	if !eof {
+		result.NextMarker = nextMarker
+	}
 	for _, objInfo := range objInfos {
 		result.NextMarker = objInfo.Name
 		if objInfo.IsDir {
@@ -152,7 +182,7 @@ func (xl xlObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKey
 	}
 
 	// Initiate a list operation, if successful filter and return quickly.
-	listObjInfo, err := xl.listObjects(bucket, prefix, marker, delimiter, maxKeys)
+	listObjInfo, err := xl.listObjects(bucket, prefix, marker, delimiter, maxKeys, globalListSoftDeadline)
 	if err == nil {
 		// We got the entries successfully return.
 		return listObjInfo, nil
@@ -161,3 +191,86 @@ func (xl xlObjects) ListObjects(bucket, prefix, marker, delimiter string, maxKey
 	// Return error at the end.
 	return ListObjectsInfo{}, toObjectErr(err, bucket, prefix)
 }
+
+// ListObjectsPaged - lists upto limit objects under prefix a page at a
+// time, token is empty for the first page and the nextToken returned
+// with the previous page afterwards. nextToken is empty on the last page.
+// With delimiter set to '/' the listing is not recursive and common
+// prefixes are returned instead of the objects under them.
+func (xl xlObjects) ListObjectsPaged(bucket, prefix, token, delimiter string, limit int) (ListObjectsInfo, string, error) {
+	if !IsValidBucketName(bucket) {
+		return ListObjectsInfo{}, "", traceError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !xl.isBucketExist(bucket) {
+		return ListObjectsInfo{}, "", traceError(BucketNotFound{Bucket: bucket})
+	}
+	if !IsValidObjectPrefix(prefix) {
+		return ListObjectsInfo{}, "", traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	}
+	// Verify if delimiter is anything other than '/', which we do not support.
+	if delimiter != "" && delimiter != slashSeparator {
+		return ListObjectsInfo{}, "", traceError(UnsupportedDelimiter{
+			Delimiter: delimiter,
+		})
+	}
+	// Default is recursive, if delimiter is set then list non recursive.
+	recursive := delimiter != slashSeparator
+	isLeaf := xl.isObject
+	listDir := xl.listDirLoadBalanced(isLeaf)
+	result, nextToken, err := listObjectsPaged(bucket, prefix, token, limit, recursive, xl.listPool, listDir, isLeaf, xl.getObjectInfo)
+	if err != nil {
+		return ListObjectsInfo{}, "", toObjectErr(err, bucket, prefix)
+	}
+	return result, nextToken, nil
+}
+
+// EstimateListCost - projects the number of directories and entries
+// listing prefix touches by sampling the top of the tree, so that clients
+// can decide whether to paginate or run the listing in the background.
+func (xl xlObjects) EstimateListCost(bucket, prefix string, recursive bool) (ListCostEstimate, error) {
+	if !IsValidBucketName(bucket) {
+		return ListCostEstimate{}, traceError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !xl.isBucketExist(bucket) {
+		return ListCostEstimate{}, traceError(BucketNotFound{Bucket: bucket})
+	}
+	if !IsValidObjectPrefix(prefix) {
+		return ListCostEstimate{}, traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	}
+	isLeaf := xl.isObject
+	listDir := xl.listDirLoadBalanced(isLeaf)
+	estimate, err := estimateListCost(bucket, prefix, recursive, listDir, isLeaf, listCostProbes, time.Now().UnixNano())
+	if err != nil {
+		return ListCostEstimate{}, toObjectErr(err, bucket, prefix)
+	}
+	return estimate, nil
+}
+
+// ListAndDelete - deletes every object under prefix for which filter
+// returns true, a nil filter matches all objects. Objects are deleted by
+// deleteFn, by DeleteObject() if nil. deleted counts the objects deleted
+// even when an error is returned, failed deletes are returned together
+// as ListAndDeleteError once all objects were walked.
+func (xl xlObjects) ListAndDelete(ctx context.Context, bucket, prefix string, filter func(ObjectInfo) bool, deleteFn func(key string) error) (deleted int, err error) {
+	if !IsValidBucketName(bucket) {
+		return 0, traceError(BucketNameInvalid{Bucket: bucket})
+	}
+	if !xl.isBucketExist(bucket) {
+		return 0, traceError(BucketNotFound{Bucket: bucket})
+	}
+	if !IsValidObjectPrefix(prefix) {
+		return 0, traceError(ObjectNameInvalid{Bucket: bucket, Object: prefix})
+	}
+	if deleteFn == nil {
+		deleteFn = func(key string) error {
+			return xl.DeleteObject(bucket, key)
+		}
+	}
+	isLeaf := xl.isObject
+	listDir := xl.listDirLoadBalanced(isLeaf)
+	deleted, err = listAndDelete(ctx, bucket, prefix, listDir, isLeaf, xl.getObjectInfo, filter, deleteFn)
+	if err != nil {
+		return deleted, toObjectErr(err, bucket, prefix)
+	}
+	return deleted, nil
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/minio/minio/pkg/mimedb"
 	"github.com/skyrings/skyring-common/tools/uuid"
+	"golang.org/x/net/context"
 )
 
 // listMultipartUploads - lists all multipart uploads.
@@ -95,8 +96,8 @@ func (xl xlObjects) listMultipartUploads(bucket, prefix, keyMarker, uploadIDMark
 		if walkerCh == nil {
 			walkerDoneCh = make(chan struct{})
 			isLeaf := xl.isMultipartUpload
-			listDir := listDirFactory(isLeaf, xl.getLoadBalancedDisks()...)
-			walkerCh = startTreeWalk(minioMetaBucket, multipartPrefixPath, multipartMarkerPath, recursive, listDir, isLeaf, walkerDoneCh)
+			listDir := xl.listDirLoadBalanced(isLeaf)
+			walkerCh = startTreeWalk(context.Background(), minioMetaBucket, multipartPrefixPath, multipartMarkerPath, recursive, listDir, isLeaf, walkerDoneCh)
 		}
 		// Collect uploads until we have reached maxUploads count to 0.
 		for maxUploads > 0 {
@@ -60,6 +60,9 @@ type xlObjects struct {
 	// ListObjects pool management.
 	listPool *treeWalkPool
 
+	// Outstanding listings of each disk, listings go to the least loaded disks.
+	listLoad *diskLoadTracker
+
 	// Object cache for caching objects.
 	objCache *objcache.Cache
 
@@ -157,6 +160,7 @@ func newXLObjects(disks, ignoredDisks []string) (ObjectLayer, error) {
 		dataBlocks:      dataBlocks,
 		parityBlocks:    parityBlocks,
 		listPool:        listPool,
+		listLoad:        newDiskLoadTracker(len(newPosixDisks)),
 		objCache:        objCache,
 		objCacheEnabled: globalMaxCacheSize > 0,
 	}